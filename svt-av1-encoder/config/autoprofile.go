@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProfileAuto picks one of the built-in profiles from a content analysis of the source
+const ProfileAuto Profile = "auto"
+
+// AutoFactKeys are the content facts auto rules can test
+//
+//	animation  1 if the source looks or is tagged as animation, else 0
+//	hdr        1 for HDR10/HLG/Dolby Vision sources, else 0
+//	grain      recommended film-grain level (0 clean … 20 extreme)
+//	motion     scene cuts per minute
+//	height     video height in pixels
+//	bpp        source video bits per pixel per frame
+var AutoFactKeys = []string{"animation", "hdr", "grain", "motion", "height", "bpp"}
+
+// DefaultAutoRulesText is the built-in mapping, in the same format users can override with
+const DefaultAutoRulesText = `
+# Flat colours and hard edges band at high CRF, and animation is cheap to encode well
+animation => quality
+# Visible grain needs grain synthesis rather than being smeared by the encoder
+grain>=8 => film
+# HDR highlights and gradients need the extra bits
+hdr => quality
+# Few cuts on a modest source: talking heads, lectures, screen recordings
+motion<=2 height<=1080 => podcast
+# Already starved sources gain nothing from a high-quality encode
+bpp<0.04 => compress
+* => default
+`
+
+// AutoCondition compares one content fact with a value, e.g. grain>=8
+type AutoCondition struct {
+	Key   string
+	Op    string // >=, <=, >, <, =, or "" for a true boolean fact
+	Value float64
+}
+
+// AutoRule maps content facts to a profile; all conditions must hold, and the first matching rule wins
+type AutoRule struct {
+	Conditions []AutoCondition // Empty matches everything ("*")
+	Profile    Profile
+	Text       string // Rule as written, for explanations
+}
+
+// DefaultAutoRules returns the built-in auto profile mapping
+func DefaultAutoRules() []AutoRule {
+	rules, err := ParseAutoRules(DefaultAutoRulesText)
+	if err != nil {
+		panic("invalid built-in auto rules: " + err.Error())
+	}
+	return rules
+}
+
+// ParseAutoRules reads rules written one per line as "conditions => profile"
+// Conditions are space-separated: "animation" (fact is true), "!hdr" (fact is false),
+// "grain>=8", "height<=1080" and so on; "*" matches everything. # starts a comment.
+func ParseAutoRules(text string) ([]AutoRule, error) {
+	var rules []AutoRule
+	for n, line := range strings.Split(text, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		conds, target, ok := strings.Cut(line, "=>")
+		if !ok {
+			return nil, fmt.Errorf("line %d: missing '=>' in %q", n+1, line)
+		}
+		profile := Profile(strings.ToLower(strings.TrimSpace(target)))
+		if !isBuiltinProfile(profile) {
+			return nil, fmt.Errorf("line %d: unknown profile %q", n+1, profile)
+		}
+
+		rule := AutoRule{Profile: profile, Text: line}
+		for _, field := range strings.Fields(conds) {
+			if field == "*" {
+				continue
+			}
+			cond, err := parseAutoCondition(field)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			rule.Conditions = append(rule.Conditions, cond)
+		}
+		rules = append(rules, rule)
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("no rules found")
+	}
+	return rules, nil
+}
+
+// parseAutoCondition parses one condition like "grain>=8", "animation" or "!hdr"
+func parseAutoCondition(field string) (AutoCondition, error) {
+	for _, op := range []string{">=", "<=", ">", "<", "="} {
+		key, value, ok := strings.Cut(field, op)
+		if !ok {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return AutoCondition{}, fmt.Errorf("invalid value in %q", field)
+		}
+		if !isAutoFactKey(key) {
+			return AutoCondition{}, fmt.Errorf("unknown fact %q (available: %s)", key, strings.Join(AutoFactKeys, ", "))
+		}
+		return AutoCondition{Key: key, Op: op, Value: v}, nil
+	}
+
+	// Bare boolean facts: "animation" means animation=1, "!hdr" means hdr=0
+	key, negated := strings.CutPrefix(field, "!")
+	if !isAutoFactKey(key) {
+		return AutoCondition{}, fmt.Errorf("unknown fact %q (available: %s)", key, strings.Join(AutoFactKeys, ", "))
+	}
+	if negated {
+		return AutoCondition{Key: key, Op: "=", Value: 0}, nil
+	}
+	return AutoCondition{Key: key}, nil
+}
+
+// Holds reports whether the condition is true for the given facts
+// A fact that couldn't be measured is missing, and no condition on it holds
+func (c AutoCondition) Holds(facts map[string]float64) bool {
+	v, ok := facts[c.Key]
+	if !ok {
+		return false
+	}
+	switch c.Op {
+	case ">=":
+		return v >= c.Value
+	case "<=":
+		return v <= c.Value
+	case ">":
+		return v > c.Value
+	case "<":
+		return v < c.Value
+	case "=":
+		return v == c.Value
+	}
+	return v != 0
+}
+
+// Matches reports whether every condition of the rule holds
+func (r AutoRule) Matches(facts map[string]float64) bool {
+	for _, c := range r.Conditions {
+		if !c.Holds(facts) {
+			return false
+		}
+	}
+	return true
+}
+
+// ChooseProfile returns the first rule matching the facts, falling back to the default profile
+func ChooseProfile(rules []AutoRule, facts map[string]float64) AutoRule {
+	for _, r := range rules {
+		if r.Matches(facts) {
+			return r
+		}
+	}
+	return AutoRule{Profile: ProfileDefault, Text: "no rule matched"}
+}
+
+// WithProfile returns c with the rate-control and tuning settings of a built-in profile,
+// keeping everything else (stream handling, analysis and post-encode options)
+// A resolution cap or grain mode already set on c is kept, since it was chosen explicitly
+func (c Config) WithProfile(p Profile) Config {
+	src := GetProfile(p)
+	c.ProfileName = p
+	c.CRF = src.CRF
+	c.Preset = src.Preset
+	c.Tune = src.Tune
+	c.VarianceBoost = src.VarianceBoost
+	c.VarianceBoostStrength = src.VarianceBoostStrength
+	c.Sharpness = src.Sharpness
+	c.TFStrength = src.TFStrength
+	c.FilmGrain = src.FilmGrain
+	c.ExpectedSizePercent = src.ExpectedSizePercent
+	if c.MaxWidth == 0 && c.MaxHeight == 0 {
+		c.MaxWidth, c.MaxHeight = src.MaxWidth, src.MaxHeight
+	}
+	if c.GrainMode == GrainOff {
+		c.GrainMode = src.GrainMode
+	}
+	if c.PreFilters == nil {
+		c.PreFilters = src.PreFilters
+	}
+	return c
+}
+
+func isBuiltinProfile(p Profile) bool {
+	for _, known := range AvailableProfiles() {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+func isAutoFactKey(key string) bool {
+	for _, k := range AutoFactKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
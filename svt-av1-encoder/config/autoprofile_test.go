@@ -0,0 +1,108 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAutoRules(t *testing.T) {
+	rules, err := ParseAutoRules(`
+		# comment
+		!hdr grain>=12 => film   # trailing comment
+		animation => quality
+		* => compress
+	`)
+	if err != nil {
+		t.Fatalf("ParseAutoRules error: %v", err)
+	}
+	if len(rules) != 3 {
+		t.Fatalf("got %d rules, want 3", len(rules))
+	}
+	want := []AutoCondition{{Key: "hdr", Op: "=", Value: 0}, {Key: "grain", Op: ">=", Value: 12}}
+	if len(rules[0].Conditions) != 2 || rules[0].Conditions[0] != want[0] || rules[0].Conditions[1] != want[1] {
+		t.Errorf("conditions = %+v, want %+v", rules[0].Conditions, want)
+	}
+	if rules[0].Text != "!hdr grain>=12 => film" || rules[2].Profile != ProfileCompress || len(rules[2].Conditions) != 0 {
+		t.Errorf("rules parsed as %+v", rules)
+	}
+
+	for _, bad := range []string{"grain>=8 film", "grain>=8 => turbo", "noise>3 => film", "grain>=lots => film", ""} {
+		if _, err := ParseAutoRules(bad); err == nil {
+			t.Errorf("ParseAutoRules(%q) should fail", bad)
+		}
+	}
+}
+
+func TestChooseProfile(t *testing.T) {
+	rules := DefaultAutoRules()
+	tests := []struct {
+		name  string
+		facts map[string]float64
+		want  Profile
+	}{
+		{"anime", map[string]float64{"animation": 1, "grain": 0, "height": 1080, "motion": 12, "bpp": 0.1}, ProfileQuality},
+		{"grainy film", map[string]float64{"grain": 12, "height": 2160, "motion": 8, "bpp": 0.15}, ProfileFilm},
+		{"hdr", map[string]float64{"hdr": 1, "height": 2160, "motion": 8, "bpp": 0.08}, ProfileQuality},
+		{"lecture", map[string]float64{"height": 1080, "motion": 0.5, "bpp": 0.05}, ProfilePodcast},
+		{"starved web rip", map[string]float64{"height": 1080, "motion": 10, "bpp": 0.02}, ProfileCompress},
+		{"general", map[string]float64{"height": 1080, "motion": 10, "bpp": 0.1}, ProfileDefault},
+		// Unmeasured bitrate and duration leave bpp and motion out; they must not read as 0
+		{"unknown bitrate and duration", map[string]float64{"animation": 0, "hdr": 0, "height": 1080}, ProfileDefault},
+	}
+	for _, tc := range tests {
+		if got := ChooseProfile(rules, tc.facts); got.Profile != tc.want {
+			t.Errorf("%s: chose %s (rule %q), want %s", tc.name, got.Profile, got.Text, tc.want)
+		}
+	}
+
+	for _, field := range []string{"bpp<0.04", "!bpp", "bpp=0"} {
+		cond, err := parseAutoCondition(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond.Holds(map[string]float64{}) {
+			t.Errorf("%s should not hold for an unmeasured fact", field)
+		}
+	}
+
+	if got := ChooseProfile(nil, nil); got.Profile != ProfileDefault || !strings.Contains(got.Text, "no rule") {
+		t.Errorf("no rules should fall back to default, got %+v", got)
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	cfg := GetProfile(ProfileAuto)
+	cfg.CropMode = CropAuto
+
+	film := cfg.WithProfile(ProfileFilm)
+	if film.ProfileName != ProfileFilm || film.CRF != 32 || film.FilmGrain != 8 || film.GrainMode != GrainSuggest {
+		t.Errorf("film settings not applied: %+v", film)
+	}
+	if film.CropMode != CropAuto {
+		t.Error("WithProfile should keep non-profile settings")
+	}
+
+	cfg.MaxWidth, cfg.MaxHeight = 1280, 720
+	if podcast := cfg.WithProfile(ProfilePodcast); podcast.MaxWidth != 1280 {
+		t.Errorf("explicit resolution cap replaced by profile cap: %dx%d", podcast.MaxWidth, podcast.MaxHeight)
+	}
+}
+
+func TestResolutionClassOf(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          ResolutionClass
+	}{
+		{720, 480, ResolutionSD},
+		{1280, 720, ResolutionHD},
+		{1920, 800, ResolutionFHD},
+		{1440, 1080, ResolutionFHD},
+		{2560, 1440, ResolutionFHD},
+		{3840, 1600, ResolutionUHD},
+	}
+	for _, tc := range tests {
+		if got := ResolutionClassOf(tc.width, tc.height); got != tc.want {
+			t.Errorf("ResolutionClassOf(%d, %d) = %s, want %s", tc.width, tc.height, got, tc.want)
+		}
+	}
+}
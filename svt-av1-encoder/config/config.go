@@ -1,5 +1,11 @@
 package config
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Profile represents a named encoding profile
 type Profile string
 
@@ -12,6 +18,178 @@ const (
 	ProfileExtreme  Profile = "extreme"  // Extreme compression - smallest possible files
 )
 
+// CropMode controls automatic black-bar cropping
+type CropMode string
+
+const (
+	CropOff          CropMode = "off"          // Always encode the full frame
+	CropAuto         CropMode = "auto"         // Crop when most samples agree on the bars
+	CropConservative CropMode = "conservative" // Crop only when every sample agrees and bars are substantial
+)
+
+// DeinterlaceMode controls interlace and telecine handling
+type DeinterlaceMode string
+
+const (
+	DeinterlaceOff  DeinterlaceMode = "off"  // Encode fields as-is
+	DeinterlaceAuto DeinterlaceMode = "auto" // Detect with idet and deinterlace or inverse-telecine as needed
+)
+
+// Deinterlacer selects the filter used for interlaced frames
+type Deinterlacer string
+
+const (
+	DeinterlacerBwdif Deinterlacer = "bwdif" // Better quality, slightly slower
+	DeinterlacerYadif Deinterlacer = "yadif" // Classic, fastest
+)
+
+// FrameRateMode controls how variable frame rate sources are encoded
+type FrameRateMode string
+
+const (
+	FrameRatePreserve FrameRateMode = "preserve" // Keep the source timestamps (no duplicated or dropped frames)
+	FrameRateCFR      FrameRateMode = "cfr"      // Convert to a constant TargetFrameRate
+)
+
+// ToneMapper selects the curve that maps HDR highlights into SDR range for the companion encode
+type ToneMapper string
+
+const (
+	ToneMapHable  ToneMapper = "hable"  // Filmic curve, keeps highlight detail at some cost in contrast
+	ToneMapMobius ToneMapper = "mobius" // Leaves in-range colours exact and compresses only highlights
+	ToneMapBT2390 ToneMapper = "bt2390" // ITU-R BT.2390 EETF (rendered by libplacebo)
+)
+
+// StallAction is what the watchdog does when an encode stops making progress
+type StallAction string
+
+const (
+	StallWarn    StallAction = "warn"    // Mark the job as stalled and keep waiting
+	StallCancel  StallAction = "cancel"  // Stop the encode with a stall error
+	StallRestart StallAction = "restart" // Kill ffmpeg and start the encode again, up to StallRestarts times
+)
+
+// Scaler selects the resize filter used when downscaling
+type Scaler string
+
+const (
+	ScalerLanczos  Scaler = "lanczos"  // Sharp, good default for downscaling
+	ScalerSpline36 Scaler = "spline36" // Slightly softer with less ringing (needs zscale)
+	ScalerBicubic  Scaler = "bicubic"  // Fastest
+)
+
+// Container is the output file format
+type Container string
+
+const (
+	ContainerMKV Container = "mkv" // Matroska, holds every subtitle and attachment type
+	ContainerMP4 Container = "mp4" // MP4, text subtitles only as mov_text and no attachments
+)
+
+// SubtitleMode selects which subtitle tracks reach the output
+type SubtitleMode string
+
+const (
+	SubtitlesAll       SubtitleMode = "all"       // Keep every track allowed by the language rules
+	SubtitlesPreferred SubtitleMode = "preferred" // Keep forced tracks and tracks in the preferred language
+	SubtitlesNone      SubtitleMode = "none"      // Drop all subtitles (sidecars are still extracted)
+)
+
+// KeyframeMode selects who places keyframes
+type KeyframeMode string
+
+const (
+	KeyframesFixed KeyframeMode = "fixed" // -g computed from GOPSeconds and the source frame rate, no scene-change keyframes
+	KeyframesSVT   KeyframeMode = "svt"   // SVT-AV1's keyint (in seconds) and scene-change detection
+)
+
+// GrainMode controls film-grain estimation before encoding
+type GrainMode string
+
+const (
+	GrainOff     GrainMode = "off"     // Use the profile's FilmGrain as-is
+	GrainSuggest GrainMode = "suggest" // Estimate noise and show a recommendation
+	GrainApply   GrainMode = "apply"   // Estimate noise and use the recommended film-grain settings
+)
+
+// ResolutionClass groups sources by frame size for per-class thresholds
+type ResolutionClass string
+
+const (
+	ResolutionSD  ResolutionClass = "sd"  // Below 720p
+	ResolutionHD  ResolutionClass = "hd"  // 720p
+	ResolutionFHD ResolutionClass = "fhd" // 1080p and 1440p
+	ResolutionUHD ResolutionClass = "uhd" // 2160p and up
+)
+
+// ResolutionClasses returns all classes from smallest to largest
+func ResolutionClasses() []ResolutionClass {
+	return []ResolutionClass{ResolutionSD, ResolutionHD, ResolutionFHD, ResolutionUHD}
+}
+
+// ResolutionClassOf classifies a frame size; either dimension counts, so letterboxed
+// 1920x800 is still 1080p and 4:3 1440x1080 is too
+func ResolutionClassOf(width, height int) ResolutionClass {
+	switch {
+	case width >= 3200 || height >= 2000:
+		return ResolutionUHD
+	case width >= 1700 || height >= 1000:
+		return ResolutionFHD
+	case width >= 1200 || height >= 700:
+		return ResolutionHD
+	}
+	return ResolutionSD
+}
+
+// DefaultMinBitsPerPixel are suggested skip thresholds; larger frames need fewer bits per
+// pixel for the same quality, so the thresholds fall with resolution
+func DefaultMinBitsPerPixel() map[ResolutionClass]float64 {
+	return map[ResolutionClass]float64{
+		ResolutionSD:  0.10,
+		ResolutionHD:  0.07,
+		ResolutionFHD: 0.05,
+		ResolutionUHD: 0.03,
+	}
+}
+
+// AudioAction is what an audio rule does with a matching stream
+type AudioAction string
+
+const (
+	AudioCopy      AudioAction = "copy"      // Pass the stream through untouched
+	AudioTranscode AudioAction = "transcode" // Re-encode with AudioCodec at AudioBitratePerPair
+)
+
+// AudioRule matches audio streams by codec and channel count
+// Rules are checked in order and the first match wins; unmatched streams are copied
+type AudioRule struct {
+	Codecs      []string // ffprobe codec names, "pcm_*" matches by prefix; empty matches any codec
+	MinChannels int      // 0 = no minimum
+	MaxChannels int      // 0 = no maximum
+	Action      AudioAction
+}
+
+// String describes the rule for logs, e.g. "dts/truehd/flac → transcode"
+func (r AudioRule) String() string {
+	codecs := "any codec"
+	if len(r.Codecs) > 0 {
+		codecs = strings.Join(r.Codecs, "/")
+	}
+	if r.MinChannels > 0 || r.MaxChannels > 0 {
+		codecs += fmt.Sprintf(" %d-%dch", r.MinChannels, r.MaxChannels)
+	}
+	return codecs + " → " + string(r.Action)
+}
+
+// DefaultAudioRules keeps efficient lossy tracks and shrinks lossless or DTS tracks to Opus
+// They are opt-in (-audio=rules) since they turn lossless tracks lossy
+func DefaultAudioRules() []AudioRule {
+	return []AudioRule{
+		{Codecs: []string{"aac", "opus", "vorbis", "mp3", "ac3", "eac3"}, Action: AudioCopy},
+		{Codecs: []string{"dts", "truehd", "mlp", "flac", "alac", "pcm_*"}, Action: AudioTranscode},
+	}
+}
+
 // AvailableProfiles returns all available profile names
 func AvailableProfiles() []Profile {
 	return []Profile{ProfileDefault, ProfileQuality, ProfilePodcast, ProfileCompress, ProfileExtreme, ProfileFilm}
@@ -54,14 +232,142 @@ type Config struct {
 	// FilmGrain denoising level (0=off, 1-50=level)
 	// SVT-AV1-HDR default: 0 (disabled, as it often harms visual fidelity)
 	FilmGrain int
+	// FilmGrainDenoise sets film-grain-denoise when FilmGrain is on (-1 = SVT-AV1 default, 0 = keep source, 1 = denoise)
+	FilmGrainDenoise int
+	// PreFilters run in order on the final frame size (after deinterlace, crop and scale) before encoding
+	// nil uses the profile's filters; an empty list disables them
+	PreFilters []VideoFilter
+	// GrainMode estimates the source's noise to suggest or apply FilmGrain and FilmGrainDenoise
+	GrainMode GrainMode
+	// AutoRules map content facts to a built-in profile when ProfileName is ProfileAuto
+	AutoRules []AutoRule
 	// MaxSizePercent is the maximum output size as percentage of input (0 = disabled)
 	MaxSizePercent int
+	// ExpectedSizePercent is a deliberately high guess at the output size as a percentage of the
+	// input, used by the disk space preflight
+	ExpectedSizePercent int
+	// CheckDiskSpace refuses to start when the output filesystem can't hold the estimated output
+	// plus FreeSpaceMargin
+	CheckDiskSpace bool
+	// FreeSpaceMargin is the free space in bytes the preflight requires on top of the estimate
+	FreeSpaceMargin int64
+	// StallTimeout is how long an encode may go without its frame count or output time advancing
+	// before it is treated as hung (0 = no stall watchdog)
+	StallTimeout time.Duration
+	// StallAction is what happens when the stall timeout is reached
+	StallAction StallAction
+	// StallRestarts caps restarts in restart mode; a job still stalling after that is cancelled
+	StallRestarts int
+	// MaxRetries is how many times a failed encode is retried with fallback settings chosen from
+	// the classified error (0 = fail on the first error)
+	MaxRetries int
+	// MinFreeSpace stops a running encode when free space on the output filesystem drops below it
+	// (bytes, 0 = no watchdog)
+	MinFreeSpace int64
 	// RemoveLanguages is a list of language codes to remove from streams
 	RemoveLanguages []string
+	// KeepLanguages limits audio and subtitle tracks to these languages (empty = keep all)
+	// "und" also matches untagged tracks; the last audio track is never removed
+	KeepLanguages []string
+	// PreferredLanguage is flagged as the default audio in the output (empty = keep source flags)
+	// When no audio track has it, its subtitles are flagged default instead
+	PreferredLanguage string
+	// DropCommentary removes commentary tracks, detected from dispositions and titles
+	DropCommentary bool
+	// DropDescriptiveAudio removes audio description tracks for the visually impaired
+	DropDescriptiveAudio bool
+	// Container is the output format; text subtitles are converted to its native format
+	Container Container
+	// SubtitleMode selects which subtitle tracks are kept
+	SubtitleMode SubtitleMode
+	// ExtractSubtitles also writes each kept subtitle track to a sidecar .srt/.ass/.sup file
+	ExtractSubtitles bool
 	// RemoveImageCodecs is a list of image codecs to remove (e.g., mjpeg, png)
+	// Cover art is handled by KeepCoverArt instead
 	RemoveImageCodecs []string
-	// MinBitrate is the minimum source bitrate in kbps to allow encoding (0 = disabled)
-	MinBitrate int
+	// KeepCoverArt re-attaches embedded cover pictures as Matroska attachments (mkv only)
+	KeepCoverArt bool
+	// KeepChapters copies chapter markers from the source
+	KeepChapters bool
+	// KeepMetadata copies global container tags (title etc.); per-stream tags are always kept
+	KeepMetadata bool
+	// MinBitsPerPixel skips sources whose video bitrate per pixel per frame is below the threshold
+	// for their resolution class; already-starved sources only lose quality when re-encoded
+	// Missing or 0 entries disable the check for that class
+	MinBitsPerPixel map[ResolutionClass]float64
+	// AudioRules decide per audio stream whether to copy or transcode (nil = copy everything)
+	AudioRules []AudioRule
+	// AudioCodec is the encoder used for transcoded audio
+	AudioCodec string
+	// AudioBitratePerPair is the transcode bitrate in kbps per channel pair (5.1 = 3 pairs)
+	AudioBitratePerPair int
+	// KeepLosslessOriginal also copies the first transcoded lossless track untouched
+	KeepLosslessOriginal bool
+	// GOPSeconds is the maximum keyframe interval in seconds, converted to frames from the source frame rate
+	GOPSeconds float64
+	// KeyframeMode selects fixed GOPs from ffmpeg or SVT-AV1's own keyint/scene-change handling
+	KeyframeMode KeyframeMode
+	// ChapterKeyframes forces a keyframe at every chapter start so chapter skips are instant
+	ChapterKeyframes bool
+	// FrameRateMode decides whether variable frame rate sources keep their timestamps or become constant
+	FrameRateMode FrameRateMode
+	// TargetFrameRate is the constant rate in cfr mode, e.g. "30" or "30000/1001"
+	// "" picks the standard rate nearest the source's average; CFR sources are only converted when it is set
+	TargetFrameRate string
+	// SDRCompanion queues a second, tonemapped BT.709 encode after the main one for HDR sources
+	SDRCompanion bool
+	// SDRProfile is the profile for the companion encode ("" = same as the main encode)
+	SDRProfile Profile
+	// ToneMapper is the tonemapping curve for the companion encode
+	ToneMapper ToneMapper
+	// SDRSuffix is inserted before .av1.<container> in the companion's file name
+	SDRSuffix string
+	// PreviewClips is how many sample clips -preview encodes, spread evenly through the source
+	PreviewClips int
+	// PreviewSeconds is the length of each preview clip
+	PreviewSeconds float64
+	// ContactSheet renders a tiled image of evenly spaced output frames after the encode
+	ContactSheet bool
+	// ContactSheetFrames is the number of thumbnails on the contact sheet
+	ContactSheetFrames int
+	// ContactSheetColumns is how many thumbnails each row of the contact sheet holds
+	ContactSheetColumns int
+	// ContactSheetFormat is the image format of the contact sheet: jpg or png
+	ContactSheetFormat string
+	// CropMode enables black-bar detection before encoding
+	// Content whose aspect ratio changes (e.g. IMAX sequences) is never cropped
+	CropMode CropMode
+	// DeinterlaceMode enables interlace/telecine detection before encoding; auto costs an idet pass
+	// over a minute of sampled video during Prepare, skipped for streams flagged progressive
+	DeinterlaceMode DeinterlaceMode
+	// Deinterlacer is the filter used for interlaced sources and leftover combing after field matching
+	Deinterlacer Deinterlacer
+	// MaxWidth and MaxHeight cap the output resolution (0 = no cap)
+	// Larger sources are downscaled keeping their aspect ratio, with mod-8 dimensions
+	MaxWidth  int
+	MaxHeight int
+	// Scaler is the resize filter used when the resolution cap applies
+	Scaler Scaler
+	// WriteProvenance tags the output with the tool version, settings, SVT-AV1 build and source fingerprint
+	WriteProvenance bool
+	// SkipEncoded skips inputs whose provenance tags show this tool already produced them
+	SkipEncoded bool
+	// VerifyOutput checks the output's duration, streams and chapters against the source and decodes
+	// its start, middle and end
+	VerifyOutput bool
+	// VerifyDecode makes verification decode every frame, catching corrupt frames anywhere and checking
+	// the frame count; it takes about as long as decoding the whole output
+	VerifyDecode bool
+	// QualityMetrics lists objective metrics to measure after encoding (vmaf, ssim, psnr)
+	// Empty disables the post-encode quality pass
+	QualityMetrics []string
+	// QualitySampleStride compares every Nth frame during the quality pass (1 = every frame)
+	QualitySampleStride int
+}
+
+// QualityMetricNames returns the metrics supported by the quality pass
+func QualityMetricNames() []string {
+	return []string{"vmaf", "ssim", "psnr"}
 }
 
 // DefaultConfig returns the SVT-AV1-HDR standard defaults (balanced profile)
@@ -83,10 +389,62 @@ func GetProfile(profile Profile) Config {
 		ACBias:                1.0,
 		SharpTX:               true,
 		FilmGrain:             0,
+		FilmGrainDenoise:      -1,
+		GrainMode:             GrainOff,
 		MaxSizePercent:        0,
+		ExpectedSizePercent:   60,
+		CheckDiskSpace:        true,
+		FreeSpaceMargin:       1 << 30,
+		MinFreeSpace:          512 << 20,
+		MaxRetries:            2,
+		StallTimeout:          5 * time.Minute,
+		StallAction:           StallWarn,
+		StallRestarts:         2,
+		AutoRules:             DefaultAutoRules(),
 		RemoveLanguages:       []string{},
+		KeepLanguages:         []string{},
+		PreferredLanguage:     "",
+		DropCommentary:        false,
+		DropDescriptiveAudio:  false,
+		Container:             ContainerMKV,
+		SubtitleMode:          SubtitlesAll,
+		ExtractSubtitles:      false,
 		RemoveImageCodecs:     []string{"mjpeg", "png"},
-		MinBitrate:            0,
+		KeepCoverArt:          true,
+		KeepChapters:          true,
+		KeepMetadata:          true,
+		MinBitsPerPixel:       map[ResolutionClass]float64{},
+		AudioRules:            nil,
+		AudioCodec:            "libopus",
+		AudioBitratePerPair:   96,
+		KeepLosslessOriginal:  false,
+		GOPSeconds:            10,
+		KeyframeMode:          KeyframesFixed,
+		ChapterKeyframes:      false,
+		FrameRateMode:         FrameRatePreserve,
+		TargetFrameRate:       "",
+		SDRCompanion:          false,
+		SDRProfile:            "",
+		ToneMapper:            ToneMapHable,
+		SDRSuffix:             ".sdr",
+		PreviewClips:          5,
+		PreviewSeconds:        20,
+		ContactSheet:          false,
+		ContactSheetFrames:    16,
+		ContactSheetColumns:   4,
+		ContactSheetFormat:    "jpg",
+		CropMode:              CropOff,
+		DeinterlaceMode:       DeinterlaceAuto,
+		Deinterlacer:          DeinterlacerBwdif,
+		MaxWidth:              0,
+		MaxHeight:             0,
+		Scaler:                ScalerLanczos,
+		WriteProvenance:       true,
+		SkipEncoded:           true,
+		VerifyOutput:          true,
+		VerifyDecode:          false,
+		QualityMetrics:        []string{},
+		QualitySampleStride:   10,
 	}
 
 	switch profile {
@@ -95,6 +453,7 @@ func GetProfile(profile Profile) Config {
 		base.CRF = 30
 		base.Preset = 3 // Slower for better quality
 		base.VarianceBoostStrength = 3
+		base.ExpectedSizePercent = 80
 
 	case ProfilePodcast:
 		// Optimized for talking heads, podcasts, interviews, lectures
@@ -102,6 +461,9 @@ func GetProfile(profile Profile) Config {
 		base.CRF = 40
 		base.Preset = 5 // Faster since content is simple
 		base.VarianceBoostStrength = 1
+		base.MaxWidth = 1920 // Talking heads gain nothing from 4K
+		base.MaxHeight = 1080
+		base.ExpectedSizePercent = 40
 
 	case ProfileCompress:
 		// Maximum compression - for archiving or storage constrained situations
@@ -109,6 +471,11 @@ func GetProfile(profile Profile) Config {
 		base.Preset = 6
 		base.VarianceBoostStrength = 1
 		base.Sharpness = 0
+		base.MaxWidth = 1920 // Cap at 1080p to save space
+		base.MaxHeight = 1080
+		base.ExpectedSizePercent = 35
+		// Light denoise: noise costs the most bits at high CRF
+		base.PreFilters = []VideoFilter{{Name: "hqdn3d", Params: "2:1.5:3:2.25"}}
 
 	case ProfileExtreme:
 		// EXTREME compression - smallest possible files, significant quality loss
@@ -121,6 +488,9 @@ func GetProfile(profile Profile) Config {
 		base.Sharpness = 0         // No sharpness processing
 		base.TFStrength = 2        // More temporal filtering (reduces noise/detail)
 		base.FilmGrain = 10        // Denoise to reduce detail that costs bits
+		base.ExpectedSizePercent = 25
+		// Strong denoise, then deband the flattened gradients before the encoder quantizes them
+		base.PreFilters = []VideoFilter{{Name: "hqdn3d", Params: "4:3:6:4.5"}, {Name: "deband"}}
 
 	case ProfileFilm:
 		// For movies and cinematic content
@@ -128,7 +498,9 @@ func GetProfile(profile Profile) Config {
 		base.Preset = 2  // Slower for quality
 		base.Tune = 0    // VQ tuning for visual quality
 		base.FilmGrain = 8 // Preserve film grain
+		base.GrainMode = GrainSuggest
 		base.VarianceBoostStrength = 3
+		base.ExpectedSizePercent = 70
 
 	default: // ProfileDefault
 		// Balanced quality/size - good for general content
@@ -145,13 +517,15 @@ func ProfileDescription(profile Profile) string {
 	case ProfileQuality:
 		return "High quality (CRF 30) - For important content, larger files"
 	case ProfilePodcast:
-		return "Podcast/Talking heads (CRF 40) - Optimized compression for low-motion content"
+		return "Podcast/Talking heads (CRF 40, max 1080p) - Optimized compression for low-motion content"
 	case ProfileCompress:
-		return "Maximum compression (CRF 45) - Smallest files, some quality loss"
+		return "Maximum compression (CRF 45, max 1080p) - Smallest files, some quality loss"
 	case ProfileExtreme:
 		return "EXTREME compression (CRF 55) - Tiny files, significant quality loss"
 	case ProfileFilm:
 		return "Film/Cinema (CRF 32) - Preserves film grain, high quality"
+	case ProfileAuto:
+		return "Automatic - Picks a profile from motion, grain, animation, resolution, HDR and bitrate"
 	default:
 		return "Default balanced (CRF 35) - Good quality/size balance for general content"
 	}
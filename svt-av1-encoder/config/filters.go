@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// VideoFilter is one pre-encode filter from a profile or -filters, e.g. hqdn3d=2:1.5:3:3
+type VideoFilter struct {
+	Name   string // ffmpeg filter name, one of PreFilterNames
+	Params string // ffmpeg option string ("a:b" or "key=value:key=value"), "" for the filter's defaults
+}
+
+// String renders the filter in ffmpeg syntax
+func (f VideoFilter) String() string {
+	if f.Params == "" {
+		return f.Name
+	}
+	return f.Name + "=" + f.Params
+}
+
+// filterSpec lists the options a pre-filter accepts
+type filterSpec struct {
+	options    []string // Named options
+	positional int      // Maximum number of unnamed options
+	numeric    bool     // Option values must be numbers
+}
+
+// preFilters are the filters allowed in a pre-filter list; anything else could change
+// frame timing or geometry behind the stream plan's back
+var preFilters = map[string]filterSpec{
+	// Fast spatial/temporal denoiser
+	"hqdn3d": {options: []string{"luma_spatial", "chroma_spatial", "luma_tmp", "chroma_tmp"}, positional: 4, numeric: true},
+	// Slow, high-quality non-local means denoiser
+	"nlmeans": {options: []string{"s", "p", "pc", "r", "rc"}, positional: 5, numeric: true},
+	// Smooths banding in gradients before the encoder locks it in
+	"deband": {options: []string{"1thr", "2thr", "3thr", "4thr", "range", "r", "direction", "d", "blur", "b", "coupling", "c"}, numeric: true},
+	// Sharpen (positive amounts) or blur (negative amounts)
+	"unsharp": {options: []string{"luma_msize_x", "lx", "luma_msize_y", "ly", "luma_amount", "la",
+		"chroma_msize_x", "cx", "chroma_msize_y", "cy", "chroma_amount", "ca"}, positional: 6, numeric: true},
+	// Pixel format conversion, e.g. format=yuv420p10le
+	"format": {options: []string{"pix_fmts"}, positional: 1},
+}
+
+// PreFilterNames lists the filters accepted in pre-filter lists, in the order they usually belong
+var PreFilterNames = []string{"hqdn3d", "nlmeans", "deband", "unsharp", "format"}
+
+// ParseFilters reads a comma-separated filter list such as "hqdn3d=2:1.5:3:3,deband"
+// "none" or "" returns an empty, non-nil list so it overrides the profile's filters
+func ParseFilters(text string) ([]VideoFilter, error) {
+	filters := []VideoFilter{}
+	text = strings.TrimSpace(text)
+	if text == "" || strings.EqualFold(text, "none") {
+		return filters, nil
+	}
+	for _, part := range strings.Split(text, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), "=")
+		filters = append(filters, VideoFilter{Name: strings.ToLower(strings.TrimSpace(name)), Params: strings.TrimSpace(params)})
+	}
+	if err := ValidateFilters(filters); err != nil {
+		return nil, err
+	}
+	return filters, nil
+}
+
+// ValidateFilters checks names and options so mistakes surface before the encode starts
+func ValidateFilters(filters []VideoFilter) error {
+	for _, f := range filters {
+		spec, ok := preFilters[f.Name]
+		if !ok {
+			return fmt.Errorf("unknown filter '%s' (use %s)", f.Name, strings.Join(PreFilterNames, ", "))
+		}
+		if f.Params == "" {
+			if f.Name == "format" {
+				return fmt.Errorf("filter 'format' needs a pixel format, e.g. format=yuv420p10le")
+			}
+			continue
+		}
+		// Characters that would splice extra filters or labels into the graph
+		if strings.ContainsAny(f.Params, ",;[]'\"") {
+			return fmt.Errorf("filter '%s': invalid options '%s'", f.Name, f.Params)
+		}
+
+		positional := 0
+		for _, opt := range strings.Split(f.Params, ":") {
+			key, value, named := strings.Cut(opt, "=")
+			if !named {
+				value = key
+				positional++
+				if positional > spec.positional {
+					return fmt.Errorf("filter '%s': too many options in '%s'", f.Name, f.Params)
+				}
+			} else if !containsString(spec.options, key) {
+				return fmt.Errorf("filter '%s': unknown option '%s'", f.Name, key)
+			}
+			if value == "" {
+				return fmt.Errorf("filter '%s': empty value in '%s'", f.Name, f.Params)
+			}
+			if _, err := strconv.ParseFloat(value, 64); spec.numeric && err != nil {
+				return fmt.Errorf("filter '%s': option value '%s' is not a number", f.Name, value)
+			}
+		}
+	}
+	return nil
+}
+
+// FiltersString renders a filter list in ffmpeg syntax, "none" when empty
+func FiltersString(filters []VideoFilter) string {
+	if len(filters) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(filters))
+	for i, f := range filters {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
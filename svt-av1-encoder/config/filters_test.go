@@ -0,0 +1,51 @@
+package config
+
+import "testing"
+
+func TestParseFilters(t *testing.T) {
+	filters, err := ParseFilters("hqdn3d=2:1.5:3:3, nlmeans=s=3:p=7 ,deband,unsharp=la=0.5,format=yuv420p10le")
+	if err != nil {
+		t.Fatalf("ParseFilters error: %v", err)
+	}
+	if got := FiltersString(filters); got != "hqdn3d=2:1.5:3:3,nlmeans=s=3:p=7,deband,unsharp=la=0.5,format=yuv420p10le" {
+		t.Errorf("FiltersString = %q", got)
+	}
+
+	none, err := ParseFilters("none")
+	if err != nil || none == nil || len(none) != 0 {
+		t.Errorf("ParseFilters(none) = %v, %v; want an empty non-nil list", none, err)
+	}
+
+	for _, bad := range []string{
+		"eq=contrast=2",     // not a pre-filter
+		"hqdn3d=1:2:3:4:5",  // too many positional options
+		"hqdn3d=strong",     // not a number
+		"nlmeans=sigma=3",   // unknown option
+		"format",            // needs a pixel format
+		"unsharp=la=1[out]", // graph syntax
+		"deband=1thr=",      // empty value
+	} {
+		if _, err := ParseFilters(bad); err == nil {
+			t.Errorf("ParseFilters(%q) should fail", bad)
+		}
+	}
+}
+
+func TestProfileFiltersValid(t *testing.T) {
+	for _, p := range AvailableProfiles() {
+		if err := ValidateFilters(GetProfile(p).PreFilters); err != nil {
+			t.Errorf("profile %s: %v", p, err)
+		}
+	}
+}
+
+func TestWithProfileKeepsExplicitFilters(t *testing.T) {
+	auto := GetProfile(ProfileAuto)
+	if got := auto.WithProfile(ProfileExtreme).PreFilters; len(got) == 0 {
+		t.Errorf("auto → extreme should take the profile's filters")
+	}
+	auto.PreFilters = []VideoFilter{}
+	if got := auto.WithProfile(ProfileExtreme).PreFilters; len(got) != 0 {
+		t.Errorf("explicit empty filter list was replaced with %v", got)
+	}
+}
@@ -0,0 +1,316 @@
+package encoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+// CompareResult is one profile's outcome on the shared sample clips
+type CompareResult struct {
+	Profile  config.Profile
+	CRF      int
+	Preset   int
+	Size     int64         // Total bytes of all clips
+	Duration time.Duration // Total media time of all clips
+	Frames   int64         // Frames encoded
+	Elapsed  time.Duration // Wall time spent encoding (quality passes excluded)
+	Scores   []QualityScore
+}
+
+// Bitrate is the clips' overall bitrate in bits/s
+func (r CompareResult) Bitrate() int64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return int64(float64(r.Size*8) / r.Duration.Seconds())
+}
+
+// FPS is the encode speed in frames per second
+func (r CompareResult) FPS() float64 {
+	if r.Elapsed <= 0 {
+		return 0
+	}
+	return float64(r.Frames) / r.Elapsed.Seconds()
+}
+
+// Score returns the mean of a metric, false when it wasn't measured
+func (r CompareResult) Score(metric string) (float64, bool) {
+	for _, s := range r.Scores {
+		if s.Metric == metric && s.Frames > 0 {
+			return s.Mean, true
+		}
+	}
+	return 0, false
+}
+
+// CompareReport is the table of a comparison run
+type CompareReport struct {
+	Source       string
+	Clips        int
+	ClipSeconds  float64
+	Metrics      []string
+	Results      []CompareResult
+	MarkdownPath string
+	CSVPath      string
+}
+
+// header returns the table columns, one per metric after the fixed ones
+func (r *CompareReport) header() []string {
+	header := []string{"Profile", "CRF", "Preset", "Size", "Bitrate", "Encode FPS"}
+	for _, m := range r.Metrics {
+		header = append(header, strings.ToUpper(m))
+	}
+	return header
+}
+
+// Rows returns the table formatted for display, header first
+func (r *CompareReport) Rows() [][]string {
+	rows := [][]string{r.header()}
+	for _, res := range r.Results {
+		row := []string{
+			string(res.Profile),
+			strconv.Itoa(res.CRF),
+			strconv.Itoa(res.Preset),
+			formatSize(res.Size),
+			formatBitrate(res.Bitrate()),
+			fmt.Sprintf("%.1f", res.FPS()),
+		}
+		for _, m := range r.Metrics {
+			if v, ok := res.Score(m); ok {
+				row = append(row, formatMetric(m, v))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// formatMetric shows SSIM (0-1) with more precision than VMAF/PSNR
+func formatMetric(metric string, v float64) string {
+	if metric == "ssim" {
+		return fmt.Sprintf("%.4f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
+// Markdown renders the report as a Markdown table with a short heading
+func (r *CompareReport) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Profile comparison: %s\n\n", filepath.Base(r.Source))
+	fmt.Fprintf(&b, "%d clips of %gs each, spread through the source.\n\n", r.Clips, r.ClipSeconds)
+	for i, row := range r.Rows() {
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+		if i == 0 {
+			b.WriteString("|" + strings.Repeat(" --- |", len(row)) + "\n")
+		}
+	}
+	return b.String()
+}
+
+// CSV renders the report with raw numbers (bytes, bits/s) for spreadsheets
+func (r *CompareReport) CSV() string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"profile", "crf", "preset", "size_bytes", "bitrate_bps", "encode_fps"}
+	header = append(header, r.Metrics...)
+	w.Write(header)
+	for _, res := range r.Results {
+		row := []string{
+			string(res.Profile),
+			strconv.Itoa(res.CRF),
+			strconv.Itoa(res.Preset),
+			strconv.FormatInt(res.Size, 10),
+			strconv.FormatInt(res.Bitrate(), 10),
+			strconv.FormatFloat(res.FPS(), 'f', 2, 64),
+		}
+		for _, m := range r.Metrics {
+			v, ok := res.Score(m)
+			if ok {
+				row = append(row, strconv.FormatFloat(v, 'f', 4, 64))
+			} else {
+				row = append(row, "")
+			}
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// Write saves the Markdown and CSV tables next to the source (movie.compare.md, movie.compare.csv)
+func (r *CompareReport) Write() error {
+	base := strings.TrimSuffix(r.Source, filepath.Ext(r.Source)) + ".compare"
+	if err := os.WriteFile(base+".md", []byte(r.Markdown()), 0o644); err != nil {
+		return err
+	}
+	r.MarkdownPath = base + ".md"
+	if err := os.WriteFile(base+".csv", []byte(r.CSV()), 0o644); err != nil {
+		return err
+	}
+	r.CSVPath = base + ".csv"
+	return nil
+}
+
+// Comparison encodes the same sample clips with several profiles and measures each
+type Comparison struct {
+	InputPath string
+	Config    config.Config // Shared settings; each profile is applied on top with WithProfile
+	Profiles  []config.Profile
+	Source    *MediaInfo
+
+	workDir string
+	mu      sync.Mutex // Protects current
+	current *Encoder
+	stopped bool
+}
+
+// NewComparison creates a comparison run; metrics come from cfg.QualityMetrics
+func NewComparison(inputPath string, cfg config.Config, profiles []config.Profile) *Comparison {
+	return &Comparison{InputPath: inputPath, Config: cfg, Profiles: profiles}
+}
+
+// Probe reads the source once for all profiles and creates the work dir for clips and logs
+func (c *Comparison) Probe() error {
+	info, err := ProbeFile(context.Background(), c.InputPath)
+	if err != nil {
+		return err
+	}
+	if info.Duration <= 0 {
+		return fmt.Errorf("comparison needs a source with a known duration")
+	}
+	c.Source = info
+	c.workDir, err = os.MkdirTemp("", "svtav1-compare-")
+	if err != nil {
+		return fmt.Errorf("failed to create comparison work dir: %w", err)
+	}
+	return nil
+}
+
+// profileConfig applies p on top of the shared settings, without the extras that don't
+// change the encoded clips
+func (c *Comparison) profileConfig(p config.Profile) config.Config {
+	cfg := c.Config.WithProfile(p)
+	cfg.WriteProvenance = false
+	cfg.SDRCompanion = false
+	cfg.ExtractSubtitles = false
+	if cfg.GrainMode == config.GrainSuggest {
+		cfg.GrainMode = config.GrainOff
+	}
+	return cfg
+}
+
+// RunProfile encodes and measures every clip with one profile
+func (c *Comparison) RunProfile(p config.Profile) (*CompareResult, error) {
+	enc := New(c.InputPath, c.profileConfig(p))
+	enc.Source = c.Source
+
+	c.mu.Lock()
+	if c.stopped {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("comparison stopped")
+	}
+	c.current = enc
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.current = nil
+		c.mu.Unlock()
+		// Release the encoder's context; nothing else cancels it once the profile is done
+		enc.cancel()
+	}()
+
+	if _, err := enc.Prepare(); err != nil {
+		return nil, err
+	}
+
+	length := time.Duration(enc.Config.PreviewSeconds * float64(time.Second))
+	stride := max(1, enc.Config.QualitySampleStride)
+	metrics := enc.Config.QualityMetrics
+	result := CompareResult{Profile: p, CRF: enc.Config.CRF, Preset: enc.Config.Preset}
+	frames := make(map[string][]frameScore)
+
+	for i, start := range previewStarts(c.Source.Duration, length, enc.Config.PreviewClips) {
+		clip := filepath.Join(c.workDir, fmt.Sprintf("%s-%d.mkv", p, i+1))
+
+		var progress bytes.Buffer
+		began := time.Now()
+		args := append([]string{"-progress", "pipe:1"}, enc.previewArgs(start, length, clip)...)
+		if _, err := enc.runFFmpeg("", &progress, args...); err != nil {
+			return nil, fmt.Errorf("%s clip %d: %w", p, i+1, err)
+		}
+		result.Elapsed += time.Since(began)
+		result.Frames += lastProgressValue(progress.String(), "frame")
+
+		info, err := os.Stat(clip)
+		if err != nil {
+			return nil, fmt.Errorf("%s clip %d: %w", p, i+1, err)
+		}
+		result.Size += info.Size()
+		clipDuration := length
+		if probed, err := ProbeFile(enc.ctx, clip); err == nil && probed.Duration > 0 {
+			clipDuration = probed.Duration
+		}
+		result.Duration += clipDuration
+
+		if len(metrics) > 0 {
+			reference := []string{
+				"-ss", fmt.Sprintf("%.3f", start.Seconds()),
+				"-t", fmt.Sprintf("%.3f", clipDuration.Seconds()),
+				"-i", c.InputPath,
+			}
+			scores, err := enc.measureFrames(c.workDir, []string{"-i", clip}, reference, metrics, stride)
+			if err != nil {
+				return nil, fmt.Errorf("%s clip %d: %w", p, i+1, err)
+			}
+			for metric, s := range scores {
+				frames[metric] = append(frames[metric], s...)
+			}
+		}
+		os.Remove(clip)
+	}
+
+	for _, metric := range metrics {
+		result.Scores = append(result.Scores, summarizeScores(metric, frames[metric], stride))
+	}
+	return &result, nil
+}
+
+// Report builds the table for the given results
+func (c *Comparison) Report(results []CompareResult) *CompareReport {
+	return &CompareReport{
+		Source:      c.InputPath,
+		Clips:       c.Config.PreviewClips,
+		ClipSeconds: c.Config.PreviewSeconds,
+		Metrics:     c.Config.QualityMetrics,
+		Results:     results,
+	}
+}
+
+// Stop cancels the running profile and prevents further ones from starting
+func (c *Comparison) Stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopped = true
+	if c.current != nil {
+		c.current.Stop()
+	}
+}
+
+// Close removes the work dir with any leftover clips and logs
+func (c *Comparison) Close() {
+	if c.workDir != "" {
+		os.RemoveAll(c.workDir)
+	}
+}
@@ -0,0 +1,89 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func sampleCompareReport() *CompareReport {
+	return &CompareReport{
+		Source:      "/media/movie.mkv",
+		Clips:       5,
+		ClipSeconds: 20,
+		Metrics:     []string{"vmaf", "ssim"},
+		Results: []CompareResult{
+			{Profile: config.ProfileQuality, CRF: 30, Preset: 3, Size: 50 << 20, Duration: 100 * time.Second,
+				Frames: 2400, Elapsed: 200 * time.Second,
+				Scores: []QualityScore{{Metric: "vmaf", Mean: 96.13, Frames: 480}, {Metric: "ssim", Mean: 0.98765, Frames: 480}}},
+			{Profile: config.ProfileCompress, CRF: 45, Preset: 6, Size: 10 << 20, Duration: 100 * time.Second,
+				Frames: 2400, Elapsed: 40 * time.Second,
+				Scores: []QualityScore{{Metric: "vmaf", Mean: 88.5, Frames: 480}}},
+		},
+	}
+}
+
+func TestCompareReportRows(t *testing.T) {
+	rows := sampleCompareReport().Rows()
+	if strings.Join(rows[0], ",") != "Profile,CRF,Preset,Size,Bitrate,Encode FPS,VMAF,SSIM" {
+		t.Errorf("header = %v", rows[0])
+	}
+	if strings.Join(rows[1], ",") != "quality,30,3,50.0 MiB,4.19 Mbps,12.0,96.13,0.9877" {
+		t.Errorf("quality row = %v", rows[1])
+	}
+	// A metric that produced no frames shows as missing rather than 0
+	if rows[2][5] != "60.0" || rows[2][7] != "-" {
+		t.Errorf("compress row = %v", rows[2])
+	}
+}
+
+func TestCompareReportFormats(t *testing.T) {
+	r := sampleCompareReport()
+
+	md := r.Markdown()
+	if !strings.Contains(md, "# Profile comparison: movie.mkv") ||
+		!strings.Contains(md, "| --- | --- | --- | --- | --- | --- | --- | --- |") ||
+		!strings.Contains(md, "| compress | 45 | 6 |") {
+		t.Errorf("markdown:\n%s", md)
+	}
+
+	lines := strings.Split(strings.TrimSpace(r.CSV()), "\n")
+	if len(lines) != 3 || lines[0] != "profile,crf,preset,size_bytes,bitrate_bps,encode_fps,vmaf,ssim" {
+		t.Fatalf("csv:\n%s", r.CSV())
+	}
+	if lines[2] != "compress,45,6,10485760,838860,60.00,88.5000," {
+		t.Errorf("csv row = %s", lines[2])
+	}
+}
+
+func TestComparisonProfileConfig(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SDRCompanion = true
+	c := NewComparison("/media/movie.mkv", cfg, nil)
+
+	film := c.profileConfig(config.ProfileFilm)
+	if film.ProfileName != config.ProfileFilm || film.CRF != 32 {
+		t.Errorf("film config = %+v", film)
+	}
+	if film.WriteProvenance || film.SDRCompanion || film.GrainMode != config.GrainOff {
+		t.Error("comparison clips should skip provenance, SDR companions and grain suggestions")
+	}
+}
+
+func TestRunProfile_ReleasesEncoder(t *testing.T) {
+	c := NewComparison("/nonexistent/movie.mkv", config.DefaultConfig(), nil)
+	c.Source = &MediaInfo{Duration: time.Minute, Streams: []StreamInfo{{Index: 0, CodecType: "video", CodecName: "hevc"}}}
+	c.workDir = t.TempDir()
+
+	// The clip encode fails on the missing input; the encoder must still be let go
+	if _, err := c.RunProfile(config.ProfileFilm); err == nil {
+		t.Fatal("expected the profile to fail")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.current != nil {
+		t.Error("current should be cleared once the profile returns")
+	}
+}
@@ -0,0 +1,90 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// contactSheetTileWidth is the width of each thumbnail; height follows the output's aspect ratio
+const contactSheetTileWidth = 480
+
+// ContactSheetPath is where the contact sheet of an output goes (movie.av1.mkv -> movie.av1.sheet.jpg)
+func ContactSheetPath(outputPath, format string) string {
+	return strings.TrimSuffix(FramesDir(outputPath), ".frames") + ".sheet." + format
+}
+
+// sheetTimes spreads n timestamps evenly through duration, each centred on its share so
+// the first and last tiles avoid black fades at the very start and end
+func sheetTimes(duration time.Duration, n int) []time.Duration {
+	if n <= 0 || duration <= 0 {
+		return nil
+	}
+	times := make([]time.Duration, n)
+	for i := range times {
+		times[i] = time.Duration(float64(duration) * (float64(i) + 0.5) / float64(n))
+	}
+	return times
+}
+
+// drawTimestamp labels a thumbnail with its position; colons are escaped for drawtext
+func drawTimestamp(at time.Duration) string {
+	text := strings.ReplaceAll(formatTimestamp(at), ":", `\:`)
+	return fmt.Sprintf("drawtext=text='%s':x=8:y=h-th-8:fontsize=20:fontcolor=white:box=1:boxcolor=black@0.6:boxborderw=4", text)
+}
+
+// contactSheetGraph builds the filter graph for one input per timestamp: each input keeps its
+// first frame, is scaled down and labelled, then the thumbnails are tiled into one image
+func contactSheetGraph(times []time.Duration, columns int) string {
+	rows := (len(times) + columns - 1) / columns
+	var parts []string
+	var labels strings.Builder
+	for i, at := range times {
+		parts = append(parts, fmt.Sprintf("[%d:v]trim=end_frame=1,scale=%d:-2,setsar=1,%s[t%d]",
+			i, contactSheetTileWidth, drawTimestamp(at), i))
+		fmt.Fprintf(&labels, "[t%d]", i)
+	}
+	parts = append(parts, fmt.Sprintf("%sconcat=n=%d:v=1:a=0,tile=%dx%d:padding=4:margin=4",
+		labels.String(), len(times), columns, rows))
+	return strings.Join(parts, ";")
+}
+
+// contactSheetArgs seeks to each timestamp separately, so only N frames are decoded
+// instead of the whole output
+func (e *Encoder) contactSheetArgs(times []time.Duration, path string) []string {
+	var args []string
+	for _, at := range times {
+		args = append(args, "-ss", fmt.Sprintf("%.3f", at.Seconds()), "-i", e.OutputPath)
+	}
+	args = append(args,
+		"-filter_complex", contactSheetGraph(times, max(1, e.Config.ContactSheetColumns)),
+		"-frames:v", "1",
+	)
+	if e.Config.ContactSheetFormat == "jpg" {
+		args = append(args, "-q:v", "3")
+	}
+	return append(args, "-y", path)
+}
+
+// ContactSheet renders a tiled image of evenly spaced output frames with timestamps,
+// next to the output, and returns its path
+func (e *Encoder) ContactSheet() (string, error) {
+	output, err := ProbeFile(e.ctx, e.OutputPath)
+	if err != nil {
+		return "", fmt.Errorf("cannot read output: %w", err)
+	}
+	if output.Duration <= 0 {
+		return "", fmt.Errorf("contact sheet needs an output with a known duration")
+	}
+	times := sheetTimes(output.Duration, e.Config.ContactSheetFrames)
+	if len(times) == 0 {
+		return "", fmt.Errorf("contact sheet needs at least one frame")
+	}
+
+	path := ContactSheetPath(e.OutputPath, e.Config.ContactSheetFormat)
+	e.addLog(fmt.Sprintf("Rendering contact sheet (%d frames) → %s", len(times), path))
+	if _, err := e.runFFmpeg("", nil, e.contactSheetArgs(times, path)...); err != nil {
+		return "", fmt.Errorf("contact sheet: %w", err)
+	}
+	return path, nil
+}
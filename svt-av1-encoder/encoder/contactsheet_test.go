@@ -0,0 +1,61 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestContactSheetPath(t *testing.T) {
+	if got := ContactSheetPath("/media/movie.av1.mkv", "jpg"); got != "/media/movie.av1.sheet.jpg" {
+		t.Errorf("ContactSheetPath = %s", got)
+	}
+	if got := ContactSheetPath("clip.av1.mp4", "png"); got != "clip.av1.sheet.png" {
+		t.Errorf("ContactSheetPath = %s", got)
+	}
+}
+
+func TestSheetTimes(t *testing.T) {
+	got := sheetTimes(80*time.Second, 4)
+	want := []time.Duration{10 * time.Second, 30 * time.Second, 50 * time.Second, 70 * time.Second}
+	if len(got) != len(want) {
+		t.Fatalf("sheetTimes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("sheetTimes[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+	if sheetTimes(0, 4) != nil || sheetTimes(time.Minute, 0) != nil {
+		t.Error("sheetTimes should return nil without a duration or frame count")
+	}
+}
+
+func TestContactSheetGraph(t *testing.T) {
+	times := []time.Duration{10 * time.Second, 30 * time.Second, time.Hour + 5*time.Second}
+	graph := contactSheetGraph(times, 2)
+
+	if !strings.HasSuffix(graph, "[t0][t1][t2]concat=n=3:v=1:a=0,tile=2x2:padding=4:margin=4") {
+		t.Errorf("graph should tile 3 thumbnails in 2 columns: %s", graph)
+	}
+	if !strings.Contains(graph, "[2:v]trim=end_frame=1,scale=480:-2") {
+		t.Errorf("each input should keep one scaled frame: %s", graph)
+	}
+	if !strings.Contains(graph, `text='1\:00\:05'`) {
+		t.Errorf("timestamps should be escaped for drawtext: %s", graph)
+	}
+}
+
+func TestContactSheetArgs(t *testing.T) {
+	enc := &Encoder{OutputPath: "out.av1.mkv"}
+	enc.Config.ContactSheetColumns = 4
+	enc.Config.ContactSheetFormat = "jpg"
+	args := strings.Join(enc.contactSheetArgs([]time.Duration{time.Second, 2 * time.Second}, "out.av1.sheet.jpg"), " ")
+
+	if !strings.HasPrefix(args, "-ss 1.000 -i out.av1.mkv -ss 2.000 -i out.av1.mkv -filter_complex") {
+		t.Errorf("each frame should be its own seeked input: %s", args)
+	}
+	if !strings.HasSuffix(args, "-frames:v 1 -q:v 3 -y out.av1.sheet.jpg") {
+		t.Errorf("unexpected output args: %s", args)
+	}
+}
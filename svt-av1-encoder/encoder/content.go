@@ -0,0 +1,242 @@
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+const (
+	contentSamples       = 4  // Segments scanned for cuts and entropy
+	contentSampleSeconds = 15 // Long enough to catch several cuts in edited content
+	sceneCutThreshold    = 10 // scdet score (0-100) counted as a cut
+
+	// Animation has large flat areas (low entropy) and no sensor noise (high PSNR vs denoised)
+	animationMaxEntropy = 0.6
+	animationMinPSNR    = 47
+)
+
+var (
+	sceneCutRe = regexp.MustCompile(`lavfi\.scd\.time:\s*([\d.]+)`)
+	entropyRe  = regexp.MustCompile(`lavfi\.entropy\.normalized_entropy\.normal\.Y=([\d.]+)`)
+)
+
+// ContentAnalysis is what auto profile selection knows about the source
+type ContentAnalysis struct {
+	Width, Height   int
+	HDR             string  // SDR, HDR10, HLG or Dolby Vision
+	BPP             float64 // Video bits per pixel per frame, 0 if unknown
+	Sampled         bool    // Cuts and entropy were measured; needs a known duration
+	CutsPerMinute   float64
+	Entropy         float64 // Median normalized luma entropy (0-1)
+	Grain           *GrainEstimate
+	Animation       bool
+	AnimationReason string
+}
+
+// ProfileChoice is the profile auto selection picked and why
+type ProfileChoice struct {
+	Profile config.Profile
+	Rule    string // The matching rule as written
+	Content *ContentAnalysis
+}
+
+// hdrClass classifies a video stream's dynamic range from its transfer function and side data
+// HDR10+ metadata is per-frame side data that stream probing doesn't see, so it reports as HDR10
+func hdrClass(s StreamInfo) string {
+	for _, sd := range s.SideData {
+		if strings.Contains(sd, "DOVI") {
+			return "Dolby Vision"
+		}
+	}
+	switch s.ColorTransfer {
+	case "smpte2084":
+		return "HDR10"
+	case "arib-std-b67":
+		return "HLG"
+	}
+	return "SDR"
+}
+
+// Facts converts the analysis to the values auto rules test (see config.AutoFactKeys)
+// Facts that couldn't be measured are left out rather than reported as 0, so no rule matches on them
+func (c *ContentAnalysis) Facts() map[string]float64 {
+	facts := map[string]float64{
+		"animation": 0,
+		"hdr":       0,
+	}
+	if c.Sampled {
+		facts["motion"] = c.CutsPerMinute
+	}
+	if c.Height > 0 {
+		facts["height"] = float64(c.Height)
+	}
+	if c.BPP > 0 {
+		facts["bpp"] = c.BPP
+	}
+	if c.Animation {
+		facts["animation"] = 1
+	}
+	if c.HDR != "SDR" {
+		facts["hdr"] = 1
+	}
+	if c.Grain != nil {
+		facts["grain"] = float64(c.Grain.Level)
+	}
+	return facts
+}
+
+// Summary describes the analysis in one line, e.g. "1920x1080 SDR, 3.2 cuts/min, grain moderate, 0.112 bpp, live action"
+func (c *ContentAnalysis) Summary() string {
+	parts := []string{fmt.Sprintf("%dx%d %s", c.Width, c.Height, c.HDR)}
+	if c.Sampled {
+		parts = append(parts, fmt.Sprintf("%.1f cuts/min", c.CutsPerMinute))
+	}
+	if c.Grain != nil {
+		parts = append(parts, "grain "+c.Grain.Class)
+	}
+	if c.BPP > 0 {
+		parts = append(parts, fmt.Sprintf("%.3f bpp", c.BPP))
+	}
+	if c.Animation {
+		parts = append(parts, "animation ("+c.AnimationReason+")")
+	} else {
+		parts = append(parts, "live action")
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseSceneCuts counts the cuts scdet reported
+func parseSceneCuts(stderr string) int {
+	return len(sceneCutRe.FindAllStringIndex(stderr, -1))
+}
+
+// parseSceneCutTimes lists the times of the cuts scdet reported, in order
+func parseSceneCutTimes(stderr string) []time.Duration {
+	var times []time.Duration
+	for _, m := range sceneCutRe.FindAllStringSubmatch(stderr, -1) {
+		if s, err := strconv.ParseFloat(m[1], 64); err == nil {
+			times = append(times, time.Duration(s*float64(time.Second)))
+		}
+	}
+	return times
+}
+
+// parseEntropy returns the per-frame luma entropy printed by the metadata filter
+func parseEntropy(stderr string) []float64 {
+	var values []float64
+	for _, m := range entropyRe.FindAllStringSubmatch(stderr, -1) {
+		if v, err := strconv.ParseFloat(m[1], 64); err == nil {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// median returns the middle value of values, 0 for none
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+	return sorted[mid]
+}
+
+// classifyAnimation decides whether the source is animation from its tags or its statistics
+func classifyAnimation(genre string, entropy float64, grain *GrainEstimate) (bool, string) {
+	genre = strings.ToLower(genre)
+	for _, word := range []string{"anim", "cartoon"} {
+		if strings.Contains(genre, word) {
+			return true, "tagged " + genre
+		}
+	}
+	if grain != nil && grain.PSNR >= animationMinPSNR && entropy > 0 && entropy <= animationMaxEntropy {
+		return true, fmt.Sprintf("flat and noise-free, entropy %.2f", entropy)
+	}
+	return false, ""
+}
+
+// AnalyzeContent gathers the facts auto profile selection is based on
+// Frames are analyzed as decoded; crop and scale are not decided yet at this point
+func (e *Encoder) AnalyzeContent() (*ContentAnalysis, error) {
+	if e.Source == nil {
+		return nil, fmt.Errorf("content analysis needs a probed source")
+	}
+	video, ok := e.Source.VideoStream()
+	if !ok {
+		return nil, fmt.Errorf("no video stream to analyze")
+	}
+
+	c := &ContentAnalysis{
+		Width:  video.Width,
+		Height: video.Height,
+		HDR:    hdrClass(video),
+		BPP:    e.Source.BitsPerPixel(),
+	}
+
+	e.addLog(fmt.Sprintf("Analyzing content (%d samples)", contentSamples))
+
+	// Short sources are covered by shorter samples rather than overlapping ones
+	sampleSeconds := min(float64(contentSampleSeconds), e.Source.Duration.Seconds()/contentSamples)
+	if sampleSeconds > 0 {
+		cuts := 0
+		var entropy []float64
+		for i := 0; i < contentSamples; i++ {
+			at := time.Duration(float64(e.Source.Duration) * (float64(i) + 0.5) / contentSamples)
+			stderr, err := e.runFFmpeg("", nil,
+				"-ss", fmt.Sprintf("%.3f", at.Seconds()),
+				"-t", fmt.Sprintf("%.3f", sampleSeconds),
+				"-i", e.InputPath,
+				"-map", fmt.Sprintf("0:%d", video.Index),
+				"-vf", fmt.Sprintf("scdet=threshold=%d,entropy,metadata=mode=print:key=lavfi.entropy.normalized_entropy.normal.Y", sceneCutThreshold),
+				"-f", "null", "-",
+			)
+			if err != nil {
+				return nil, fmt.Errorf("content analysis failed: %w", err)
+			}
+			cuts += parseSceneCuts(stderr)
+			entropy = append(entropy, parseEntropy(stderr)...)
+		}
+		c.Sampled = true
+		c.CutsPerMinute = math.Round(float64(cuts)/(sampleSeconds*contentSamples)*60*10) / 10
+		c.Entropy = median(entropy)
+	}
+
+	grain, err := e.measureGrain(nil)
+	if err != nil {
+		return nil, err
+	}
+	c.Grain = grain
+	c.Animation, c.AnimationReason = classifyAnimation(e.Source.Tags["genre"], c.Entropy, grain)
+
+	return c, nil
+}
+
+// SelectProfile analyzes the content, picks a profile with the auto rules and applies it
+func (e *Encoder) SelectProfile() (*ProfileChoice, error) {
+	content, err := e.AnalyzeContent()
+	if err != nil {
+		return nil, err
+	}
+
+	rules := e.Config.AutoRules
+	if len(rules) == 0 {
+		rules = config.DefaultAutoRules()
+	}
+	rule := config.ChooseProfile(rules, content.Facts())
+	e.Config = e.Config.WithProfile(rule.Profile)
+
+	e.addLog(fmt.Sprintf("Auto profile: %s (rule: %s; %s)", rule.Profile, rule.Text, content.Summary()))
+	return &ProfileChoice{Profile: rule.Profile, Rule: rule.Text, Content: content}, nil
+}
@@ -0,0 +1,93 @@
+package encoder
+
+import (
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func TestHDRClass(t *testing.T) {
+	tests := []struct {
+		stream StreamInfo
+		want   string
+	}{
+		{StreamInfo{ColorTransfer: "bt709"}, "SDR"},
+		{StreamInfo{}, "SDR"},
+		{StreamInfo{ColorTransfer: "smpte2084", ColorPrimaries: "bt2020"}, "HDR10"},
+		{StreamInfo{ColorTransfer: "arib-std-b67"}, "HLG"},
+		{StreamInfo{ColorTransfer: "smpte2084", SideData: []string{"DOVI configuration record"}}, "Dolby Vision"},
+	}
+	for _, tc := range tests {
+		if got := hdrClass(tc.stream); got != tc.want {
+			t.Errorf("hdrClass(%+v) = %s, want %s", tc.stream, got, tc.want)
+		}
+	}
+}
+
+func TestParseContentStats(t *testing.T) {
+	stderr := "[scdet @ 0x1] lavfi.scd.score: 45.211, lavfi.scd.time: 3.28\n" +
+		"[Parsed_metadata_2 @ 0x2] frame:0    pts:0       pts_time:0\n" +
+		"[Parsed_metadata_2 @ 0x2] lavfi.entropy.normalized_entropy.normal.Y=0.712300\n" +
+		"[scdet @ 0x1] lavfi.scd.score: 22.010, lavfi.scd.time: 9.01\n" +
+		"[Parsed_metadata_2 @ 0x2] lavfi.entropy.normalized_entropy.normal.Y=0.650000\n"
+	if got := parseSceneCuts(stderr); got != 2 {
+		t.Errorf("parseSceneCuts = %d, want 2", got)
+	}
+	if got := parseSceneCutTimes(stderr); len(got) != 2 || got[0] != 3280*time.Millisecond || got[1] != 9010*time.Millisecond {
+		t.Errorf("parseSceneCutTimes = %v", got)
+	}
+	if got := parseEntropy(stderr); len(got) != 2 || got[0] != 0.7123 {
+		t.Errorf("parseEntropy = %v", got)
+	}
+	if got := median([]float64{0.9, 0.2, 0.5}); got != 0.5 {
+		t.Errorf("median = %v, want 0.5", got)
+	}
+}
+
+func TestClassifyAnimation(t *testing.T) {
+	clean := &GrainEstimate{PSNR: 52, Class: "clean"}
+	grainy := &GrainEstimate{PSNR: 40, Class: "heavy", Level: 12}
+
+	if ok, reason := classifyAnimation("Animation", 0.8, grainy); !ok || reason != "tagged animation" {
+		t.Errorf("genre tag should win: %v %q", ok, reason)
+	}
+	if ok, _ := classifyAnimation("", 0.45, clean); !ok {
+		t.Error("flat, noise-free content should be animation")
+	}
+	if ok, _ := classifyAnimation("", 0.45, grainy); ok {
+		t.Error("grainy content is not animation")
+	}
+	if ok, _ := classifyAnimation("", 0.8, clean); ok {
+		t.Error("detailed clean content (digital live action) is not animation")
+	}
+}
+
+func TestContentFacts(t *testing.T) {
+	c := &ContentAnalysis{Width: 3840, Height: 2160, HDR: "HDR10", BPP: 0.08, Sampled: true, CutsPerMinute: 6.5,
+		Grain: &GrainEstimate{Level: 4, Class: "light"}}
+	facts := c.Facts()
+	if facts["hdr"] != 1 || facts["animation"] != 0 || facts["grain"] != 4 || facts["height"] != 2160 || facts["motion"] != 6.5 {
+		t.Errorf("Facts = %v", facts)
+	}
+	if got := c.Summary(); got != "3840x2160 HDR10, 6.5 cuts/min, grain light, 0.080 bpp, live action" {
+		t.Errorf("Summary = %q", got)
+	}
+}
+
+func TestContentFacts_Unknown(t *testing.T) {
+	// No bitrate and no duration: bpp and motion were never measured
+	c := &ContentAnalysis{Width: 1920, Height: 1080, HDR: "SDR"}
+	facts := c.Facts()
+	for _, key := range []string{"bpp", "motion", "grain"} {
+		if _, ok := facts[key]; ok {
+			t.Errorf("unmeasured %s reported as %v", key, facts[key])
+		}
+	}
+	if got := config.ChooseProfile(config.DefaultAutoRules(), facts); got.Profile != config.ProfileDefault {
+		t.Errorf("unknown facts chose %s (rule %q), want default", got.Profile, got.Text)
+	}
+	if got := c.Summary(); got != "1920x1080 SDR, live action" {
+		t.Errorf("Summary = %q", got)
+	}
+}
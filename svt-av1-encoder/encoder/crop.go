@@ -0,0 +1,193 @@
+package encoder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+const (
+	// cropSamples is how many points across the runtime cropdetect looks at
+	cropSamples = 12
+	// cropSampleSeconds is how long each sample runs so cropdetect settles
+	cropSampleSeconds = 2
+	// cropEdgeTolerance is how far (px) a sample edge may be from the crop and still agree with it
+	cropEdgeTolerance = 8
+)
+
+var cropdetectRe = regexp.MustCompile(`crop=(-?\d+):(-?\d+):(-?\d+):(-?\d+)`)
+
+// CropRect is a crop window in source pixels
+type CropRect struct {
+	W, H, X, Y int
+}
+
+// String formats the crop like "1920x800+0+140"
+func (c CropRect) String() string {
+	return fmt.Sprintf("%dx%d+%d+%d", c.W, c.H, c.X, c.Y)
+}
+
+// Filter returns the ffmpeg crop filter for this window
+func (c CropRect) Filter() string {
+	return fmt.Sprintf("crop=%d:%d:%d:%d", c.W, c.H, c.X, c.Y)
+}
+
+// matches reports whether every edge of r is within tolerance of c
+func (c CropRect) matches(r CropRect) bool {
+	edges := [][2]int{
+		{c.X, r.X},
+		{c.Y, r.Y},
+		{c.X + c.W, r.X + r.W},
+		{c.Y + c.H, r.Y + r.H},
+	}
+	for _, e := range edges {
+		diff := e[0] - e[1]
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > cropEdgeTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// CropResult explains the outcome of crop detection
+type CropResult struct {
+	Crop     *CropRect // nil when the full frame is kept
+	Reason   string
+	Samples  int // Usable samples
+	Agreeing int // Samples that agree with the chosen window
+}
+
+// parseCropdetect returns the last crop cropdetect suggested in stderr
+func parseCropdetect(stderr string) (CropRect, bool) {
+	matches := cropdetectRe.FindAllStringSubmatch(stderr, -1)
+	if len(matches) == 0 {
+		return CropRect{}, false
+	}
+	m := matches[len(matches)-1]
+	w, _ := strconv.Atoi(m[1])
+	h, _ := strconv.Atoi(m[2])
+	x, _ := strconv.Atoi(m[3])
+	y, _ := strconv.Atoi(m[4])
+	return CropRect{W: w, H: h, X: x, Y: y}, true
+}
+
+// chooseCrop decides on a crop from per-sample cropdetect windows
+//
+// Dark scenes make cropdetect suggest more cropping than the real bars, so the chosen window is
+// the union of all samples. Samples that show picture where most others show bars mean the
+// aspect ratio changes (e.g. IMAX sequences), in which case nothing is cropped.
+// Auto mode tolerates a few disagreeing samples; conservative requires all of them to agree
+// and ignores thin bars.
+func chooseCrop(samples []CropRect, width, height int, mode config.CropMode) CropResult {
+	// Fully black frames come back with negative or tiny windows
+	var usable []CropRect
+	for _, s := range samples {
+		if s.W > 0 && s.H > 0 && s.W*s.H >= width*height/4 {
+			usable = append(usable, s)
+		}
+	}
+	result := CropResult{Samples: len(usable)}
+	if len(usable) == 0 {
+		result.Reason = "no usable samples"
+		return result
+	}
+
+	union := usable[0]
+	right, bottom := union.X+union.W, union.Y+union.H
+	for _, s := range usable[1:] {
+		union.X = min(union.X, s.X)
+		union.Y = min(union.Y, s.Y)
+		right = max(right, s.X+s.W)
+		bottom = max(bottom, s.Y+s.H)
+	}
+	union.W = right - union.X
+	union.H = bottom - union.Y
+
+	for _, s := range usable {
+		if union.matches(s) {
+			result.Agreeing++
+		}
+	}
+
+	required := 0.8
+	minBarX, minBarY := 6, 6
+	if mode == config.CropConservative {
+		required = 1.0
+		minBarX, minBarY = width/50, height/50
+	}
+
+	if float64(result.Agreeing) < float64(len(usable))*required {
+		result.Reason = fmt.Sprintf("aspect ratio changes (%d of %d samples agree)", result.Agreeing, len(usable))
+		return result
+	}
+
+	// Drop bars too thin to be worth cropping, keeping dimensions even for 4:2:0
+	if union.X < minBarX && width-right < minBarX {
+		union.X, union.W = 0, width
+	}
+	if union.Y < minBarY && height-bottom < minBarY {
+		union.Y, union.H = 0, height
+	}
+	union.W -= union.W % 2
+	union.H -= union.H % 2
+
+	if union.W >= width && union.H >= height {
+		result.Reason = "no black bars"
+		return result
+	}
+
+	result.Crop = &union
+	result.Reason = fmt.Sprintf("bars stable in %d of %d samples", result.Agreeing, len(usable))
+	return result
+}
+
+// DetectCrop samples cropdetect across the runtime and stores the chosen crop on the encoder
+func (e *Encoder) DetectCrop() (CropResult, error) {
+	if e.Config.CropMode == config.CropOff || e.Config.CropMode == "" {
+		return CropResult{Reason: "disabled"}, nil
+	}
+	if e.Source == nil {
+		return CropResult{}, fmt.Errorf("crop detection needs a probed source")
+	}
+	video, ok := e.Source.VideoStream()
+	if !ok || video.Width == 0 || video.Height == 0 || e.Source.Duration <= 0 {
+		return CropResult{Reason: "no video dimensions or duration"}, nil
+	}
+
+	e.addLog(fmt.Sprintf("Detecting black bars (%d samples, %s mode)", cropSamples, e.Config.CropMode))
+
+	var samples []CropRect
+	for i := 0; i < cropSamples; i++ {
+		// Sample the middle of each equal slice of the runtime
+		at := time.Duration(float64(e.Source.Duration) * (float64(i) + 0.5) / cropSamples)
+		stderr, err := e.runFFmpeg("", nil,
+			"-ss", fmt.Sprintf("%.3f", at.Seconds()),
+			"-i", e.InputPath,
+			"-map", fmt.Sprintf("0:%d", video.Index),
+			"-t", strconv.Itoa(cropSampleSeconds),
+			"-vf", "cropdetect=limit=0.094:round=2:reset=0",
+			"-f", "null", "-",
+		)
+		if err != nil {
+			return CropResult{}, fmt.Errorf("cropdetect failed: %w", err)
+		}
+		if rect, ok := parseCropdetect(stderr); ok {
+			samples = append(samples, rect)
+		}
+	}
+
+	result := chooseCrop(samples, video.Width, video.Height, e.Config.CropMode)
+	e.Crop = result.Crop
+	if result.Crop != nil {
+		e.addLog(fmt.Sprintf("Crop: %s from %dx%d (%s)", result.Crop, video.Width, video.Height, result.Reason))
+	} else {
+		e.addLog(fmt.Sprintf("Crop: none (%s)", result.Reason))
+	}
+	return result, nil
+}
@@ -0,0 +1,79 @@
+package encoder
+
+import (
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestParseCropdetect(t *testing.T) {
+	stderr := "[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:138 y2:941 w:1920 h:800 x:0 y:140 pts:1 t:0.04 limit:0.094 crop=1920:800:0:140\n" +
+		"[Parsed_cropdetect_0 @ 0x1] x1:0 x2:1919 y1:136 y2:943 w:1920 h:804 x:0 y:138 pts:2 t:0.08 limit:0.094 crop=1920:804:0:138\n"
+
+	got, ok := parseCropdetect(stderr)
+	if !ok {
+		t.Fatal("parseCropdetect found no crop")
+	}
+	if want := (CropRect{W: 1920, H: 804, X: 0, Y: 138}); got != want {
+		t.Errorf("parseCropdetect = %v, want last line %v", got, want)
+	}
+
+	if _, ok := parseCropdetect("no crop lines here"); ok {
+		t.Error("parseCropdetect should report no match")
+	}
+}
+
+func TestChooseCrop(t *testing.T) {
+	letterbox := CropRect{W: 1920, H: 800, X: 0, Y: 140}
+	full := CropRect{W: 1920, H: 1080, X: 0, Y: 0}
+	darkScene := CropRect{W: 1600, H: 600, X: 160, Y: 240}
+	black := CropRect{W: -1920, H: -1080, X: 1920, Y: 1080}
+
+	repeat := func(r CropRect, n int) []CropRect {
+		var out []CropRect
+		for i := 0; i < n; i++ {
+			out = append(out, r)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name    string
+		samples []CropRect
+		mode    config.CropMode
+		want    *CropRect
+	}{
+		{"stable letterbox", repeat(letterbox, 12), config.CropAuto, &letterbox},
+		{"dark scenes ignored", append(repeat(letterbox, 10), darkScene, black), config.CropAuto, &letterbox},
+		{"no bars", repeat(full, 12), config.CropAuto, nil},
+		{"imax expansion refused", append(repeat(letterbox, 8), repeat(full, 4)...), config.CropAuto, nil},
+		{"one expansion refused in conservative", append(repeat(letterbox, 11), CropRect{W: 1920, H: 1040, X: 0, Y: 20}), config.CropConservative, nil},
+		{"only black frames", repeat(black, 12), config.CropAuto, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := chooseCrop(tc.samples, 1920, 1080, tc.mode)
+			switch {
+			case tc.want == nil && result.Crop != nil:
+				t.Errorf("expected no crop, got %v (%s)", result.Crop, result.Reason)
+			case tc.want != nil && result.Crop == nil:
+				t.Errorf("expected %v, got none (%s)", tc.want, result.Reason)
+			case tc.want != nil && *result.Crop != *tc.want:
+				t.Errorf("crop = %v, want %v", result.Crop, tc.want)
+			}
+		})
+	}
+}
+
+func TestChooseCrop_ConservativeIgnoresThinBars(t *testing.T) {
+	thin := CropRect{W: 1920, H: 1064, X: 0, Y: 8}
+	samples := []CropRect{thin, thin, thin}
+
+	if result := chooseCrop(samples, 1920, 1080, config.CropConservative); result.Crop != nil {
+		t.Errorf("conservative mode cropped 8px bars: %v", result.Crop)
+	}
+	if result := chooseCrop(samples, 1920, 1080, config.CropAuto); result.Crop == nil || *result.Crop != thin {
+		t.Errorf("auto mode should crop 8px bars, got %v (%s)", result.Crop, result.Reason)
+	}
+}
@@ -0,0 +1,94 @@
+package encoder
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// diskCheckInterval is how often the watchdog polls free space during an encode
+const diskCheckInterval = 10 * time.Second
+
+// errFreeSpaceUnsupported is returned by freeSpace on platforms without a free-space query
+var errFreeSpaceUnsupported = errors.New("free space query not supported on this platform")
+
+// EstimateOutputSize guesses the output size from the source size and the profile's expected ratio
+// Any existing output is overwritten, so its size is subtracted from what still has to be found
+func (e *Encoder) EstimateOutputSize() (int64, error) {
+	info, err := os.Stat(e.InputPath)
+	if err != nil {
+		return 0, err
+	}
+	estimate := info.Size() * int64(e.Config.ExpectedSizePercent) / 100
+	if existing, err := os.Stat(e.OutputPath); err == nil {
+		estimate = max(0, estimate-existing.Size())
+	}
+	return estimate, nil
+}
+
+// checkSpace compares free space with the estimated output plus margin
+func checkSpace(dir string, free, estimate, margin int64) error {
+	if free >= estimate+margin {
+		return nil
+	}
+	return fmt.Errorf("not enough disk space in %s: %s free, need about %s (estimated output %s + %s margin)",
+		dir, formatSize(free), formatSize(estimate+margin), formatSize(estimate), formatSize(margin))
+}
+
+// CheckDiskSpace is the preflight run before Start: it fails when the output filesystem can't
+// hold the estimated output plus the configured margin, and otherwise returns a note for the analysis
+func (e *Encoder) CheckDiskSpace() (string, error) {
+	if !e.Config.CheckDiskSpace {
+		return "", nil
+	}
+	dir := filepath.Dir(e.OutputPath)
+	free, err := freeSpace(dir)
+	if errors.Is(err, errFreeSpaceUnsupported) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("cannot check free space in %s: %w", dir, err)
+	}
+	estimate, err := e.EstimateOutputSize()
+	if err != nil {
+		return "", err
+	}
+	if err := checkSpace(dir, free, estimate, e.Config.FreeSpaceMargin); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Disk space: %s free, output estimated at %s", formatSize(free), formatSize(estimate)), nil
+}
+
+// lowSpaceError explains why the watchdog stopped the encode
+func lowSpaceError(dir string, free, minimum int64) error {
+	return fmt.Errorf("stopped: only %s free in %s (minimum %s); free up space and run again",
+		formatSize(free), dir, formatSize(minimum))
+}
+
+// watchDiskSpace stops the encode before ffmpeg hits ENOSPC, which would otherwise only show
+// up as an exit status and a line in the stderr log
+func (e *Encoder) watchDiskSpace(finished <-chan struct{}) {
+	minimum := e.Config.MinFreeSpace
+	dir := filepath.Dir(e.OutputPath)
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-finished:
+			return
+		case <-ticker.C:
+		}
+		free, err := freeSpace(dir)
+		if err != nil {
+			// Unsupported or transient failures leave the encode alone
+			continue
+		}
+		if free < minimum {
+			e.abortEncode(lowSpaceError(dir, free, minimum))
+			return
+		}
+	}
+}
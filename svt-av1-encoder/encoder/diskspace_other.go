@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package encoder
+
+// freeSpace is not implemented here; the preflight and watchdog are skipped
+func freeSpace(dir string) (int64, error) {
+	return 0, errFreeSpaceUnsupported
+}
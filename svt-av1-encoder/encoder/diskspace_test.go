@@ -0,0 +1,63 @@
+package encoder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestEstimateOutputSize(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "movie.mkv")
+	if err := os.WriteFile(input, make([]byte, 10000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.GetProfile(config.ProfileCompress)
+	enc := New(input, cfg)
+
+	got, err := enc.EstimateOutputSize()
+	if err != nil || got != 3500 {
+		t.Errorf("EstimateOutputSize = %d, %v; want 3500 (35%% of the source)", got, err)
+	}
+
+	// A previous output is overwritten, so its space counts as available
+	if err := os.WriteFile(enc.OutputPath, make([]byte, 1000), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := enc.EstimateOutputSize(); got != 2500 {
+		t.Errorf("EstimateOutputSize with existing output = %d, want 2500", got)
+	}
+}
+
+func TestCheckSpace(t *testing.T) {
+	if err := checkSpace("/media", 10<<30, 8<<30, 1<<30); err != nil {
+		t.Errorf("10 GiB free should fit 8 GiB + 1 GiB margin: %v", err)
+	}
+	err := checkSpace("/media", 5<<30, 8<<30, 1<<30)
+	if err == nil {
+		t.Fatal("5 GiB free should not fit 8 GiB + 1 GiB margin")
+	}
+	if !strings.Contains(err.Error(), "5.00 GiB free, need about 9.00 GiB") {
+		t.Errorf("error should give free and needed space: %v", err)
+	}
+}
+
+func TestCheckDiskSpace_Disabled(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.CheckDiskSpace = false
+	if note, err := New("/missing/movie.mkv", cfg).CheckDiskSpace(); note != "" || err != nil {
+		t.Errorf("disabled preflight should do nothing, got %q, %v", note, err)
+	}
+}
+
+func TestAbortEncode_ReportsFirstReason(t *testing.T) {
+	enc := New("movie.mkv", config.DefaultConfig())
+	enc.abortEncode(lowSpaceError("/media", 100<<20, 512<<20))
+	enc.abortEncode(os.ErrClosed)
+	if enc.abort == nil || !strings.Contains(enc.abort.Error(), "only 100.0 MiB free in /media (minimum 512.0 MiB)") {
+		t.Errorf("abort = %v", enc.abort)
+	}
+}
@@ -0,0 +1,141 @@
+package encoder
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrorCategory is a kind of ffmpeg failure recognised from its stderr
+type ErrorCategory string
+
+const (
+	CategoryMissingEncoder ErrorCategory = "missing-encoder" // ffmpeg built without libsvtav1
+	CategoryInvalidParam   ErrorCategory = "invalid-param"   // SVT-AV1 rejected an option
+	CategoryPixelFormat    ErrorCategory = "pixel-format"    // No usable pixel format conversion
+	CategoryMuxer          ErrorCategory = "muxer"           // Container or subtitle incompatibility
+	CategoryDiskFull       ErrorCategory = "disk-full"       // ENOSPC while writing
+	CategoryInputCorrupt   ErrorCategory = "input-corrupt"   // Damaged source data
+	CategoryOutOfMemory    ErrorCategory = "out-of-memory"   // Allocation failures
+)
+
+// maxErrorLines caps the stderr lines kept per category
+const maxErrorLines = 5
+
+// errorRule recognises one category; rules are listed by priority, since a real cause
+// (e.g. a full disk) is often followed by lines matching a later rule (muxer write errors)
+type errorRule struct {
+	category   ErrorCategory
+	title      string
+	suggestion string
+	pattern    *regexp.Regexp
+}
+
+var errorRules = []errorRule{
+	{
+		CategoryMissingEncoder,
+		"SVT-AV1 encoder not available",
+		"Install an ffmpeg built with --enable-libsvtav1 (SVT-AV1-HDR) and make sure it comes first on PATH",
+		regexp.MustCompile(`(?i)unknown encoder 'libsvtav1'|encoder not found|unrecognized option 'svtav1-params'`),
+	},
+	{
+		CategoryDiskFull,
+		"Disk full",
+		"Free space on the output filesystem and run again; -min-free stops encodes before this happens",
+		regexp.MustCompile(`(?i)no space left on device|ENOSPC`),
+	},
+	{
+		CategoryOutOfMemory,
+		"Out of memory",
+		"Use a faster -preset or a lower -max-res, or close other programs; slow presets at 4K need several GB",
+		regexp.MustCompile(`(?i)cannot allocate memory|out of memory|ENOMEM|bad_alloc`),
+	},
+	{
+		CategoryInvalidParam,
+		"Invalid SVT-AV1 parameter",
+		"Check the profile's SVT-AV1 settings (CRF, preset, tune, film grain) against this encoder build; -dry-run shows them",
+		regexp.MustCompile(`(?i)svt\[error\]|error parsing (svtav1 )?option|error setting option .*svtav1|invalid value for .*svtav1`),
+	},
+	{
+		CategoryPixelFormat,
+		"Unsupported pixel format",
+		"Convert explicitly with -filters=format=yuv420p10le, or check the source's pixel format with ffprobe",
+		regexp.MustCompile(`(?i)incompatible pixel format|pixel format .*not supported|does not support (the )?pixel format|impossible to convert between the formats`),
+	},
+	{
+		CategoryMuxer,
+		"Container or subtitle incompatibility",
+		"Keep fewer streams (-subs=none) or use -container=mkv; MP4 only carries mov_text subtitles and a limited set of codecs",
+		regexp.MustCompile(`(?i)could not write header|could not find tag for codec|not currently supported in container|subtitle encoding currently only possible|incompatible with output codec|codec .* not supported by muxer`),
+	},
+	{
+		CategoryInputCorrupt,
+		"Corrupt input",
+		"The source is damaged; try remuxing it (ffmpeg -i in -map 0 -c copy out.mkv) or get a fresh copy",
+		regexp.MustCompile(`(?i)invalid data found when processing input|corrupt (decoded )?(frame|packet|input)|error while decoding|moov atom not found|invalid nal unit`),
+	},
+}
+
+// EncodeError is a failed encode with the category recognised from ffmpeg's stderr
+type EncodeError struct {
+	Category   ErrorCategory
+	Title      string
+	Suggestion string
+	Lines      []string // The stderr lines that identified the category
+	Err        error    // ffmpeg's exit status
+}
+
+func (e *EncodeError) Error() string {
+	return fmt.Sprintf("%s: %s (%v)", e.Title, e.Lines[0], e.Err)
+}
+
+func (e *EncodeError) Unwrap() error {
+	return e.Err
+}
+
+// errorClassifier collects the stderr lines matching each category while ffmpeg runs,
+// so the evidence survives even when LogLines has long since scrolled past it
+type errorClassifier struct {
+	matches map[ErrorCategory][]string
+}
+
+// observe records line under the first category it matches
+func (c *errorClassifier) observe(line string) {
+	for _, rule := range errorRules {
+		if !rule.pattern.MatchString(line) {
+			continue
+		}
+		if c.matches == nil {
+			c.matches = make(map[ErrorCategory][]string)
+		}
+		if len(c.matches[rule.category]) < maxErrorLines {
+			c.matches[rule.category] = append(c.matches[rule.category], strings.TrimSpace(line))
+		}
+		return
+	}
+}
+
+// classify wraps err in the highest-priority category seen, or returns nil when nothing matched
+func (c *errorClassifier) classify(err error) *EncodeError {
+	for _, rule := range errorRules {
+		if lines := c.matches[rule.category]; len(lines) > 0 {
+			return &EncodeError{
+				Category:   rule.category,
+				Title:      rule.title,
+				Suggestion: rule.suggestion,
+				Lines:      lines,
+				Err:        err,
+			}
+		}
+	}
+	return nil
+}
+
+// classifyStderr classifies a finished helper's complete stderr
+func classifyStderr(stderr string, err error) *EncodeError {
+	var c errorClassifier
+	for _, line := range strings.Split(stderr, "\n") {
+		c.observe(line)
+	}
+	return c.classify(err)
+}
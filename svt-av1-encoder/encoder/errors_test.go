@@ -0,0 +1,140 @@
+package encoder
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errExit = errors.New("exit status 1")
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   ErrorCategory
+	}{
+		{"missing encoder", "Unknown encoder 'libsvtav1'", CategoryMissingEncoder},
+		{"invalid param", "Svt[error]: Instance 1: Invalid Preset [-2, 13], your input: 14", CategoryInvalidParam},
+		{"pixel format", "Incompatible pixel format 'yuv422p10le' for codec 'libsvtav1', auto-selecting format 'yuv420p10le'", CategoryPixelFormat},
+		{"subtitle in mp4", "[mp4 @ 0x1] Could not find tag for codec hdmv_pgs_subtitle in stream #2, codec not currently supported in container", CategoryMuxer},
+		{"disk full", "av_interleaved_write_frame(): No space left on device", CategoryDiskFull},
+		{"corrupt input", "[h264 @ 0x1] error while decoding MB 12 40, bytestream -5", CategoryInputCorrupt},
+		{"out of memory", "Error allocating frame: Cannot allocate memory", CategoryOutOfMemory},
+	}
+	for _, tc := range tests {
+		failure := classifyStderr("Input #0, matroska\n"+tc.stderr+"\nConversion failed!", errExit)
+		if failure == nil {
+			t.Errorf("%s: not classified", tc.name)
+			continue
+		}
+		if failure.Category != tc.want {
+			t.Errorf("%s: category = %s, want %s", tc.name, failure.Category, tc.want)
+		}
+		if len(failure.Lines) != 1 || failure.Lines[0] != tc.stderr || failure.Suggestion == "" {
+			t.Errorf("%s: lines = %q, suggestion = %q", tc.name, failure.Lines, failure.Suggestion)
+		}
+	}
+}
+
+func TestClassifyStderr_Unrecognised(t *testing.T) {
+	if failure := classifyStderr("Conversion failed!", errExit); failure != nil {
+		t.Errorf("unrecognised stderr classified as %s", failure.Category)
+	}
+}
+
+func TestClassify_PriorityOverOrder(t *testing.T) {
+	// Neither an earlier decode glitch nor the write errors the full disk causes should hide it
+	var c errorClassifier
+	c.observe("[h264 @ 0x1] error while decoding MB 1 2")
+	c.observe("[matroska @ 0x2] Error writing packet: No space left on device")
+	c.observe("Could not write header for output file #0")
+	if failure := c.classify(errExit); failure == nil || failure.Category != CategoryDiskFull {
+		t.Errorf("classify = %+v, want disk-full", failure)
+	}
+}
+
+func TestClassify_CapsLines(t *testing.T) {
+	var c errorClassifier
+	for i := 0; i < 20; i++ {
+		c.observe(fmt.Sprintf("[hevc @ 0x1] Invalid NAL unit %d, skipping.", i))
+	}
+	failure := c.classify(errExit)
+	if failure == nil || len(failure.Lines) != maxErrorLines {
+		t.Fatalf("classify = %+v, want %d lines", failure, maxErrorLines)
+	}
+	if !strings.HasSuffix(failure.Lines[0], "unit 0, skipping.") {
+		t.Errorf("the first matching lines should be kept, got %q", failure.Lines[0])
+	}
+}
+
+func TestEncodeError_WrapsExitStatus(t *testing.T) {
+	failure := classifyStderr("Unknown encoder 'libsvtav1'", errExit)
+	if !errors.Is(failure, errExit) {
+		t.Error("EncodeError should unwrap to ffmpeg's exit status")
+	}
+	if got := failure.Error(); got != "SVT-AV1 encoder not available: Unknown encoder 'libsvtav1' (exit status 1)" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+// fakeFFmpeg runs the main encode through a shell script instead of ffmpeg
+func fakeFFmpeg(t *testing.T, script string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ffmpeg")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	previous := ffmpegBinary
+	ffmpegBinary = path
+	t.Cleanup(func() { ffmpegBinary = previous })
+}
+
+// launchAndWait starts a run directly, skipping Start's analysis, and waits for it to end
+func launchAndWait(t *testing.T, e *Encoder) error {
+	t.Helper()
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+	t.Cleanup(e.cancel)
+	if err := e.launch(""); err != nil {
+		t.Fatal(err)
+	}
+	return waitDone(t, e)
+}
+
+// waitDone polls the encoder the way the TUI does until the job ends
+func waitDone(t *testing.T, e *Encoder) error {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, _, done, err := e.GetState(); done {
+			return err
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("encode did not finish")
+	return nil
+}
+
+func TestWait_ClassifiesFinalStderrLine(t *testing.T) {
+	// The error is the very last thing ffmpeg prints, right before it exits
+	fakeFFmpeg(t, `i=0
+while [ $i -lt 500 ]; do echo "frame=$i fps=30" >&2; i=$((i+1)); done
+echo "Unknown encoder 'libsvtav1'" >&2
+exit 1`)
+	e := retryEncoder()
+	e.Config.MaxRetries = 0
+	e.OutputPath = filepath.Join(t.TempDir(), "out.mkv")
+
+	var failure *EncodeError
+	if err := launchAndWait(t, e); !errors.As(err, &failure) {
+		t.Fatalf("error = %v, want an EncodeError", err)
+	}
+	if failure.Category != CategoryMissingEncoder {
+		t.Errorf("category = %s, want %s", failure.Category, CategoryMissingEncoder)
+	}
+}
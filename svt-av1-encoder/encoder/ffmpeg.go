@@ -2,6 +2,7 @@ package encoder
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -38,6 +39,11 @@ type Progress struct {
 	LastValidSpeed float64   // Last known good speed multiplier
 	FrameEstimated bool      // Whether TotalFrames is estimated vs actual
 	SourceFPS      float64   // Source video frame rate (for accurate frame estimation)
+
+	// Stall detection
+	LastAdvance time.Time // When the frame count or output time last moved
+	Stalled     bool      // No advance for longer than the stall timeout
+	Restarts    int       // Times the watchdog restarted a stalled encode
 }
 
 // clampPercentage ensures percentage is within 0-100 range
@@ -155,6 +161,9 @@ func parseOutTime(timeStr string) int64 {
 	return totalUs
 }
 
+// ffmpegBinary is the program the main encode runs; tests point it at a stand-in script
+var ffmpegBinary = "ffmpeg"
+
 // Encoder handles FFmpeg encoding with svt-av1-hdr
 type Encoder struct {
 	Config     config.Config
@@ -165,26 +174,97 @@ type Encoder struct {
 	Done       bool
 	Error      error
 	LogLines   []string
-	mu         sync.Mutex // Protects Progress and LogLines
+	mu         sync.Mutex // Protects Progress, LogLines, cmd, abort, restart, failures and attempts
+	abort      error      // Set by a watchdog before it kills ffmpeg; reported instead of the exit status
+	restart    bool       // Set by the stall watchdog so wait launches ffmpeg again instead of finishing
+
+	// failures collects the current run's stderr lines by error category
+	failures errorClassifier
+	// attempts records every ffmpeg run; fallback holds the changes retries made between them
+	attempts []Attempt
+	fallback fallbacks
+
+	// Pre-encode analysis, filled in before Start
+	Source *MediaInfo   // Probed input, nil until Probe succeeds
+	Crop   *CropRect    // Detected black-bar crop, nil to keep the full frame
+	Scan   ScanAnalysis // Detected interlacing, zero value means progressive
+	VFR    VFRAnalysis  // Detected frame timing and CFR target, zero value means constant and untouched
+
+	coverDir string            // Temp dir holding extracted cover art while ffmpeg attaches it
+	toneMap  config.ToneMapper // Set on the SDR companion encoder, which tonemaps HDR to BT.709
+
+	// Provenance is written into the output's tags, nil until Prepare records it
+	Provenance *Provenance
+
+	// ctx is cancelled by Stop so helper passes (quality checks etc.) exit with the app
+	ctx    context.Context
+	cancel context.CancelFunc
 }
 
 // New creates a new Encoder instance
 func New(inputPath string, cfg config.Config) *Encoder {
-	// Generate output path (same directory, .av1.mkv extension)
+	// Generate output path (same directory, .av1.mkv or .av1.mp4 extension)
 	ext := filepath.Ext(inputPath)
 	base := strings.TrimSuffix(inputPath, ext)
-	outputPath := base + ".av1.mkv"
+	container := cfg.Container
+	if container == "" {
+		container = config.ContainerMKV
+	}
+	outputPath := base + ".av1." + string(container)
+
+	ctx, cancel := context.WithCancel(context.Background())
 
 	return &Encoder{
 		Config:     cfg,
 		InputPath:  inputPath,
 		OutputPath: outputPath,
 		LogLines:   make([]string, 0),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// Probe reads the input's streams, chapters and container info
+func (e *Encoder) Probe() error {
+	info, err := ProbeFile(e.ctx, e.InputPath)
+	if err != nil {
+		return err
 	}
+	e.Source = info
+	return nil
 }
 
 // GetTotalFrames probes the input file to get total frame count and source FPS
+// Both are adjusted when the filter chain changes the frame rate (e.g. inverse telecine)
 func (e *Encoder) GetTotalFrames() error {
+	if err := e.probeTotalFrames(); err != nil {
+		return err
+	}
+	e.applyFrameRateMode()
+	// CFR conversion runs after the scan filter, so decimation doesn't change the output rate
+	if !e.VFR.Convert() {
+		e.applyFrameRateFactor()
+	}
+	return nil
+}
+
+// applyFrameRateFactor scales SourceFPS and TotalFrames to what the encoder will actually receive
+func (e *Encoder) applyFrameRateFactor() {
+	factor := e.frameRateFactor()
+	if factor == 1 {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.Progress.SourceFPS *= factor
+	if e.Progress.TotalFrames > 0 {
+		e.Progress.TotalFrames = int64(float64(e.Progress.TotalFrames) * factor)
+		e.Progress.FrameEstimated = true // Decimation isn't exact
+	}
+}
+
+// probeTotalFrames reads the source frame count and FPS with ffprobe
+func (e *Encoder) probeTotalFrames() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -299,24 +379,74 @@ func (e *Encoder) buildFFmpegArgs() []string {
 	args := []string{
 		"-hide_banner",
 		"-progress", "pipe:1", // Progress output to stdout
-		"-i", e.InputPath,
-		"-map", "0",
-		"-map", "-0:d", // Remove data streams
 	}
+	args = append(args, e.fallbackInputArgs()...)
+	args = append(args, "-i", e.InputPath)
+
+	// With a probed source every stream is mapped explicitly so audio can be handled per stream;
+	// otherwise fall back to mapping everything and removing what we don't want
+	plan := e.planStreams()
+	if e.Source != nil {
+		args = append(args, plan.streamArgs()...)
+		args = append(args, e.metadataArgs(plan)...)
+	} else {
+		args = append(args,
+			"-map", "0",
+			"-map", "-0:d", // Remove data streams
+		)
+
+		// Remove unwanted languages
+		for _, lang := range e.Config.RemoveLanguages {
+			args = append(args, "-map", fmt.Sprintf("-0:a:m:language:%s", lang))
+			args = append(args, "-map", fmt.Sprintf("-0:s:m:language:%s", lang))
+		}
 
-	// Remove unwanted languages
-	for _, lang := range e.Config.RemoveLanguages {
-		args = append(args, "-map", fmt.Sprintf("-0:a:m:language:%s", lang))
-		args = append(args, "-map", fmt.Sprintf("-0:s:m:language:%s", lang))
+		// Remove image codecs
+		for _, codec := range e.Config.RemoveImageCodecs {
+			args = append(args, "-map", fmt.Sprintf("-0:v:m:codec_name:%s", codec))
+		}
+
+		args = append(args, "-c:a", "copy")
+		if e.Config.Container == config.ContainerMP4 {
+			args = append(args, "-c:s", "mov_text")
+		} else {
+			args = append(args, "-c:s", "copy")
+		}
 	}
 
-	// Remove image codecs
-	for _, codec := range e.Config.RemoveImageCodecs {
-		args = append(args, "-map", fmt.Sprintf("-0:v:m:codec_name:%s", codec))
+	args = append(args, e.provenanceArgs()...)
+	args = append(args, e.videoArgs(true)...)
+	args = append(args, "-y", e.OutputPath)
+
+	return args
+}
+
+// videoArgs are the filter and SVT-AV1 arguments shared by the full encode and preview clips;
+// chapters forces keyframes at chapter starts, which only line up with the whole source
+func (e *Encoder) videoArgs(chapters bool) []string {
+	var args []string
+	if filters := e.buildFilterChain(); len(filters) > 0 {
+		args = append(args, "-vf", filters.String())
 	}
+	args = append(args, e.frameRateArgs()...)
+	args = append(args, e.colorArgs()...)
+
+	args = append(args,
+		"-c:v", "libsvtav1",
+		"-crf", strconv.Itoa(e.Config.CRF),
+		"-preset", strconv.Itoa(e.Config.Preset),
+	)
+	args = append(args, e.keyframeArgs(chapters)...)
+	args = append(args,
+		"-pix_fmt", "yuv420p10le",
+		"-svtav1-params", e.svtParams(),
+	)
+	return args
+}
 
-	// Video encoding settings
-	svtParams := fmt.Sprintf(
+// svtParams builds the -svtav1-params value from the profile settings
+func (e *Encoder) svtParams() string {
+	return fmt.Sprintf(
 		"tune=%d:enable-variance-boost=%d:variance-boost-strength=%d:sharpness=%d:enable-tf=%d:film-grain=%d",
 		e.Config.Tune,
 		boolToInt(e.Config.VarianceBoost),
@@ -324,23 +454,17 @@ func (e *Encoder) buildFFmpegArgs() []string {
 		e.Config.Sharpness,
 		e.Config.TFStrength,
 		e.Config.FilmGrain,
-	)
+	) + e.filmGrainDenoiseParam() + e.svtKeyframeParams()
+}
 
-	args = append(args,
-		"-c:v", "libsvtav1",
-		"-crf", strconv.Itoa(e.Config.CRF),
-		"-preset", strconv.Itoa(e.Config.Preset),
-		"-g", "240",         // Keyframe every 240 frames (~10 sec at 24fps, ~8 sec at 30fps)
-		"-keyint_min", "48", // Minimum keyframe interval (scene changes still insert keyframes)
-		"-pix_fmt", "yuv420p10le",
-		"-svtav1-params", svtParams,
-		"-c:a", "copy",
-		"-c:s", "copy",
-		"-y",
-		e.OutputPath,
-	)
+// CommandLine returns the full ffmpeg command that Start will run, for logs and dry runs
+func (e *Encoder) CommandLine() string {
+	return "ffmpeg " + strings.Join(e.buildFFmpegArgs(), " ")
+}
 
-	return args
+// StreamPlan returns the per-stream decisions for the probed source
+func (e *Encoder) StreamPlan() StreamPlan {
+	return e.planStreams()
 }
 
 func boolToInt(b bool) int {
@@ -352,53 +476,154 @@ func boolToInt(b bool) int {
 
 // Start begins the encoding process
 func (e *Encoder) Start() error {
-	args := e.buildFFmpegArgs()
-	e.cmd = exec.Command("ffmpeg", args...)
+	// Cover art is re-attached from files, so it has to be extracted first
+	if err := e.extractCoverArt(); err != nil {
+		return err
+	}
 
 	e.addLog(fmt.Sprintf("Starting encode: %s", e.InputPath))
 	e.addLog(fmt.Sprintf("Output: %s", e.OutputPath))
-	e.addLog(fmt.Sprintf("Command: ffmpeg %s", strings.Join(args, " ")))
+	for _, line := range e.planStreams().Describe() {
+		e.addLog("Stream " + line)
+	}
+	if filters := e.buildFilterChain(); len(filters) > 0 {
+		e.addLog("Video filters: " + filters.String())
+	}
 
-	// Set encoding start time
-	e.mu.Lock()
-	e.Progress.StartTime = time.Now()
-	e.mu.Unlock()
+	if err := e.launch(""); err != nil {
+		e.removeCoverArt()
+		return err
+	}
+	return nil
+}
+
+// launch starts ffmpeg with the current settings along with its readers and watchdogs
+// Stall restarts and retries call it again from wait, so the job only finishes when the last run exits
+func (e *Encoder) launch(change string) error {
+	args := e.buildFFmpegArgs()
+	cmd := exec.Command(ffmpegBinary, args...)
+	e.addLog(fmt.Sprintf("Command: ffmpeg %s", strings.Join(args, " ")))
 
-	stdout, err := e.cmd.StdoutPipe()
+	stdout, err := cmd.StdoutPipe()
 	if err != nil {
 		return fmt.Errorf("failed to get stdout pipe: %w", err)
 	}
 
-	stderr, err := e.cmd.StderrPipe()
+	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return fmt.Errorf("failed to get stderr pipe: %w", err)
 	}
 
-	if err := e.cmd.Start(); err != nil {
+	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start ffmpeg: %w", err)
 	}
 
-	go e.parseProgress(stdout)
-	go e.captureStderr(stderr)
+	// Set encoding start time; stall detection counts from here until the first frame
+	now := time.Now()
+	e.mu.Lock()
+	e.cmd = cmd
+	stopped := e.ctx.Err() != nil
+	e.failures = errorClassifier{}
+	e.attempts = append(e.attempts, Attempt{Change: change, Started: now})
+	e.Progress.StartTime = now
+	e.Progress.LastAdvance = now
+	e.mu.Unlock()
 
+	// Stop may have run just before there was a process for it to kill; wait then records the stop
+	if stopped {
+		cmd.Process.Kill()
+	}
+
+	// wait must let both readers reach EOF before calling cmd.Wait, which closes the pipes;
+	// ffmpeg's last stderr lines usually name the error and would otherwise be lost
+	var readers sync.WaitGroup
+	readers.Add(2)
 	go func() {
-		err := e.cmd.Wait()
-		e.mu.Lock()
-		if err != nil {
-			e.Error = err
-			e.LogLines = append(e.LogLines, fmt.Sprintf("Encoding error: %v", err))
-		} else {
-			// Encoding completed successfully - finalize progress
-			e.finalizeProgressLocked()
-			e.LogLines = append(e.LogLines, "Encoding completed successfully!")
-		}
-		e.Done = true
-		e.mu.Unlock()
+		defer readers.Done()
+		e.parseProgress(stdout)
+	}()
+	go func() {
+		defer readers.Done()
+		e.captureStderr(stderr)
 	}()
 
+	finished := make(chan struct{})
+	if e.Config.MinFreeSpace > 0 {
+		go e.watchDiskSpace(finished)
+	}
+	if e.Config.StallTimeout > 0 {
+		go e.watchStall(finished)
+	}
+
+	go e.wait(cmd, &readers, finished)
 	return nil
 }
 
+// wait records how the ffmpeg run ended, or launches it again when a watchdog asked for a restart
+// or a classified failure has a fallback worth retrying
+func (e *Encoder) wait(cmd *exec.Cmd, readers *sync.WaitGroup, finished chan struct{}) {
+	readers.Wait()
+	err := cmd.Wait()
+	close(finished)
+
+	e.mu.Lock()
+	stopped := e.ctx.Err() != nil
+	restart := e.restart && !stopped
+	e.restart = false
+	if err != nil && e.abort != nil {
+		err = e.abort
+	} else if err != nil {
+		// Name the cause instead of reporting only ffmpeg's exit status
+		if failure := e.failures.classify(err); failure != nil {
+			err = failure
+		}
+	}
+	e.finishAttemptLocked(err, restart)
+	change := ""
+	switch {
+	case restart:
+		change = "restart after stall"
+	case err != nil && !stopped && e.abort == nil && e.fallback.retries < e.Config.MaxRetries:
+		change = e.applyFallbackLocked(err)
+	}
+	if change != "" {
+		e.resetProgressLocked()
+	}
+	attempt := len(e.attempts) + 1
+	e.mu.Unlock()
+
+	if change != "" {
+		e.addLog(fmt.Sprintf("Retrying (attempt %d): %s", attempt, change))
+		if err = e.launch(change); err == nil {
+			return
+		}
+	}
+
+	e.removeCoverArt()
+	e.mu.Lock()
+	if err != nil {
+		e.Error = err
+		e.LogLines = append(e.LogLines, fmt.Sprintf("Encoding error: %v", err))
+	} else {
+		// Encoding completed successfully - finalize progress
+		e.finalizeProgressLocked()
+		e.LogLines = append(e.LogLines, "Encoding completed successfully!")
+	}
+	e.Done = true
+	e.mu.Unlock()
+}
+
+// resetProgressLocked clears the per-run counters before a restart, keeping the totals (must hold mutex)
+func (e *Encoder) resetProgressLocked() {
+	e.Progress = Progress{
+		TotalFrames:    e.Progress.TotalFrames,
+		TotalDuration:  e.Progress.TotalDuration,
+		FrameEstimated: e.Progress.FrameEstimated,
+		SourceFPS:      e.Progress.SourceFPS,
+		Restarts:       e.Progress.Restarts,
+	}
+}
+
 // finalizeProgressLocked corrects progress values when encoding completes (must hold mutex)
 func (e *Encoder) finalizeProgressLocked() {
 	// The actual frame count is whatever we encoded
@@ -549,6 +774,12 @@ func (e *Encoder) applyProgressBatch(batch progressUpdate) {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 
+	// Any forward movement clears a stall
+	if (batch.frameSet && batch.frame > e.Progress.Frame) || (batch.outTimeSet && batch.outTimeUs > e.Progress.OutTimeUs) {
+		e.Progress.LastAdvance = time.Now()
+		e.Progress.Stalled = false
+	}
+
 	// Apply frame count
 	if batch.frameSet {
 		e.Progress.Frame = batch.frame
@@ -794,6 +1025,8 @@ func (e *Encoder) captureStderr(r io.Reader) {
 			}
 		}
 
+		e.failures.observe(line)
+
 		// Keep stderr logs (but filter out progress-like lines)
 		if !strings.HasPrefix(line, "frame=") &&
 			!strings.HasPrefix(line, "size=") &&
@@ -820,11 +1053,59 @@ func (e *Encoder) addLog(line string) {
 	}
 }
 
-// Stop terminates the encoding process
+// Stop terminates the encoding process and any running helper pass
 func (e *Encoder) Stop() {
-	if e.cmd != nil && e.cmd.Process != nil {
-		e.cmd.Process.Kill()
+	if e.cancel != nil {
+		e.cancel()
+	}
+	e.kill()
+}
+
+// kill ends the current ffmpeg run, if any
+func (e *Encoder) kill() {
+	e.mu.Lock()
+	cmd := e.cmd
+	e.mu.Unlock()
+	if cmd != nil && cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// abortEncode kills ffmpeg on behalf of a watchdog; err replaces ffmpeg's exit status as the job error
+func (e *Encoder) abortEncode(err error) {
+	e.mu.Lock()
+	if e.abort == nil {
+		e.abort = err
 	}
+	e.mu.Unlock()
+	e.addLog("Watchdog: " + err.Error())
+	e.kill()
+}
+
+// runFFmpeg runs a short-lived ffmpeg helper process in dir and returns its stderr
+// stdout may be nil; the main encode uses Start instead so progress can be streamed
+func (e *Encoder) runFFmpeg(dir string, stdout io.Writer, args ...string) (string, error) {
+	args = append([]string{"-hide_banner", "-nostdin"}, args...)
+	cmd := exec.CommandContext(e.ctx, "ffmpeg", args...)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if failure := classifyStderr(stderr.String(), err); failure != nil {
+			return stderr.String(), failure
+		}
+		return stderr.String(), fmt.Errorf("ffmpeg failed: %w (%s)", err, lastLine(stderr.String()))
+	}
+	return stderr.String(), nil
+}
+
+// lastLine returns the last non-empty line of s, used to summarize ffmpeg failures
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
 }
 
 // GetState returns a thread-safe snapshot of the encoder state
@@ -867,49 +1148,3 @@ func (e *Encoder) GetActualOutputSize() (int64, error) {
 	}
 	return info.Size(), nil
 }
-
-// GetBitrate returns the bitrate of the video stream in kbps
-func (e *Encoder) GetBitrate() (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	// Try format bitrate first
-	cmd := exec.CommandContext(ctx, "ffprobe",
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "format=bit_rate",
-		"-of", "csv=p=0",
-		e.InputPath,
-	)
-
-	output, err := cmd.Output()
-	if err == nil {
-		bitrateStr := strings.TrimSpace(string(output))
-		if bitrateStr != "N/A" && bitrateStr != "" {
-			if bps, err := strconv.ParseInt(bitrateStr, 10, 64); err == nil && bps > 0 {
-				return int(bps / 1000), nil
-			}
-		}
-	}
-
-	// Fallback: Try stream bitrate
-	cmd = exec.CommandContext(ctx, "ffprobe",
-		"-v", "error",
-		"-select_streams", "v:0",
-		"-show_entries", "stream=bit_rate",
-		"-of", "csv=p=0",
-		e.InputPath,
-	)
-
-	output, err = cmd.Output()
-	if err == nil {
-		bitrateStr := strings.TrimSpace(string(output))
-		if bitrateStr != "N/A" && bitrateStr != "" {
-			if bps, err := strconv.ParseInt(bitrateStr, 10, 64); err == nil && bps > 0 {
-				return int(bps / 1000), nil
-			}
-		}
-	}
-
-	return 0, fmt.Errorf("could not determine bitrate")
-}
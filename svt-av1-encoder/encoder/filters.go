@@ -0,0 +1,141 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// FilterChain is an ordered list of ffmpeg video filters rendered into a single -vf argument
+type FilterChain []string
+
+// Add appends filter to the chain, ignoring empty entries
+func (c *FilterChain) Add(filter string) {
+	if filter != "" {
+		*c = append(*c, filter)
+	}
+}
+
+// String renders the chain in ffmpeg syntax ("crop=...,scale=...")
+func (c FilterChain) String() string {
+	return strings.Join(c, ",")
+}
+
+// buildFilterChain assembles the pre-encode filters in a fixed order:
+// deinterlace/IVTC on the original fields, CFR conversion, crop so scaling sees only picture, then the resolution cap,
+// tonemapping for the SDR companion, and finally the configured pre-filters on the smaller final frame
+func (e *Encoder) buildFilterChain() FilterChain {
+	var chain FilterChain
+	e.addScanFilter(&chain)
+	e.addFrameRateFilter(&chain)
+	e.addGeometryFilters(&chain)
+	e.addToneMapFilter(&chain)
+	e.addPreFilters(&chain)
+	e.addFallbackFilters(&chain)
+	return chain
+}
+
+// referenceFilters returns the filters the reference needs to line up frame-for-frame with the
+// output when measuring quality; anything that only alters the picture itself is left out
+// Tonemapping stays in: an SDR output can only be compared with an SDR reference
+func (e *Encoder) referenceFilters() FilterChain {
+	var chain FilterChain
+	e.addScanFilter(&chain)
+	e.addFrameRateFilter(&chain)
+	e.addGeometryFilters(&chain)
+	e.addToneMapFilter(&chain)
+	return chain
+}
+
+// addScanFilter appends the deinterlace or inverse telecine filter for the detected scan type
+func (e *Encoder) addScanFilter(chain *FilterChain) {
+	fieldOrder := ""
+	if e.Source != nil {
+		if video, ok := e.Source.VideoStream(); ok {
+			fieldOrder = video.FieldOrder
+		}
+	}
+	chain.Add(scanFilter(e.Scan.Type, e.Config.Deinterlacer, fieldOrder))
+}
+
+// addGeometryFilters appends crop and scale, which change frame dimensions
+func (e *Encoder) addGeometryFilters(chain *FilterChain) {
+	if e.Crop != nil {
+		chain.Add(e.Crop.Filter())
+	}
+	if w, h, ok := e.ScaledSize(); ok {
+		chain.Add(scaleFilter(w, h, e.Config.Scaler))
+	}
+}
+
+// addPreFilters appends the profile's denoise/deband/sharpen/format filters in configured order
+func (e *Encoder) addPreFilters(chain *FilterChain) {
+	for _, f := range e.Config.PreFilters {
+		chain.Add(f.String())
+	}
+}
+
+// sourceSize returns the frame size entering the scaler (after crop), or zeros when unknown
+func (e *Encoder) sourceSize() (int, int) {
+	if e.Crop != nil {
+		return e.Crop.W, e.Crop.H
+	}
+	if e.Source == nil {
+		return 0, 0
+	}
+	video, ok := e.Source.VideoStream()
+	if !ok {
+		return 0, 0
+	}
+	return video.Width, video.Height
+}
+
+// ScaledSize returns the output size when the configured resolution cap applies
+func (e *Encoder) ScaledSize() (int, int, bool) {
+	w, h := e.sourceSize()
+	return scaleDimensions(w, h, e.Config.MaxWidth, e.Config.MaxHeight)
+}
+
+// scaleDimensions fits width x height inside maxW x maxH (0 = no limit on that axis)
+// keeping the aspect ratio, with both sides rounded down to a multiple of 8
+// Frames already within the cap are reported as unscaled
+func scaleDimensions(width, height, maxW, maxH int) (int, int, bool) {
+	if width <= 0 || height <= 0 || (maxW <= 0 && maxH <= 0) {
+		return width, height, false
+	}
+	if (maxW <= 0 || width <= maxW) && (maxH <= 0 || height <= maxH) {
+		return width, height, false
+	}
+
+	ratio := 1.0
+	if maxW > 0 && width > maxW {
+		ratio = float64(maxW) / float64(width)
+	}
+	if maxH > 0 && float64(height)*ratio > float64(maxH) {
+		ratio = float64(maxH) / float64(height)
+	}
+
+	w := int(float64(width)*ratio+0.5) / 8 * 8
+	h := int(float64(height)*ratio+0.5) / 8 * 8
+	if w < 8 {
+		w = 8
+	}
+	if h < 8 {
+		h = 8
+	}
+	return w, h, true
+}
+
+// scaleFilter returns the resize filter for the chosen scaler
+// spline36 is only available in zscale; the others use swscale flags
+func scaleFilter(w, h int, scaler config.Scaler) string {
+	switch scaler {
+	case config.ScalerSpline36:
+		return fmt.Sprintf("zscale=w=%d:h=%d:filter=spline36", w, h)
+	case config.ScalerBicubic:
+		return fmt.Sprintf("scale=%d:%d:flags=bicubic", w, h)
+	default:
+		return fmt.Sprintf("scale=%d:%d:flags=lanczos", w, h)
+	}
+}
@@ -0,0 +1,99 @@
+package encoder
+
+import (
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestScaleDimensions(t *testing.T) {
+	tests := []struct {
+		name         string
+		w, h         int
+		maxW, maxH   int
+		wantW, wantH int
+		wantScaled   bool
+	}{
+		{"4K to 1080p", 3840, 2160, 1920, 1080, 1920, 1080, true},
+		{"scope 4K to 1080p", 3840, 1600, 1920, 1080, 1920, 800, true},
+		{"cropped 1080p to 720p", 1920, 804, 1280, 720, 1280, 536, true},
+		{"portrait phone video limited by height", 1080, 1920, 1920, 1080, 608, 1080, true},
+		{"already within cap", 1920, 1080, 1920, 1080, 1920, 1080, false},
+		{"smaller than cap", 1280, 720, 1920, 1080, 1280, 720, false},
+		{"no cap", 3840, 2160, 0, 0, 3840, 2160, false},
+		{"width-only cap", 3840, 2160, 2560, 0, 2560, 1440, true},
+		{"unknown source size", 0, 0, 1920, 1080, 0, 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w, h, scaled := scaleDimensions(tc.w, tc.h, tc.maxW, tc.maxH)
+			if w != tc.wantW || h != tc.wantH || scaled != tc.wantScaled {
+				t.Errorf("scaleDimensions(%d, %d, %d, %d) = %d, %d, %v; want %d, %d, %v",
+					tc.w, tc.h, tc.maxW, tc.maxH, w, h, scaled, tc.wantW, tc.wantH, tc.wantScaled)
+			}
+			if scaled && (w%8 != 0 || h%8 != 0) {
+				t.Errorf("scaled size %dx%d is not mod-8", w, h)
+			}
+		})
+	}
+}
+
+func TestScaleFilter(t *testing.T) {
+	tests := []struct {
+		scaler config.Scaler
+		want   string
+	}{
+		{config.ScalerLanczos, "scale=1920:800:flags=lanczos"},
+		{config.ScalerSpline36, "zscale=w=1920:h=800:filter=spline36"},
+		{config.ScalerBicubic, "scale=1920:800:flags=bicubic"},
+		{"", "scale=1920:800:flags=lanczos"},
+	}
+	for _, tc := range tests {
+		if got := scaleFilter(1920, 800, tc.scaler); got != tc.want {
+			t.Errorf("scaleFilter(%q) = %q, want %q", tc.scaler, got, tc.want)
+		}
+	}
+}
+
+func TestBuildFilterChain_CropBeforeScale(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxWidth, cfg.MaxHeight = 1920, 1080
+	e := &Encoder{
+		Config: cfg,
+		Source: &MediaInfo{Streams: []StreamInfo{{CodecType: "video", Width: 3840, Height: 2160}}},
+		Crop:   &CropRect{W: 3840, H: 1600, X: 0, Y: 280},
+	}
+
+	got := e.buildFilterChain().String()
+	want := "crop=3840:1600:0:280,scale=1920:800:flags=lanczos"
+	if got != want {
+		t.Errorf("buildFilterChain() = %q, want %q", got, want)
+	}
+
+	e.Crop = nil
+	e.Config.MaxWidth, e.Config.MaxHeight = 0, 0
+	if chain := e.buildFilterChain(); len(chain) != 0 {
+		t.Errorf("no crop or cap should give an empty chain, got %q", chain)
+	}
+}
+
+func TestBuildFilterChain_PreFiltersLast(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.MaxWidth, cfg.MaxHeight = 1920, 1080
+	cfg.PreFilters = []config.VideoFilter{{Name: "hqdn3d", Params: "2:1.5:3:3"}, {Name: "deband"}}
+	e := &Encoder{
+		Config: cfg,
+		Source: &MediaInfo{Streams: []StreamInfo{{CodecType: "video", Width: 3840, Height: 2160}}},
+	}
+
+	got := e.buildFilterChain().String()
+	want := "scale=1920:1080:flags=lanczos,hqdn3d=2:1.5:3:3,deband"
+	if got != want {
+		t.Errorf("buildFilterChain() = %q, want %q", got, want)
+	}
+	// The quality reference must not be denoised along with the output
+	if ref := e.referenceFilters().String(); ref != "scale=1920:1080:flags=lanczos" {
+		t.Errorf("referenceFilters() = %q, want only the scale", ref)
+	}
+}
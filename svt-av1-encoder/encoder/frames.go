@@ -0,0 +1,220 @@
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zoomFactor is how much zoom crops are enlarged; nearest-neighbour keeps artifacts crisp
+const zoomFactor = 2
+
+// sceneScanWidth is the width the output is decoded at for scene detection; cuts survive downscaling
+const sceneScanWidth = 320
+
+// ZoomRect is a region of the output frame to enlarge, in output pixels
+type ZoomRect struct {
+	W, H, X, Y int
+	Center     bool // Use the middle quarter of the frame instead of W/H/X/Y
+}
+
+// ParseZoom reads "WxH+X+Y" (e.g. 480x270+960+540) or "center"
+func ParseZoom(value string) (*ZoomRect, error) {
+	if strings.EqualFold(value, "center") {
+		return &ZoomRect{Center: true}, nil
+	}
+	var z ZoomRect
+	if _, err := fmt.Sscanf(value, "%dx%d+%d+%d", &z.W, &z.H, &z.X, &z.Y); err != nil ||
+		z.W <= 0 || z.H <= 0 || z.X < 0 || z.Y < 0 {
+		return nil, fmt.Errorf("invalid zoom '%s' (use WxH+X+Y, e.g. 480x270+960+540, or center)", value)
+	}
+	return &z, nil
+}
+
+// filter returns the crop-and-enlarge filter for a frame of the given size
+func (z *ZoomRect) filter(frameW, frameH int) string {
+	w, h, x, y := z.W, z.H, z.X, z.Y
+	if z.Center {
+		w, h = frameW/2, frameH/2
+		x, y = frameW/4, frameH/4
+	}
+	return fmt.Sprintf("crop=%d:%d:%d:%d,scale=iw*%d:ih*%d:flags=neighbor", w, h, x, y, zoomFactor, zoomFactor)
+}
+
+// ExportedFrame lists the images written for one timestamp
+type ExportedFrame struct {
+	Time    time.Duration
+	Source  string
+	Output  string
+	Stacked string // Source left, output right
+	Zoom    string // Stacked zoom crops, "" without a zoom region
+}
+
+// alignFilter makes a source frame comparable with an output frame of outW x outH
+// Encodes may be cropped (letterbox bars are centred) and scaled, so the source is
+// centre-cropped to the output's aspect ratio and then resized
+func alignFilter(srcW, srcH, outW, outH int) string {
+	if srcW <= 0 || srcH <= 0 || outW <= 0 || outH <= 0 || (srcW == outW && srcH == outH) {
+		return ""
+	}
+	var chain FilterChain
+	w, h := srcW, srcH
+	srcAspect := float64(srcW) / float64(srcH)
+	outAspect := float64(outW) / float64(outH)
+	if math.Abs(srcAspect-outAspect)/outAspect > 0.01 {
+		if srcAspect > outAspect {
+			w = int(float64(srcH)*outAspect) / 2 * 2
+		} else {
+			h = int(float64(srcW)/outAspect) / 2 * 2
+		}
+		chain.Add(fmt.Sprintf("crop=%d:%d:%d:%d", w, h, (srcW-w)/2, (srcH-h)/2))
+	}
+	if w != outW || h != outH {
+		chain.Add(fmt.Sprintf("scale=%d:%d:flags=lanczos", outW, outH))
+	}
+	return chain.String()
+}
+
+// frameName is the file name stem for a timestamp, e.g. "03_0h12m05s"
+func frameName(index int, at time.Duration) string {
+	s := int(at.Seconds())
+	return fmt.Sprintf("%02d_%dh%02dm%02ds", index+1, s/3600, s/60%60, s%60)
+}
+
+// spreadTimes picks n evenly spaced entries from times (sorted), skipping the first second
+func spreadTimes(times []time.Duration, n int) []time.Duration {
+	var usable []time.Duration
+	for _, t := range times {
+		if t >= time.Second {
+			usable = append(usable, t)
+		}
+	}
+	if n <= 0 || len(usable) <= n {
+		return usable
+	}
+	if n == 1 {
+		return []time.Duration{usable[len(usable)/2]}
+	}
+	picked := make([]time.Duration, n)
+	for i := range picked {
+		picked[i] = usable[i*(len(usable)-1)/(n-1)]
+	}
+	return picked
+}
+
+// SceneCutTimes returns n scene-cut timestamps spread through the output
+// Cuts are detected with scdet on a scaled-down decode of the output; its keyframes can't
+// stand in for them, since fixed-GOP encodes place keyframes at regular intervals
+func (e *Encoder) SceneCutTimes(n int) ([]time.Duration, error) {
+	e.addLog("Detecting scene cuts in the output")
+	stderr, err := e.runFFmpeg("", nil,
+		"-nostats",
+		"-i", e.OutputPath,
+		"-map", "0:v:0",
+		"-vf", fmt.Sprintf("scale=%d:-2,scdet=threshold=%d", sceneScanWidth, sceneCutThreshold),
+		"-f", "null", "-",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("scene detection failed: %w", err)
+	}
+	return spreadTimes(parseSceneCutTimes(stderr), n), nil
+}
+
+// extractFrame writes the frame at the given time from input to a PNG
+func (e *Encoder) extractFrame(input string, at time.Duration, filter, path string) error {
+	args := []string{"-ss", fmt.Sprintf("%.3f", at.Seconds()), "-i", input, "-map", "0:v:0", "-frames:v", "1"}
+	if filter != "" {
+		args = append(args, "-vf", filter)
+	}
+	_, err := e.runFFmpeg("", nil, append(args, "-y", path)...)
+	return err
+}
+
+// stackImages places two images side by side, each passed through filter first ("" for none)
+func (e *Encoder) stackImages(left, right, filter, path string) error {
+	graph := "[0:v][1:v]hstack"
+	if filter != "" {
+		graph = fmt.Sprintf("[0:v]%s[l];[1:v]%s[r];[l][r]hstack", filter, filter)
+	}
+	_, err := e.runFFmpeg("", nil, "-i", left, "-i", right, "-filter_complex", graph, "-y", path)
+	return err
+}
+
+// ExportFrames saves source/output PNG pairs, side-by-side images and optional zoom crops
+// for each timestamp into dir
+func (e *Encoder) ExportFrames(times []time.Duration, zoom *ZoomRect, dir string) ([]ExportedFrame, error) {
+	if e.Source == nil {
+		if err := e.Probe(); err != nil {
+			return nil, err
+		}
+	}
+	output, err := ProbeFile(e.ctx, e.OutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read output: %w", err)
+	}
+	src, ok := e.Source.VideoStream()
+	out, ok2 := output.VideoStream()
+	if !ok || !ok2 {
+		return nil, fmt.Errorf("source and output both need a video stream")
+	}
+	if zoom != nil && !zoom.Center && (zoom.X+zoom.W > out.Width || zoom.Y+zoom.H > out.Height) {
+		return nil, fmt.Errorf("zoom region %dx%d+%d+%d is outside the %dx%d output",
+			zoom.W, zoom.H, zoom.X, zoom.Y, out.Width, out.Height)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	align := alignFilter(src.Width, src.Height, out.Width, out.Height)
+	var frames []ExportedFrame
+	for i, at := range times {
+		name := filepath.Join(dir, frameName(i, at))
+		f := ExportedFrame{
+			Time:    at,
+			Source:  name + "_source.png",
+			Output:  name + "_output.png",
+			Stacked: name + "_compare.png",
+		}
+		e.addLog(fmt.Sprintf("Exporting frame at %s", formatTimestamp(at)))
+
+		if err := e.extractFrame(e.InputPath, at, align, f.Source); err != nil {
+			return nil, fmt.Errorf("source frame at %s: %w", formatTimestamp(at), err)
+		}
+		if err := e.extractFrame(e.OutputPath, at, "", f.Output); err != nil {
+			return nil, fmt.Errorf("output frame at %s: %w", formatTimestamp(at), err)
+		}
+		if err := e.stackImages(f.Source, f.Output, "", f.Stacked); err != nil {
+			return nil, fmt.Errorf("comparison image at %s: %w", formatTimestamp(at), err)
+		}
+		if zoom != nil {
+			f.Zoom = name + "_zoom.png"
+			if err := e.stackImages(f.Source, f.Output, zoom.filter(out.Width, out.Height), f.Zoom); err != nil {
+				return nil, fmt.Errorf("zoom image at %s: %w", formatTimestamp(at), err)
+			}
+		}
+		frames = append(frames, f)
+	}
+	return frames, nil
+}
+
+// FramesDir is the default comparison folder for an output (movie.av1.mkv -> movie.av1.frames)
+func FramesDir(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".frames"
+}
+
+// ParseTimestamp reads "H:MM:SS", "M:SS" or seconds, with optional fractions ("1:02:03.5", "90")
+func ParseTimestamp(value string) (time.Duration, error) {
+	var seconds float64
+	for _, part := range strings.Split(strings.TrimSpace(value), ":") {
+		v, err := strconv.ParseFloat(part, 64)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid timestamp '%s' (use H:MM:SS, M:SS or seconds)", value)
+		}
+		seconds = seconds*60 + v
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
@@ -0,0 +1,75 @@
+package encoder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseZoom(t *testing.T) {
+	z, err := ParseZoom("480x270+960+540")
+	if err != nil || *z != (ZoomRect{W: 480, H: 270, X: 960, Y: 540}) {
+		t.Errorf("ParseZoom = %+v, %v", z, err)
+	}
+	if got := z.filter(1920, 1080); got != "crop=480:270:960:540,scale=iw*2:ih*2:flags=neighbor" {
+		t.Errorf("filter = %s", got)
+	}
+	center, err := ParseZoom("center")
+	if err != nil || center.filter(1920, 800) != "crop=960:400:480:200,scale=iw*2:ih*2:flags=neighbor" {
+		t.Errorf("center zoom = %+v, %v", center, err)
+	}
+	for _, bad := range []string{"480x270", "0x270+0+0", "big"} {
+		if _, err := ParseZoom(bad); err == nil {
+			t.Errorf("ParseZoom(%q) should fail", bad)
+		}
+	}
+}
+
+func TestAlignFilter(t *testing.T) {
+	tests := []struct {
+		name                   string
+		srcW, srcH, outW, outH int
+		want                   string
+	}{
+		{"same size", 1920, 1080, 1920, 1080, ""},
+		{"scaled", 3840, 2160, 1920, 1080, "scale=1920:1080:flags=lanczos"},
+		{"letterbox cropped", 1920, 1080, 1920, 800, "crop=1920:800:0:140"},
+		{"cropped and scaled", 3840, 2160, 1920, 800, "crop=3840:1600:0:280,scale=1920:800:flags=lanczos"},
+		{"pillarbox cropped", 1920, 1080, 1440, 1080, "crop=1440:1080:240:0"},
+	}
+	for _, tc := range tests {
+		if got := alignFilter(tc.srcW, tc.srcH, tc.outW, tc.outH); got != tc.want {
+			t.Errorf("%s: alignFilter = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestSpreadTimes(t *testing.T) {
+	var keyframes []time.Duration
+	for s := 0; s <= 100; s += 10 {
+		keyframes = append(keyframes, time.Duration(s)*time.Second)
+	}
+	got := spreadTimes(keyframes, 3)
+	want := []time.Duration{10 * time.Second, 50 * time.Second, 100 * time.Second}
+	if len(got) != 3 || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("spreadTimes = %v, want %v", got, want)
+	}
+	if got := spreadTimes(keyframes[:3], 6); len(got) != 2 {
+		t.Errorf("fewer keyframes than requested should return them all (minus t=0), got %v", got)
+	}
+}
+
+func TestParseTimestamp(t *testing.T) {
+	tests := map[string]time.Duration{
+		"90":        90 * time.Second,
+		"1:30":      90 * time.Second,
+		"1:02:03.5": time.Hour + 2*time.Minute + 3500*time.Millisecond,
+	}
+	for in, want := range tests {
+		if got, err := ParseTimestamp(in); err != nil || got != want {
+			t.Errorf("ParseTimestamp(%q) = %s, %v; want %s", in, got, err, want)
+		}
+	}
+	if _, err := ParseTimestamp("1:xx"); err == nil {
+		t.Error("ParseTimestamp should reject garbage")
+	}
+}
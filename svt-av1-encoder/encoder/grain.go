@@ -0,0 +1,161 @@
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+const (
+	grainSamples       = 8 // Segments compared against their denoised copy
+	grainSampleSeconds = 2
+	// grainDenoiser is a fixed reference denoise; how far a frame moves under it measures its noise
+	grainDenoiser = "hqdn3d=4:3:6:4.5"
+)
+
+// grainLevels maps the luma PSNR between source and denoised copy to a film-grain level
+// Higher PSNR means the denoiser removed little, i.e. a clean source
+var grainLevels = []struct {
+	minPSNR float64
+	level   int
+	class   string
+}{
+	{48, 0, "clean"},
+	{45, 4, "light"},
+	{42, 8, "moderate"},
+	{39, 12, "heavy"},
+	{36, 16, "very heavy"},
+	{0, 20, "extreme"},
+}
+
+// grainDenoiseFromLevel is the lowest level where film-grain-denoise=1 pays off;
+// below it the denoise costs more detail than the bits it saves
+const grainDenoiseFromLevel = 12
+
+// psnrLumaRe matches the luma value in the psnr filter summary ("PSNR y:41.23 u:... average:...")
+var psnrLumaRe = regexp.MustCompile(`PSNR y:(inf|[\d.]+)`)
+
+// GrainEstimate is the measured noise level and the film-grain settings it suggests
+type GrainEstimate struct {
+	PSNR    float64 // Median luma PSNR of source vs denoised copy, in dB
+	Samples int
+	Class   string // clean, light, moderate, heavy, very heavy, extreme
+	Level   int    // Recommended film-grain level (0 = off)
+	Denoise int    // Recommended film-grain-denoise (0 or 1)
+}
+
+// Params formats the recommendation as svtav1-params
+func (g *GrainEstimate) Params() string {
+	if g.Level == 0 {
+		return "film-grain=0"
+	}
+	return fmt.Sprintf("film-grain=%d:film-grain-denoise=%d", g.Level, g.Denoise)
+}
+
+// parseGrainPSNR reads the luma PSNR from psnr filter output, capping identical frames
+func parseGrainPSNR(stderr string) (float64, bool) {
+	m := psnrLumaRe.FindStringSubmatch(stderr)
+	if m == nil {
+		return 0, false
+	}
+	if m[1] == "inf" {
+		return psnrCap, true
+	}
+	v, err := strconv.ParseFloat(m[1], 64)
+	return v, err == nil
+}
+
+// recommendGrain turns per-sample PSNR values into a film-grain recommendation
+// The median keeps black frames and credits from skewing the result
+func recommendGrain(samples []float64) *GrainEstimate {
+	if len(samples) == 0 {
+		return nil
+	}
+	mid := median(samples)
+	est := &GrainEstimate{PSNR: math.Round(mid*100) / 100, Samples: len(samples)}
+	for _, l := range grainLevels {
+		if mid >= l.minPSNR {
+			est.Level, est.Class = l.level, l.class
+			break
+		}
+	}
+	if est.Level >= grainDenoiseFromLevel {
+		est.Denoise = 1
+	}
+	return est
+}
+
+// DetectGrain measures noise by comparing sampled segments with a denoised copy
+// In apply mode the recommendation replaces the profile's film-grain settings
+func (e *Encoder) DetectGrain() (*GrainEstimate, error) {
+	if e.Config.GrainMode == config.GrainOff || e.Config.GrainMode == "" {
+		return nil, nil
+	}
+	if e.Source == nil {
+		return nil, fmt.Errorf("grain estimation needs a probed source")
+	}
+
+	e.addLog(fmt.Sprintf("Estimating film grain (%d samples)", grainSamples))
+
+	// Measure on the frames the encoder will see: deinterlaced, cropped, scaled and pre-filtered,
+	// so a denoising pre-filter lowers the recommendation
+	est, err := e.measureGrain(e.buildFilterChain())
+	if err != nil {
+		return nil, err
+	}
+	if est == nil {
+		e.addLog("Grain: no usable samples")
+		return nil, nil
+	}
+	e.addLog(fmt.Sprintf("Grain: %s (%.2f dB vs denoised) → %s", est.Class, est.PSNR, est.Params()))
+
+	if e.Config.GrainMode == config.GrainApply {
+		e.Config.FilmGrain = est.Level
+		e.Config.FilmGrainDenoise = est.Denoise
+	}
+	return est, nil
+}
+
+// measureGrain runs the denoise comparison on sampled segments after the given filters
+// It returns nil when the source has no video or no sample produced a reading
+func (e *Encoder) measureGrain(chain FilterChain) (*GrainEstimate, error) {
+	video, ok := e.Source.VideoStream()
+	if !ok || e.Source.Duration <= 0 {
+		return nil, nil
+	}
+
+	chain.Add("split[src][ref]")
+	filter := fmt.Sprintf("[0:%d]%s;[ref]%s[den];[src][den]psnr", video.Index, chain, grainDenoiser)
+
+	var samples []float64
+	for i := 0; i < grainSamples; i++ {
+		at := time.Duration(float64(e.Source.Duration) * (float64(i) + 0.5) / grainSamples)
+		stderr, err := e.runFFmpeg("", nil,
+			"-ss", fmt.Sprintf("%.3f", at.Seconds()),
+			"-t", strconv.Itoa(grainSampleSeconds),
+			"-i", e.InputPath,
+			"-filter_complex", filter,
+			"-f", "null", "-",
+		)
+		if err != nil {
+			return nil, fmt.Errorf("grain estimation failed: %w", err)
+		}
+		if v, ok := parseGrainPSNR(stderr); ok {
+			samples = append(samples, v)
+		}
+	}
+
+	return recommendGrain(samples), nil
+}
+
+// filmGrainDenoiseParam adds film-grain-denoise when grain synthesis is on and a value is set
+func (e *Encoder) filmGrainDenoiseParam() string {
+	if e.Config.FilmGrain == 0 || e.Config.FilmGrainDenoise < 0 {
+		return ""
+	}
+	return fmt.Sprintf(":film-grain-denoise=%d", e.Config.FilmGrainDenoise)
+}
@@ -0,0 +1,65 @@
+package encoder
+
+import (
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestParseGrainPSNR(t *testing.T) {
+	stderr := "frame=   48 fps=0.0 q=-0.0 Lsize=N/A time=00:00:02.00\n" +
+		"[Parsed_psnr_2 @ 0x5581] PSNR y:41.234567 u:46.100000 v:45.900000 average:42.300000 min:39.1 max:44.0\n"
+	if got, ok := parseGrainPSNR(stderr); !ok || got != 41.234567 {
+		t.Errorf("parseGrainPSNR = %v, %v; want 41.234567", got, ok)
+	}
+	if got, ok := parseGrainPSNR("PSNR y:inf u:inf v:inf average:inf"); !ok || got != psnrCap {
+		t.Errorf("identical frames = %v, %v; want cap %v", got, ok, psnrCap)
+	}
+	if _, ok := parseGrainPSNR("Conversion failed!"); ok {
+		t.Error("parseGrainPSNR should fail without a summary line")
+	}
+}
+
+func TestRecommendGrain(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []float64
+		class   string
+		params  string
+	}{
+		{"clean digital", []float64{51, 49.5, 100, 50.2}, "clean", "film-grain=0"},
+		{"light grain", []float64{46.1, 45.8, 47.0}, "light", "film-grain=4:film-grain-denoise=0"},
+		{"35mm", []float64{43.5, 42.2, 44.0, 42.9}, "moderate", "film-grain=8:film-grain-denoise=0"},
+		{"16mm scan", []float64{37.0, 38.2, 36.4, 60}, "very heavy", "film-grain=16:film-grain-denoise=1"},
+		{"black frames don't dominate", []float64{38, 39.5, 100, 100, 38.5}, "heavy", "film-grain=12:film-grain-denoise=1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			est := recommendGrain(tc.samples)
+			if est.Class != tc.class || est.Params() != tc.params {
+				t.Errorf("recommendGrain(%v) = %s %q (%.2f dB), want %s %q",
+					tc.samples, est.Class, est.Params(), est.PSNR, tc.class, tc.params)
+			}
+		})
+	}
+
+	if recommendGrain(nil) != nil {
+		t.Error("no samples should give no recommendation")
+	}
+}
+
+func TestFilmGrainDenoiseParam(t *testing.T) {
+	cfg := config.GetProfile(config.ProfileFilm)
+	e := &Encoder{Config: cfg}
+	if got := e.filmGrainDenoiseParam(); got != "" {
+		t.Errorf("unset denoise should defer to SVT-AV1, got %q", got)
+	}
+	e.Config.FilmGrainDenoise = 0
+	if got := e.filmGrainDenoiseParam(); got != ":film-grain-denoise=0" {
+		t.Errorf("filmGrainDenoiseParam = %q", got)
+	}
+	e.Config.FilmGrain = 0
+	if got := e.filmGrainDenoiseParam(); got != "" {
+		t.Errorf("denoise without grain synthesis should be omitted, got %q", got)
+	}
+}
@@ -0,0 +1,190 @@
+package encoder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+const (
+	// scanSamples is how many segments idet looks at
+	scanSamples = 6
+	// scanSampleSeconds is the length of each idet segment
+	scanSampleSeconds = 10
+	// telecineMinRepeated is the share of frames with a repeated field that proves pulldown;
+	// 3:2 repeats a field in one of every five frames (20%), true interlacing almost never does
+	telecineMinRepeated = 0.15
+	// telecineCycle is the pulldown cadence decimate removes: one duplicate in every five frames
+	telecineCycle = 5
+)
+
+// ScanType classifies how the source video was scanned
+type ScanType string
+
+const (
+	ScanProgressive   ScanType = "progressive"
+	ScanInterlacedTFF ScanType = "interlaced (TFF)"
+	ScanInterlacedBFF ScanType = "interlaced (BFF)"
+	ScanTelecined     ScanType = "telecined"
+)
+
+var (
+	idetMultiRe    = regexp.MustCompile(`Multi frame detection:\s*TFF:\s*(\d+)\s*BFF:\s*(\d+)\s*Progressive:\s*(\d+)\s*Undetermined:\s*(\d+)`)
+	idetRepeatedRe = regexp.MustCompile(`Repeated Fields:\s*Neither:\s*(\d+)\s*Top:\s*(\d+)\s*Bottom:\s*(\d+)`)
+)
+
+// idetCounts holds the frame classifications reported by ffmpeg's idet filter
+type idetCounts struct {
+	TFF, BFF, Progressive, Undetermined int64
+	RepeatedNeither, RepeatedTop        int64
+	RepeatedBottom                      int64
+}
+
+// add accumulates counts from another sample
+func (c *idetCounts) add(o idetCounts) {
+	c.TFF += o.TFF
+	c.BFF += o.BFF
+	c.Progressive += o.Progressive
+	c.Undetermined += o.Undetermined
+	c.RepeatedNeither += o.RepeatedNeither
+	c.RepeatedTop += o.RepeatedTop
+	c.RepeatedBottom += o.RepeatedBottom
+}
+
+// ScanAnalysis is the outcome of interlace detection
+type ScanAnalysis struct {
+	Type   ScanType
+	Counts idetCounts
+	Reason string
+}
+
+// parseIdet extracts the multi-frame and repeated-field summaries idet prints at the end of a run
+func parseIdet(stderr string) (idetCounts, bool) {
+	var c idetCounts
+	m := idetMultiRe.FindAllStringSubmatch(stderr, -1)
+	if len(m) == 0 {
+		return c, false
+	}
+	last := m[len(m)-1]
+	c.TFF, _ = strconv.ParseInt(last[1], 10, 64)
+	c.BFF, _ = strconv.ParseInt(last[2], 10, 64)
+	c.Progressive, _ = strconv.ParseInt(last[3], 10, 64)
+	c.Undetermined, _ = strconv.ParseInt(last[4], 10, 64)
+
+	if r := idetRepeatedRe.FindAllStringSubmatch(stderr, -1); len(r) > 0 {
+		last := r[len(r)-1]
+		c.RepeatedNeither, _ = strconv.ParseInt(last[1], 10, 64)
+		c.RepeatedTop, _ = strconv.ParseInt(last[2], 10, 64)
+		c.RepeatedBottom, _ = strconv.ParseInt(last[3], 10, 64)
+	}
+	return c, true
+}
+
+// classifyScan decides the scan type from accumulated idet counts
+//
+// Pulldown is only recognised by its repeated fields: combed-frame ratios alone can't tell it
+// from true interlacing, whose low-motion shots look progressive to idet, and treating
+// interlaced video as telecine would decimate away real frames. A few stray combed frames
+// are treated as progressive.
+func classifyScan(c idetCounts) ScanAnalysis {
+	decided := c.TFF + c.BFF + c.Progressive
+	if decided == 0 {
+		return ScanAnalysis{Type: ScanProgressive, Counts: c, Reason: "idet could not classify any frames"}
+	}
+
+	interlaced := float64(c.TFF+c.BFF) / float64(decided)
+	var repeated float64
+	if total := c.RepeatedNeither + c.RepeatedTop + c.RepeatedBottom; total > 0 {
+		repeated = float64(c.RepeatedTop+c.RepeatedBottom) / float64(total)
+	}
+
+	result := ScanAnalysis{Counts: c}
+	switch {
+	case interlaced < 0.1:
+		result.Type = ScanProgressive
+	case repeated >= telecineMinRepeated:
+		result.Type = ScanTelecined
+	case c.BFF > c.TFF:
+		result.Type = ScanInterlacedBFF
+	default:
+		result.Type = ScanInterlacedTFF
+	}
+	result.Reason = fmt.Sprintf("%.0f%% combed frames, %.0f%% repeated fields", interlaced*100, repeated*100)
+	return result
+}
+
+// scanFilter returns the filter that makes the source progressive, or "" for progressive input
+// Frame rate is kept for interlaced input (one frame per frame, not per field); telecine is
+// field-matched back to film frames, leftover combing is deinterlaced and duplicates decimated
+func scanFilter(scan ScanType, deinterlacer config.Deinterlacer, fieldOrder string) string {
+	if deinterlacer == "" {
+		deinterlacer = config.DeinterlacerBwdif
+	}
+	switch scan {
+	case ScanInterlacedTFF:
+		return fmt.Sprintf("%s=mode=send_frame:parity=tff", deinterlacer)
+	case ScanInterlacedBFF:
+		return fmt.Sprintf("%s=mode=send_frame:parity=bff", deinterlacer)
+	case ScanTelecined:
+		order := "tff"
+		if fieldOrder == "bb" || fieldOrder == "bt" {
+			order = "bff"
+		}
+		return fmt.Sprintf("fieldmatch=order=%s:combmatch=full,%s=deint=interlaced,decimate=cycle=%d", order, deinterlacer, telecineCycle)
+	}
+	return ""
+}
+
+// frameRateFactor is how the scan filter changes the frame rate: decimate drops one frame per
+// cycle whatever the content, so the factor follows from the cycle it is given
+func (e *Encoder) frameRateFactor() float64 {
+	if e.Scan.Type == ScanTelecined {
+		return float64(telecineCycle-1) / telecineCycle
+	}
+	return 1
+}
+
+// DetectScan runs idet over segments spread through the source and stores the result on the encoder
+// Streams the container flags as progressive are trusted without decoding
+func (e *Encoder) DetectScan() (ScanAnalysis, error) {
+	if e.Source == nil {
+		return ScanAnalysis{}, fmt.Errorf("scan detection needs a probed source")
+	}
+	video, ok := e.Source.VideoStream()
+	if !ok || e.Source.Duration <= 0 {
+		return ScanAnalysis{Type: ScanProgressive, Reason: "no video duration"}, nil
+	}
+	// Most sources are flagged progressive; only interlaced or unflagged ones pay for the idet pass
+	if video.FieldOrder == "progressive" {
+		return ScanAnalysis{Type: ScanProgressive, Reason: "stream flagged progressive"}, nil
+	}
+
+	e.addLog(fmt.Sprintf("Detecting interlacing (%d x %ds samples)", scanSamples, scanSampleSeconds))
+
+	var total idetCounts
+	for i := 0; i < scanSamples; i++ {
+		at := time.Duration(float64(e.Source.Duration) * (float64(i) + 0.5) / scanSamples)
+		stderr, err := e.runFFmpeg("", nil,
+			"-ss", fmt.Sprintf("%.3f", at.Seconds()),
+			"-i", e.InputPath,
+			"-map", fmt.Sprintf("0:%d", video.Index),
+			"-t", strconv.Itoa(scanSampleSeconds),
+			"-vf", "idet",
+			"-f", "null", "-",
+		)
+		if err != nil {
+			return ScanAnalysis{}, fmt.Errorf("idet failed: %w", err)
+		}
+		if counts, ok := parseIdet(stderr); ok {
+			total.add(counts)
+		}
+	}
+
+	result := classifyScan(total)
+	e.Scan = result
+	e.addLog(fmt.Sprintf("Scan: %s (%s)", result.Type, result.Reason))
+	return result, nil
+}
@@ -0,0 +1,100 @@
+package encoder
+
+import (
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func TestParseIdet(t *testing.T) {
+	stderr := "[Parsed_idet_0 @ 0x5581] Repeated Fields: Neither:   230 Top:    10 Bottom:    11\n" +
+		"[Parsed_idet_0 @ 0x5581] Single frame detection: TFF:    40 BFF:     0 Progressive:   150 Undetermined:    61\n" +
+		"[Parsed_idet_0 @ 0x5581] Multi frame detection: TFF:   230 BFF:     2 Progressive:    15 Undetermined:     4\n"
+
+	got, ok := parseIdet(stderr)
+	if !ok {
+		t.Fatal("parseIdet found no summary")
+	}
+	want := idetCounts{TFF: 230, BFF: 2, Progressive: 15, Undetermined: 4,
+		RepeatedNeither: 230, RepeatedTop: 10, RepeatedBottom: 11}
+	if got != want {
+		t.Errorf("parseIdet = %+v, want %+v", got, want)
+	}
+
+	if _, ok := parseIdet("frame=  100 fps=0.0"); ok {
+		t.Error("parseIdet should not match unrelated output")
+	}
+}
+
+func TestClassifyScan(t *testing.T) {
+	tests := []struct {
+		name   string
+		counts idetCounts
+		want   ScanType
+	}{
+		{"progressive film", idetCounts{Progressive: 1400, TFF: 3, RepeatedNeither: 1400}, ScanProgressive},
+		{"1080i broadcast", idetCounts{TFF: 1380, Progressive: 20, RepeatedNeither: 1390, RepeatedTop: 5}, ScanInterlacedTFF},
+		{"bff DV capture", idetCounts{BFF: 1300, TFF: 12, Progressive: 30, RepeatedNeither: 1340}, ScanInterlacedBFF},
+		{"3:2 pulldown DVD", idetCounts{TFF: 560, Progressive: 840, RepeatedNeither: 1120, RepeatedTop: 140, RepeatedBottom: 140}, ScanTelecined},
+		{"low-motion 1080i", idetCounts{TFF: 600, Progressive: 800, RepeatedNeither: 1395, RepeatedTop: 5}, ScanInterlacedTFF},
+		{"pulldown with few combed frames", idetCounts{TFF: 200, Progressive: 1200, RepeatedNeither: 1120, RepeatedTop: 140, RepeatedBottom: 140}, ScanTelecined},
+		{"nothing classified", idetCounts{Undetermined: 500}, ScanProgressive},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := classifyScan(tc.counts); got.Type != tc.want {
+				t.Errorf("classifyScan = %s (%s), want %s", got.Type, got.Reason, tc.want)
+			}
+		})
+	}
+}
+
+func TestScanFilter(t *testing.T) {
+	tests := []struct {
+		scan       ScanType
+		d          config.Deinterlacer
+		fieldOrder string
+		want       string
+	}{
+		{ScanProgressive, config.DeinterlacerBwdif, "progressive", ""},
+		{ScanInterlacedTFF, config.DeinterlacerBwdif, "tt", "bwdif=mode=send_frame:parity=tff"},
+		{ScanInterlacedBFF, config.DeinterlacerYadif, "bb", "yadif=mode=send_frame:parity=bff"},
+		{ScanTelecined, config.DeinterlacerBwdif, "tt", "fieldmatch=order=tff:combmatch=full,bwdif=deint=interlaced,decimate=cycle=5"},
+		{ScanTelecined, "", "bb", "fieldmatch=order=bff:combmatch=full,bwdif=deint=interlaced,decimate=cycle=5"},
+	}
+	for _, tc := range tests {
+		if got := scanFilter(tc.scan, tc.d, tc.fieldOrder); got != tc.want {
+			t.Errorf("scanFilter(%s, %s, %s) = %q, want %q", tc.scan, tc.d, tc.fieldOrder, got, tc.want)
+		}
+	}
+}
+
+func TestApplyFrameRateFactor_Telecine(t *testing.T) {
+	e := &Encoder{Scan: ScanAnalysis{Type: ScanTelecined}}
+	e.Progress.SourceFPS = 30000.0 / 1001.0
+	e.Progress.TotalFrames = 10000
+
+	e.applyFrameRateFactor()
+
+	if fps := e.Progress.SourceFPS; fps < 23.97 || fps > 23.98 {
+		t.Errorf("SourceFPS = %f, want ~23.976 after decimation", fps)
+	}
+	if e.Progress.TotalFrames != 8000 || !e.Progress.FrameEstimated {
+		t.Errorf("TotalFrames = %d (estimated %v), want 8000 estimated", e.Progress.TotalFrames, e.Progress.FrameEstimated)
+	}
+}
+
+func TestDetectScan_TrustsProgressiveFlag(t *testing.T) {
+	e := New("/media/movie.mkv", config.DefaultConfig())
+	e.Source = &MediaInfo{Duration: time.Hour, Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "hevc", FieldOrder: "progressive"},
+	}}
+
+	// No idet decode runs, so this works without ffmpeg
+	scan, err := e.DetectScan()
+	if err != nil || scan.Type != ScanProgressive {
+		t.Errorf("DetectScan = %+v, %v; want progressive from the stream flag", scan, err)
+	}
+}
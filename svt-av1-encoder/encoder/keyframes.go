@@ -0,0 +1,85 @@
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// fallbackKeyframeFPS is assumed when the frame rate is unknown, matching the old fixed -g 240
+const fallbackKeyframeFPS = 24.0
+
+// gopFrames converts a keyframe interval in seconds to frames at the given rate
+func gopFrames(fps, seconds float64) int {
+	if fps <= 0 {
+		fps = fallbackKeyframeFPS
+	}
+	return max(1, int(math.Round(fps*seconds)))
+}
+
+// keyframeFPS is the rate the encoder receives, after any inverse telecine
+func (e *Encoder) keyframeFPS() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.Progress.SourceFPS
+}
+
+// keyframeArgs sets the GOP length for the fixed mode and, with chapters, forces keyframes at chapter starts
+// In SVT mode the interval is passed through svtav1-params instead (see svtKeyframeParams)
+func (e *Encoder) keyframeArgs(chapters bool) []string {
+	var args []string
+
+	if e.Config.KeyframeMode != config.KeyframesSVT {
+		// libsvtav1 only reads the GOP length; it has no minimum interval and, without scd, no scene-change keyframes
+		args = append(args, "-g", strconv.Itoa(gopFrames(e.keyframeFPS(), e.Config.GOPSeconds)))
+	}
+
+	if times := e.chapterKeyframeTimes(); chapters && len(times) > 0 {
+		args = append(args, "-force_key_frames", strings.Join(times, ","))
+	}
+
+	return args
+}
+
+// svtKeyframeParams delegates keyframe placement to SVT-AV1: a keyint in seconds plus its scene-change detection
+func (e *Encoder) svtKeyframeParams() string {
+	if e.Config.KeyframeMode != config.KeyframesSVT {
+		return ""
+	}
+	return fmt.Sprintf(":keyint=%ss:scd=1", strconv.FormatFloat(e.Config.GOPSeconds, 'f', -1, 64))
+}
+
+// chapterKeyframeTimes lists chapter start times in seconds for -force_key_frames, so
+// chapter skips land on a keyframe; the first chapter starts on one anyway
+func (e *Encoder) chapterKeyframeTimes() []string {
+	if !e.Config.ChapterKeyframes || !e.Config.KeepChapters || e.Source == nil {
+		return nil
+	}
+	var times []string
+	for _, c := range e.Source.Chapters {
+		if c.Start <= 0 {
+			continue
+		}
+		times = append(times, strconv.FormatFloat(c.Start.Seconds(), 'f', 3, 64))
+	}
+	return times
+}
+
+// describeKeyframes summarizes the keyframe settings for the analysis notes
+func (e *Encoder) describeKeyframes() string {
+	var desc string
+	if e.Config.KeyframeMode == config.KeyframesSVT {
+		desc = fmt.Sprintf("SVT-AV1 keyint %gs with scene-change detection", e.Config.GOPSeconds)
+	} else {
+		fps := e.keyframeFPS()
+		desc = fmt.Sprintf("every %d frames (%gs at %.3f fps)",
+			gopFrames(fps, e.Config.GOPSeconds), e.Config.GOPSeconds, fps)
+	}
+	if n := len(e.chapterKeyframeTimes()); n > 0 {
+		desc += fmt.Sprintf(", forced at %d chapter starts", n)
+	}
+	return desc
+}
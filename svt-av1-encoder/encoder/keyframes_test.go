@@ -0,0 +1,61 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func TestGopFrames(t *testing.T) {
+	tests := []struct {
+		fps, seconds float64
+		want         int
+	}{
+		{24, 10, 240},
+		{24000.0 / 1001, 10, 240},
+		{60, 10, 600},
+		{25, 2, 50},
+		{0, 10, 240}, // unknown rate falls back to 24 fps
+		{0.5, 1, 1},
+	}
+	for _, tc := range tests {
+		if got := gopFrames(tc.fps, tc.seconds); got != tc.want {
+			t.Errorf("gopFrames(%v, %v) = %d, want %d", tc.fps, tc.seconds, got, tc.want)
+		}
+	}
+}
+
+func TestKeyframeArgs(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ChapterKeyframes = true
+	e := &Encoder{Config: cfg, Source: &MediaInfo{Chapters: []Chapter{
+		{Start: 0}, {Start: 300500 * time.Millisecond}, {Start: 1200 * time.Second},
+	}}}
+	e.Progress.SourceFPS = 60
+
+	args := strings.Join(e.keyframeArgs(true), " ")
+	if args != "-g 600 -force_key_frames 300.500,1200.000" {
+		t.Errorf("fixed keyframeArgs = %q", args)
+	}
+	if args := strings.Join(e.keyframeArgs(false), " "); args != "-g 600" {
+		t.Errorf("keyframeArgs without chapters = %q", args)
+	}
+	if e.svtKeyframeParams() != "" {
+		t.Errorf("fixed mode should not add svt keyint params")
+	}
+
+	e.Config.KeyframeMode = config.KeyframesSVT
+	e.Config.GOPSeconds = 5
+	e.Config.KeepChapters = false
+	if args := e.keyframeArgs(true); len(args) != 0 {
+		t.Errorf("svt mode without chapters should leave -g unset, got %v", args)
+	}
+	if got := e.svtKeyframeParams(); got != ":keyint=5s:scd=1" {
+		t.Errorf("svtKeyframeParams = %q", got)
+	}
+	if !strings.HasSuffix(e.svtParams(), ":keyint=5s:scd=1") {
+		t.Errorf("svtParams %q missing keyint", e.svtParams())
+	}
+}
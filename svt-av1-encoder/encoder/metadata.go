@@ -0,0 +1,166 @@
+package encoder
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// statisticsTags are per-track statistics written by mkvmerge; they describe the source
+// bitstream and become wrong once a stream is re-encoded
+var statisticsTags = []string{
+	"bps", "duration", "number_of_frames", "number_of_bytes",
+	"_statistics_tags", "_statistics_writing_app", "_statistics_writing_date_utc",
+}
+
+// CoverArt is an embedded picture re-attached to the output as a Matroska attachment
+type CoverArt struct {
+	Source   StreamInfo
+	Filename string // Attachment name in the output, e.g. cover.jpg
+	MimeType string
+}
+
+// isCoverArt reports whether a video stream is an embedded picture rather than real video
+func isCoverArt(s StreamInfo) bool {
+	return s.CodecType == "video" && s.Disposition["attached_pic"] > 0
+}
+
+// isFontAttachment reports whether an attachment is a font used by ASS subtitles
+func isFontAttachment(s StreamInfo) bool {
+	mime := strings.ToLower(s.Tags["mimetype"])
+	name := strings.ToLower(s.Tags["filename"])
+	return strings.Contains(mime, "font") ||
+		strings.HasSuffix(name, ".ttf") || strings.HasSuffix(name, ".otf") || strings.HasSuffix(name, ".ttc")
+}
+
+// coverDropReason decides whether cover art can be re-attached, returning "" to keep it
+func (e *Encoder) coverDropReason() string {
+	switch {
+	case !e.Config.KeepCoverArt:
+		return "cover art"
+	case e.Config.Container != config.ContainerMKV && e.Config.Container != "":
+		return "cover art only re-attached in mkv"
+	}
+	return ""
+}
+
+// planCover names a kept cover picture; Matroska players look for cover.jpg/cover.png
+func planCover(plan *StreamPlan, s StreamInfo) {
+	ext, mime := "jpg", "image/jpeg"
+	if s.CodecName == "png" {
+		ext, mime = "png", "image/png"
+	}
+
+	name := s.Tags["filename"]
+	if name == "" {
+		name = "cover." + ext
+		if n := len(plan.Covers); n > 0 {
+			name = fmt.Sprintf("cover-%d.%s", n+1, ext)
+		}
+	}
+	plan.Covers = append(plan.Covers, CoverArt{Source: s, Filename: name, MimeType: mime})
+}
+
+// coverPath is where a cover is extracted before the encode; until then only the name is known
+func (e *Encoder) coverPath(c CoverArt) string {
+	if e.coverDir == "" {
+		return c.Filename
+	}
+	return filepath.Join(e.coverDir, c.Filename)
+}
+
+// extractCoverArt copies each planned cover picture to a temp dir so -attach can embed it
+func (e *Encoder) extractCoverArt() error {
+	covers := e.planStreams().Covers
+	if len(covers) == 0 {
+		return nil
+	}
+
+	dir, err := os.MkdirTemp("", "svt-av1-cover-")
+	if err != nil {
+		return fmt.Errorf("cannot create cover art dir: %w", err)
+	}
+	e.coverDir = dir
+
+	for _, c := range covers {
+		if _, err := e.runFFmpeg("", nil,
+			"-v", "error",
+			"-i", e.InputPath,
+			"-map", fmt.Sprintf("0:%d", c.Source.Index),
+			"-c", "copy",
+			"-frames:v", "1",
+			"-y", e.coverPath(c),
+		); err != nil {
+			e.removeCoverArt()
+			return fmt.Errorf("cover art extraction failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// removeCoverArt deletes the extracted covers once ffmpeg has embedded them
+func (e *Encoder) removeCoverArt() {
+	if e.coverDir != "" {
+		os.RemoveAll(e.coverDir)
+		e.coverDir = ""
+	}
+}
+
+// metadataArgs maps chapters, container and per-stream tags and attaches cover art explicitly
+func (e *Encoder) metadataArgs(plan StreamPlan) []string {
+	var args []string
+
+	if e.Config.KeepChapters {
+		args = append(args, "-map_chapters", "0")
+	} else {
+		args = append(args, "-map_chapters", "-1")
+	}
+
+	// Global tags are optional; per-stream tags carry languages and titles, so they always follow their stream
+	if e.Config.KeepMetadata {
+		args = append(args, "-map_metadata", "0")
+	} else {
+		args = append(args, "-map_metadata", "-1")
+	}
+	for i, o := range plan.Outputs {
+		spec := fmt.Sprintf("s:%d", i)
+		args = append(args, "-map_metadata:"+spec, fmt.Sprintf("0:s:%d", o.Source.Index))
+		if o.Codec == "copy" {
+			continue
+		}
+		for key := range o.Source.Tags {
+			if isStatisticsTag(key) {
+				args = append(args, "-metadata:"+spec, strings.ToUpper(key)+"=")
+			}
+		}
+	}
+
+	attachments := plan.Count("attachment")
+	if attachments > 0 {
+		args = append(args, "-c:t", "copy")
+	}
+	for i, c := range plan.Covers {
+		spec := fmt.Sprintf("t:%d", attachments+i)
+		args = append(args,
+			"-attach", e.coverPath(c),
+			"-metadata:s:"+spec, "mimetype="+c.MimeType,
+			"-metadata:s:"+spec, "filename="+c.Filename,
+		)
+	}
+
+	return args
+}
+
+// isStatisticsTag matches statistics tags, including language-suffixed variants like BPS-eng
+func isStatisticsTag(key string) bool {
+	key, _, _ = strings.Cut(strings.ToLower(key), "-")
+	for _, t := range statisticsTags {
+		if key == t {
+			return true
+		}
+	}
+	return false
+}
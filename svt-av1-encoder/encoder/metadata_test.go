@@ -0,0 +1,68 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestMetadataArgs(t *testing.T) {
+	info, err := parseProbeOutput([]byte(sampleProbeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := &Encoder{Config: config.DefaultConfig(), Source: info, coverDir: "/tmp/covers"}
+
+	plan := e.planStreams()
+	if len(plan.Covers) != 1 || plan.Covers[0].Filename != "cover.jpg" || plan.Covers[0].MimeType != "image/jpeg" {
+		t.Fatalf("covers = %+v, want the mjpeg picture as cover.jpg", plan.Covers)
+	}
+
+	args := strings.Join(e.metadataArgs(plan), " ")
+	for _, want := range []string{
+		"-map_chapters 0",
+		"-map_metadata 0",
+		"-map_metadata:s:0 0:s:0 -metadata:s:0 BPS=", // re-encoded video loses stale statistics
+		"-map_metadata:s:2 0:s:2 -map_metadata:s:3 0:s:4",
+		"-c:t copy",
+		"-attach /tmp/covers/cover.jpg -metadata:s:t:1 mimetype=image/jpeg -metadata:s:t:1 filename=cover.jpg",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("metadataArgs %q missing %q", args, want)
+		}
+	}
+
+	e.Config.KeepChapters = false
+	e.Config.KeepMetadata = false
+	args = strings.Join(e.metadataArgs(plan), " ")
+	if !strings.Contains(args, "-map_chapters -1") || !strings.Contains(args, "-map_metadata -1") {
+		t.Errorf("disabled chapters/metadata not unmapped: %q", args)
+	}
+}
+
+func TestCoverArtInMP4(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Container = config.ContainerMP4
+	e := &Encoder{Config: cfg, Source: &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		{Index: 1, CodecType: "video", CodecName: "png", Disposition: map[string]int{"attached_pic": 1}},
+		{Index: 2, CodecType: "attachment", Tags: map[string]string{"mimetype": "application/x-truetype-font"}},
+	}}}
+
+	plan := e.planStreams()
+	if len(plan.Covers) != 0 || len(plan.Dropped) != 2 {
+		t.Fatalf("mp4 should drop cover and font, got covers %+v dropped %+v", plan.Covers, plan.Dropped)
+	}
+	if plan.Dropped[1].Reason != "fonts not supported in mp4" {
+		t.Errorf("font drop reason = %q", plan.Dropped[1].Reason)
+	}
+}
+
+func TestIsStatisticsTag(t *testing.T) {
+	for key, want := range map[string]bool{"bps": true, "BPS-eng": true, "number_of_frames": true, "title": false, "language": false} {
+		if got := isStatisticsTag(key); got != want {
+			t.Errorf("isStatisticsTag(%q) = %v, want %v", key, got, want)
+		}
+	}
+}
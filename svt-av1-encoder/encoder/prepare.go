@@ -0,0 +1,152 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// Prepare probes the source and runs the configured analysis passes before encoding
+// It returns short notes describing each decision, for display alongside progress and in dry runs
+func (e *Encoder) Prepare() ([]string, error) {
+	// Probe streams and chapters so the output can be planned and verified against them
+	// (callers may already have probed, e.g. to check provenance)
+	if e.Source == nil {
+		if err := e.Probe(); err != nil {
+			return nil, err
+		}
+	}
+
+	var notes []string
+	if bitrate := e.describeSourceBitrate(); bitrate != "" {
+		notes = append(notes, "Source: "+bitrate)
+	}
+
+	// Auto profile selection comes first: the chosen profile sets the resolution cap and grain mode used below
+	if e.Config.ProfileName == config.ProfileAuto {
+		choice, err := e.SelectProfile()
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes,
+			fmt.Sprintf("Profile: %s (auto, rule: %s)", choice.Profile, choice.Rule),
+			"Content: "+choice.Content.Summary(),
+		)
+	}
+
+	// Classify interlacing first; field matching changes the frame rate used below
+	if e.Config.DeinterlaceMode == config.DeinterlaceAuto {
+		scan, err := e.DetectScan()
+		if err != nil {
+			return nil, err
+		}
+		if scan.Type != ScanProgressive {
+			notes = append(notes, fmt.Sprintf("Scan: %s (%s)", scan.Type, scan.Reason))
+		}
+	}
+
+	// Frame timing is checked on the source; CFR conversion runs after any field matching
+	vfr, err := e.DetectVFR()
+	if err != nil {
+		return nil, err
+	}
+	if vfr.VFR || vfr.Convert() {
+		notes = append(notes, fmt.Sprintf("Frame rate: %s (%s)", vfr.Describe(), vfr.Reason))
+	}
+
+	// Detect black bars before the encode so the crop is part of the command
+	if e.Config.CropMode != config.CropOff {
+		crop, err := e.DetectCrop()
+		if err != nil {
+			return nil, err
+		}
+		if crop.Crop != nil {
+			notes = append(notes, fmt.Sprintf("Crop: %s (%s)", crop.Crop, crop.Reason))
+		} else {
+			notes = append(notes, fmt.Sprintf("Crop: none (%s)", crop.Reason))
+		}
+	}
+
+	if w, h, ok := e.ScaledSize(); ok {
+		notes = append(notes, fmt.Sprintf("Scale: %dx%d (%s, cap %dx%d)",
+			w, h, e.Config.Scaler, e.Config.MaxWidth, e.Config.MaxHeight))
+	}
+
+	if len(e.Config.PreFilters) > 0 {
+		notes = append(notes, "Filters: "+config.FiltersString(e.Config.PreFilters))
+	}
+
+	// Grain is measured on the final geometry, so it runs after crop and scale are decided
+	if e.Config.GrainMode != config.GrainOff {
+		grain, err := e.DetectGrain()
+		if err != nil {
+			return nil, err
+		}
+		if grain != nil {
+			note := fmt.Sprintf("Grain: %s (%.2f dB vs denoised)", grain.Class, grain.PSNR)
+			if e.Config.GrainMode == config.GrainApply {
+				note += " → " + grain.Params()
+			} else {
+				note += fmt.Sprintf(" → suggest %s (profile uses film-grain=%d)", grain.Params(), e.Config.FilmGrain)
+			}
+			notes = append(notes, note)
+		}
+	}
+
+	plan := e.planStreams()
+	if audio := summarizeAudio(plan); audio != "" {
+		notes = append(notes, "Audio: "+audio)
+	}
+	if defaults := describeDefaults(plan); defaults != "" {
+		notes = append(notes, "Default: "+defaults)
+	}
+
+	// Get total frames for progress calculation
+	if err := e.GetTotalFrames(); err != nil {
+		return nil, err
+	}
+
+	notes = append(notes, "Keyframes: "+e.describeKeyframes())
+
+	if companion := e.describeSDRCompanion(); companion != "" {
+		notes = append(notes, "SDR companion: "+companion)
+	}
+	if e.IsSDRCompanion() {
+		notes = append(notes, "Tonemap: "+string(e.toneMap)+" → BT.709 SDR")
+	}
+
+	if e.Config.WriteProvenance {
+		if err := e.recordProvenance(); err != nil {
+			return nil, err
+		}
+		notes = append(notes, "SVT-AV1: "+e.Provenance.SVTVersion)
+	}
+
+	return notes, nil
+}
+
+// summarizeAudio condenses the audio decisions into one line, e.g. "1 copied, 1 → libopus 384k"
+// It returns "" when every track is copied, since that needs no attention
+func summarizeAudio(plan StreamPlan) string {
+	copied := 0
+	var transcoded []string
+	for _, o := range plan.Outputs {
+		if o.Source.CodecType != "audio" {
+			continue
+		}
+		if o.Codec == "copy" {
+			copied++
+		} else {
+			transcoded = append(transcoded, fmt.Sprintf("%s → %s %dk", o.Source.CodecName, o.Codec, o.Bitrate))
+		}
+	}
+	if len(transcoded) == 0 {
+		return ""
+	}
+	summary := strings.Join(transcoded, ", ")
+	if copied > 0 {
+		summary = fmt.Sprintf("%d copied, %s", copied, summary)
+	}
+	return summary
+}
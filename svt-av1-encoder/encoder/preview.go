@@ -0,0 +1,172 @@
+package encoder
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// PreviewClip is one sample encoded by EncodePreview
+type PreviewClip struct {
+	Path     string
+	Start    time.Duration // Position in the source
+	Duration time.Duration // Encoded length (shorter than requested near the end)
+	Size     int64
+	Elapsed  time.Duration // Wall time spent encoding
+}
+
+// Bitrate is the clip's overall bitrate in bits/s
+func (c PreviewClip) Bitrate() int64 {
+	if c.Duration <= 0 {
+		return 0
+	}
+	return int64(float64(c.Size*8) / c.Duration.Seconds())
+}
+
+// PreviewReport projects the full encode from the sample clips
+type PreviewReport struct {
+	Clips            []PreviewClip
+	SourceDuration   time.Duration
+	SourceSize       int64
+	ProjectedSize    int64
+	ProjectedBitrate int64         // bits/s
+	Speed            float64       // Media seconds encoded per wall second
+	ProjectedTime    time.Duration // Estimated wall time of the full encode
+}
+
+// Lines formats the report for the terminal
+func (r *PreviewReport) Lines() []string {
+	var lines []string
+	for i, c := range r.Clips {
+		lines = append(lines, fmt.Sprintf("Clip %d at %s: %s in %s (%s, %.2fx) → %s",
+			i+1, formatTimestamp(c.Start), formatSize(c.Size), c.Elapsed.Round(time.Second),
+			formatBitrate(c.Bitrate()), c.Duration.Seconds()/c.Elapsed.Seconds(), c.Path))
+	}
+	projected := fmt.Sprintf("Projected size: %s (%s)", formatSize(r.ProjectedSize), formatBitrate(r.ProjectedBitrate))
+	if r.SourceSize > 0 {
+		projected += fmt.Sprintf(", %.1f%% of source", float64(r.ProjectedSize)/float64(r.SourceSize)*100)
+	}
+	lines = append(lines,
+		projected,
+		fmt.Sprintf("Encode speed: %.2fx, about %s for the full %s",
+			r.Speed, r.ProjectedTime.Round(time.Minute), r.SourceDuration.Round(time.Second)),
+	)
+	return lines
+}
+
+// previewStarts spreads n clips of the given length evenly through the source, each centred
+// on its share of the timeline and kept inside the source
+func previewStarts(duration, length time.Duration, n int) []time.Duration {
+	if n <= 0 || duration <= 0 {
+		return nil
+	}
+	if length >= duration {
+		return []time.Duration{0}
+	}
+	starts := make([]time.Duration, n)
+	for i := range starts {
+		centre := time.Duration(float64(duration) * (float64(i) + 0.5) / float64(n))
+		start := centre - length/2
+		start = max(0, min(start, duration-length))
+		starts[i] = start
+	}
+	return starts
+}
+
+// projectPreview scales the clips' size and speed up to the full source duration
+func projectPreview(clips []PreviewClip, sourceDuration time.Duration) *PreviewReport {
+	report := &PreviewReport{Clips: clips, SourceDuration: sourceDuration}
+	var size int64
+	var media, wall time.Duration
+	for _, c := range clips {
+		size += c.Size
+		media += c.Duration
+		wall += c.Elapsed
+	}
+	if media <= 0 || wall <= 0 {
+		return report
+	}
+	scale := sourceDuration.Seconds() / media.Seconds()
+	report.ProjectedSize = int64(float64(size) * scale)
+	report.ProjectedBitrate = int64(float64(size*8) / media.Seconds())
+	report.Speed = media.Seconds() / wall.Seconds()
+	report.ProjectedTime = time.Duration(float64(sourceDuration) / report.Speed)
+	return report
+}
+
+// previewArgs encodes one clip with the same video settings as the full encode
+// Only video and audio are kept: they are what the size projection needs
+func (e *Encoder) previewArgs(start, length time.Duration, path string) []string {
+	args := []string{
+		"-ss", fmt.Sprintf("%.3f", start.Seconds()),
+		"-t", fmt.Sprintf("%.3f", length.Seconds()),
+		"-i", e.InputPath,
+	}
+	var plan StreamPlan
+	for _, o := range e.planStreams().Outputs {
+		if o.Source.CodecType == "video" || o.Source.CodecType == "audio" {
+			plan.Outputs = append(plan.Outputs, o)
+		}
+	}
+	args = append(args, plan.streamArgs()...)
+	args = append(args, "-map_metadata", "-1", "-map_chapters", "-1")
+	// The clip is seeked on input, so its timestamps restart at 0 and chapter starts don't apply
+	args = append(args, e.videoArgs(false)...)
+	return append(args, "-y", path)
+}
+
+// EncodePreview encodes the configured number of sample clips and projects the full encode
+// Call after Prepare so the clips use the same crop, scaling and filters; the clips are kept
+// next to the output for inspection
+func (e *Encoder) EncodePreview() (*PreviewReport, error) {
+	if e.Source == nil || e.Source.Duration <= 0 {
+		return nil, fmt.Errorf("preview needs a probed source with a known duration")
+	}
+	length := time.Duration(e.Config.PreviewSeconds * float64(time.Second))
+	if length <= 0 {
+		return nil, fmt.Errorf("preview clip length must be positive")
+	}
+
+	var clips []PreviewClip
+	for i, start := range previewStarts(e.Source.Duration, length, e.Config.PreviewClips) {
+		path := suffixedOutputPath(e.OutputPath, fmt.Sprintf(".preview%d", i+1))
+		e.addLog(fmt.Sprintf("Preview clip %d at %s → %s", i+1, formatTimestamp(start), path))
+
+		began := time.Now()
+		if _, err := e.runFFmpeg("", nil, e.previewArgs(start, length, path)...); err != nil {
+			return nil, fmt.Errorf("preview clip %d: %w", i+1, err)
+		}
+		clip := PreviewClip{Path: path, Start: start, Duration: length, Elapsed: time.Since(began)}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("preview clip %d: %w", i+1, err)
+		}
+		clip.Size = info.Size()
+		// The last clip can run past the end of the source
+		if probed, err := ProbeFile(e.ctx, path); err == nil && probed.Duration > 0 {
+			clip.Duration = probed.Duration
+		}
+		clips = append(clips, clip)
+	}
+
+	report := projectPreview(clips, e.Source.Duration)
+	if info, err := os.Stat(e.InputPath); err == nil {
+		report.SourceSize = info.Size()
+	}
+	return report, nil
+}
+
+// formatTimestamp formats a source position as H:MM:SS
+func formatTimestamp(d time.Duration) string {
+	s := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// formatSize formats a byte count in MiB or GiB
+func formatSize(bytes int64) string {
+	if bytes >= 1<<30 {
+		return fmt.Sprintf("%.2f GiB", float64(bytes)/(1<<30))
+	}
+	return fmt.Sprintf("%.1f MiB", float64(bytes)/(1<<20))
+}
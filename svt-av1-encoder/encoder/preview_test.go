@@ -0,0 +1,87 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func TestPreviewStarts(t *testing.T) {
+	starts := previewStarts(100*time.Minute, 20*time.Second, 5)
+	want := []time.Duration{590 * time.Second, 1790 * time.Second, 2990 * time.Second, 4190 * time.Second, 5390 * time.Second}
+	if len(starts) != len(want) {
+		t.Fatalf("got %d starts, want %d", len(starts), len(want))
+	}
+	for i := range want {
+		if starts[i] != want[i] {
+			t.Errorf("start %d = %s, want %s", i, starts[i], want[i])
+		}
+	}
+
+	// Short sources: clips stay inside the source, or one clip covers it all
+	for _, s := range previewStarts(30*time.Second, 20*time.Second, 3) {
+		if s < 0 || s > 10*time.Second {
+			t.Errorf("start %s outside a 30s source", s)
+		}
+	}
+	if starts := previewStarts(10*time.Second, 20*time.Second, 5); len(starts) != 1 || starts[0] != 0 {
+		t.Errorf("source shorter than a clip: starts = %v", starts)
+	}
+}
+
+func TestProjectPreview(t *testing.T) {
+	clips := []PreviewClip{
+		{Duration: 20 * time.Second, Size: 5 << 20, Elapsed: 40 * time.Second},
+		{Duration: 20 * time.Second, Size: 3 << 20, Elapsed: 40 * time.Second},
+	}
+	r := projectPreview(clips, 100*time.Minute)
+	if r.ProjectedSize != 8<<20*150 {
+		t.Errorf("ProjectedSize = %d, want %d", r.ProjectedSize, 8<<20*150)
+	}
+	if r.Speed != 0.5 || r.ProjectedTime != 200*time.Minute {
+		t.Errorf("Speed = %v, ProjectedTime = %s; want 0.5 and 3h20m", r.Speed, r.ProjectedTime)
+	}
+	if r.ProjectedBitrate != 8<<20*8/40 {
+		t.Errorf("ProjectedBitrate = %d", r.ProjectedBitrate)
+	}
+}
+
+func TestPreviewArgs(t *testing.T) {
+	info, err := parseProbeOutput([]byte(sampleProbeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := New("/media/movie.mkv", config.DefaultConfig())
+	e.Source = info
+
+	args := strings.Join(e.previewArgs(90*time.Second, 20*time.Second, "/media/movie.preview1.av1.mkv"), " ")
+	if !strings.HasPrefix(args, "-ss 90.000 -t 20.000 -i /media/movie.mkv") {
+		t.Errorf("clip should seek before the input: %s", args)
+	}
+	if strings.Contains(args, "-c:s") || strings.Contains(args, "-attach") || strings.Contains(args, "SVTAV1ENC") {
+		t.Errorf("clip should only carry video and audio: %s", args)
+	}
+	if !strings.Contains(args, "-c:v libsvtav1") || !strings.HasSuffix(args, "-y /media/movie.preview1.av1.mkv") {
+		t.Errorf("clip args = %s", args)
+	}
+}
+
+func TestPreviewArgs_NoChapterKeyframes(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.ChapterKeyframes = true
+	e := New("/media/movie.mkv", cfg)
+	e.Source = &MediaInfo{
+		Streams:  []StreamInfo{{Index: 0, CodecType: "video", CodecName: "hevc"}},
+		Chapters: []Chapter{{Start: 0}, {Start: 100 * time.Second}},
+	}
+
+	if !strings.Contains(e.CommandLine(), "-force_key_frames 100.000") {
+		t.Fatalf("the full encode should force chapter keyframes: %s", e.CommandLine())
+	}
+	// Timestamps in the clip start at 0, so source chapter times would land on the wrong frames
+	if args := strings.Join(e.previewArgs(90*time.Second, 20*time.Second, "/media/movie.preview1.av1.mkv"), " "); strings.Contains(args, "-force_key_frames") {
+		t.Errorf("clip should not force chapter keyframes: %s", args)
+	}
+}
@@ -0,0 +1,289 @@
+package encoder
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamInfo describes one stream reported by ffprobe
+type StreamInfo struct {
+	Index        int
+	CodecType    string // video, audio, subtitle, data, attachment
+	CodecName    string
+	Profile      string // e.g. "DTS-HD MA"
+	Language     string
+	Title        string
+	Channels     int
+	Width        int
+	Height       int
+	PixFmt       string
+	FieldOrder   string // progressive, tt, bb, tb, bt (empty if unknown)
+	FrameRate    string // r_frame_rate, e.g. "24000/1001"
+	AvgFrameRate string
+	BitRate      int64 // bits/s, 0 if unknown
+	NbFrames     int64 // 0 if the container doesn't record it
+	// Colour description, used to classify HDR
+	ColorTransfer  string   // e.g. smpte2084 (PQ), arib-std-b67 (HLG), bt709
+	ColorPrimaries string   // e.g. bt2020, bt709
+	SideData       []string // Stream side data types, e.g. "DOVI configuration record"
+	Disposition    map[string]int
+	Tags           map[string]string
+}
+
+// Chapter is a chapter marker from the container
+type Chapter struct {
+	Start time.Duration
+	End   time.Duration
+	Title string
+}
+
+// MediaInfo is the subset of ffprobe output the encoder plans and verifies against
+type MediaInfo struct {
+	Path       string
+	FormatName string
+	Duration   time.Duration
+	BitRate    int64 // Container total bits/s
+	Size       int64
+	Streams    []StreamInfo
+	Chapters   []Chapter
+	Tags       map[string]string
+}
+
+// ffprobeOutput mirrors the JSON written by ffprobe -print_format json
+// ffprobe reports most numbers as strings, so they are converted afterwards
+type ffprobeOutput struct {
+	Streams []struct {
+		Index        int               `json:"index"`
+		CodecType    string            `json:"codec_type"`
+		CodecName    string            `json:"codec_name"`
+		Profile      string            `json:"profile"`
+		Channels     int               `json:"channels"`
+		Width        int               `json:"width"`
+		Height       int               `json:"height"`
+		PixFmt       string            `json:"pix_fmt"`
+		FieldOrder   string            `json:"field_order"`
+		RFrameRate   string            `json:"r_frame_rate"`
+		AvgFrameRate string            `json:"avg_frame_rate"`
+		BitRate      string            `json:"bit_rate"`
+		NbFrames     string            `json:"nb_frames"`
+		Disposition  map[string]int    `json:"disposition"`
+		Tags         map[string]string `json:"tags"`
+		ColorTrc     string            `json:"color_transfer"`
+		ColorPrim    string            `json:"color_primaries"`
+		SideDataList []struct {
+			Type string `json:"side_data_type"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Chapters []struct {
+		StartTime string            `json:"start_time"`
+		EndTime   string            `json:"end_time"`
+		Tags      map[string]string `json:"tags"`
+	} `json:"chapters"`
+	Format struct {
+		FormatName string            `json:"format_name"`
+		Duration   string            `json:"duration"`
+		BitRate    string            `json:"bit_rate"`
+		Size       string            `json:"size"`
+		Tags       map[string]string `json:"tags"`
+	} `json:"format"`
+}
+
+// ProbeFile runs ffprobe on path and returns its streams, chapters and container info
+func ProbeFile(ctx context.Context, path string) (*MediaInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		"-show_chapters",
+		path,
+	)
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("ffprobe failed for %s: %w", path, err)
+	}
+
+	info, err := parseProbeOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	info.Path = path
+	return info, nil
+}
+
+// parseProbeOutput converts ffprobe JSON into MediaInfo
+func parseProbeOutput(data []byte) (*MediaInfo, error) {
+	var raw ffprobeOutput
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid ffprobe output: %w", err)
+	}
+
+	info := &MediaInfo{
+		FormatName: raw.Format.FormatName,
+		Duration:   parseSeconds(raw.Format.Duration),
+		BitRate:    parseInt(raw.Format.BitRate),
+		Size:       parseInt(raw.Format.Size),
+		Tags:       lowerKeys(raw.Format.Tags),
+	}
+
+	for _, s := range raw.Streams {
+		tags := lowerKeys(s.Tags)
+		var sideData []string
+		for _, sd := range s.SideDataList {
+			sideData = append(sideData, sd.Type)
+		}
+		info.Streams = append(info.Streams, StreamInfo{
+			Index:        s.Index,
+			CodecType:    s.CodecType,
+			CodecName:    s.CodecName,
+			Profile:      s.Profile,
+			Language:     strings.ToLower(tags["language"]),
+			Title:        tags["title"],
+			Channels:     s.Channels,
+			Width:        s.Width,
+			Height:       s.Height,
+			PixFmt:       s.PixFmt,
+			FieldOrder:   s.FieldOrder,
+			FrameRate:    s.RFrameRate,
+			AvgFrameRate: s.AvgFrameRate,
+			BitRate:      parseInt(s.BitRate),
+			NbFrames:     parseInt(s.NbFrames),
+			Disposition:  s.Disposition,
+			Tags:         tags,
+
+			ColorTransfer:  s.ColorTrc,
+			ColorPrimaries: s.ColorPrim,
+			SideData:       sideData,
+		})
+	}
+
+	for _, c := range raw.Chapters {
+		info.Chapters = append(info.Chapters, Chapter{
+			Start: parseSeconds(c.StartTime),
+			End:   parseSeconds(c.EndTime),
+			Title: lowerKeys(c.Tags)["title"],
+		})
+	}
+
+	return info, nil
+}
+
+// StreamsOfType returns all streams with the given codec type, in input order
+func (m *MediaInfo) StreamsOfType(codecType string) []StreamInfo {
+	var streams []StreamInfo
+	for _, s := range m.Streams {
+		if s.CodecType == codecType {
+			streams = append(streams, s)
+		}
+	}
+	return streams
+}
+
+// VideoStream returns the first real video stream, skipping cover art
+func (m *MediaInfo) VideoStream() (StreamInfo, bool) {
+	for _, s := range m.Streams {
+		if s.CodecType == "video" && s.Disposition["attached_pic"] == 0 {
+			return s, true
+		}
+	}
+	return StreamInfo{}, false
+}
+
+// VideoBitrate returns the main video stream's bitrate in bits/s and where it came from:
+// the stream header, Matroska's BPS statistics tag, or the container total minus audio
+// (container bitrates include audio, which would inflate the video figure)
+func (m *MediaInfo) VideoBitrate() (int64, string) {
+	video, ok := m.VideoStream()
+	if !ok {
+		return 0, ""
+	}
+	if video.BitRate > 0 {
+		return video.BitRate, "stream"
+	}
+	if bps := statisticsBitrate(video); bps > 0 {
+		return bps, "BPS tag"
+	}
+
+	total := m.BitRate
+	if total == 0 && m.Size > 0 && m.Duration > 0 {
+		total = int64(float64(m.Size*8) / m.Duration.Seconds())
+	}
+	if total == 0 {
+		return 0, ""
+	}
+	for _, s := range m.StreamsOfType("audio") {
+		audio := s.BitRate
+		if audio == 0 {
+			audio = statisticsBitrate(s)
+		}
+		total -= audio
+	}
+	if total <= 0 {
+		return 0, ""
+	}
+	return total, "container minus audio"
+}
+
+// statisticsBitrate reads mkvmerge's BPS tag, which may carry a language suffix (BPS-eng)
+func statisticsBitrate(s StreamInfo) int64 {
+	for k, v := range s.Tags {
+		if k == "bps" || strings.HasPrefix(k, "bps-") {
+			return parseInt(v)
+		}
+	}
+	return 0
+}
+
+// BitsPerPixel returns the video bitrate normalized per pixel per frame, 0 when unknown
+// It makes sources of different resolutions and frame rates comparable (≈0.1 is a typical Blu-ray encode)
+func (m *MediaInfo) BitsPerPixel() float64 {
+	video, ok := m.VideoStream()
+	if !ok || video.Width == 0 || video.Height == 0 {
+		return 0
+	}
+	fps := parseFrameRate(video.AvgFrameRate)
+	if fps <= 0 {
+		fps = parseFrameRate(video.FrameRate)
+	}
+	bitrate, _ := m.VideoBitrate()
+	if fps <= 0 || bitrate == 0 {
+		return 0
+	}
+	return float64(bitrate) / (float64(video.Width*video.Height) * fps)
+}
+
+// parseSeconds converts ffprobe's decimal seconds ("123.456000") to a duration
+func parseSeconds(s string) time.Duration {
+	secs, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || secs < 0 {
+		return 0
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// parseInt converts ffprobe's numeric strings, treating "N/A" and garbage as 0
+func parseInt(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// lowerKeys normalizes tag names, which vary in case between containers (TITLE vs title)
+func lowerKeys(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags))
+	for k, v := range tags {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
@@ -0,0 +1,96 @@
+package encoder
+
+import (
+	"testing"
+	"time"
+)
+
+const sampleProbeJSON = `{
+	"streams": [
+		{"index": 0, "codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080,
+		 "pix_fmt": "yuv420p", "r_frame_rate": "24000/1001", "avg_frame_rate": "24000/1001",
+		 "disposition": {"default": 1, "attached_pic": 0}, "tags": {"BPS": "8000000"}},
+		{"index": 1, "codec_type": "audio", "codec_name": "truehd", "channels": 8, "bit_rate": "N/A",
+		 "disposition": {"default": 1}, "tags": {"language": "ENG", "title": "TrueHD Atmos 7.1"}},
+		{"index": 2, "codec_type": "subtitle", "codec_name": "hdmv_pgs_subtitle",
+		 "disposition": {"forced": 1}, "tags": {"LANGUAGE": "eng"}},
+		{"index": 3, "codec_type": "video", "codec_name": "mjpeg", "width": 600, "height": 900,
+		 "disposition": {"attached_pic": 1}},
+		{"index": 4, "codec_type": "attachment", "codec_name": "ttf", "tags": {"filename": "font.ttf"}}
+	],
+	"chapters": [
+		{"start_time": "0.000000", "end_time": "300.500000", "tags": {"title": "Opening"}},
+		{"start_time": "300.500000", "end_time": "600.000000", "tags": {"TITLE": "Act 1"}}
+	],
+	"format": {"format_name": "matroska,webm", "duration": "600.000000", "bit_rate": "9500000",
+	           "size": "712500000", "tags": {"ENCODER": "libebml"}}
+}`
+
+func TestParseProbeOutput(t *testing.T) {
+	info, err := parseProbeOutput([]byte(sampleProbeJSON))
+	if err != nil {
+		t.Fatalf("parseProbeOutput error: %v", err)
+	}
+
+	if info.Duration != 600*time.Second {
+		t.Errorf("Duration = %v, want 10m", info.Duration)
+	}
+	if info.BitRate != 9500000 || info.Size != 712500000 {
+		t.Errorf("BitRate/Size = %d/%d, want 9500000/712500000", info.BitRate, info.Size)
+	}
+	if info.Tags["encoder"] != "libebml" {
+		t.Errorf("format tags not lower-cased: %v", info.Tags)
+	}
+	if len(info.Streams) != 5 {
+		t.Fatalf("got %d streams, want 5", len(info.Streams))
+	}
+
+	audio := info.Streams[1]
+	if audio.Language != "eng" || audio.Title != "TrueHD Atmos 7.1" || audio.Channels != 8 || audio.BitRate != 0 {
+		t.Errorf("audio stream parsed as %+v", audio)
+	}
+	if info.Streams[2].Language != "eng" || info.Streams[2].Disposition["forced"] != 1 {
+		t.Errorf("subtitle stream parsed as %+v", info.Streams[2])
+	}
+
+	if len(info.Chapters) != 2 || info.Chapters[1].Title != "Act 1" || info.Chapters[1].Start != 300500*time.Millisecond {
+		t.Errorf("chapters parsed as %+v", info.Chapters)
+	}
+
+	video, ok := info.VideoStream()
+	if !ok || video.Index != 0 {
+		t.Errorf("VideoStream() = %+v, %v; want stream 0", video, ok)
+	}
+	if n := len(info.StreamsOfType("video")); n != 2 {
+		t.Errorf("StreamsOfType(video) = %d streams, want 2 (including cover art)", n)
+	}
+}
+
+func TestParseProbeOutput_Invalid(t *testing.T) {
+	if _, err := parseProbeOutput([]byte("{")); err == nil {
+		t.Error("parseProbeOutput should fail on truncated JSON")
+	}
+}
+
+func TestVideoBitrate(t *testing.T) {
+	info, err := parseProbeOutput([]byte(sampleProbeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sample's video carries mkvmerge's BPS tag
+	if bitrate, source := info.VideoBitrate(); bitrate != 8000000 || source != "BPS tag" {
+		t.Errorf("VideoBitrate = %d from %q, want 8000000 from BPS tag", bitrate, source)
+	}
+	want := 8000000 / (1920 * 1080 * (24000.0 / 1001))
+	if bpp := info.BitsPerPixel(); bpp < want-0.0001 || bpp > want+0.0001 {
+		t.Errorf("BitsPerPixel = %.4f, want %.4f", bpp, want)
+	}
+
+	// Without stream figures the container total is used, minus the audio
+	info.Streams[0].Tags = nil
+	info.Streams[1].BitRate = 1500000
+	if bitrate, source := info.VideoBitrate(); bitrate != 8000000 || source != "container minus audio" {
+		t.Errorf("VideoBitrate = %d from %q, want 8000000 from container minus audio", bitrate, source)
+	}
+}
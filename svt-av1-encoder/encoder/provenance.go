@@ -0,0 +1,227 @@
+package encoder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+// Version identifies this tool in provenance tags; release builds set it with
+// -ldflags "-X svt-av1-encoder/encoder.Version=1.2.0"
+var Version = "dev"
+
+// Provenance tag names, written as global container tags
+const (
+	tagTool       = "SVTAV1ENC_TOOL"
+	tagProfile    = "SVTAV1ENC_PROFILE"
+	tagCRF        = "SVTAV1ENC_CRF"
+	tagPreset     = "SVTAV1ENC_PRESET"
+	tagParams     = "SVTAV1ENC_SVTAV1_PARAMS"
+	tagSVTVersion = "SVTAV1ENC_SVTAV1_VERSION"
+	tagSource     = "SVTAV1ENC_SOURCE"
+	tagSourceSize = "SVTAV1ENC_SOURCE_SIZE"
+	tagSourceHash = "SVTAV1ENC_SOURCE_HASH"
+	tagDate       = "SVTAV1ENC_DATE"
+	tagFallbacks  = "SVTAV1ENC_FALLBACKS"
+)
+
+// quickHashBytes is how much of each end of the source goes into the source hash;
+// hashing whole multi-gigabyte sources would take longer than some encodes
+const quickHashBytes = 4 << 20
+
+// svtVersionRe matches the library banner SVT-AV1 prints when an encoder opens
+var svtVersionRe = regexp.MustCompile(`SVT \[version\]:\s*(.+)`)
+
+// Provenance records how an output was produced
+type Provenance struct {
+	Tool       string // e.g. "svt-av1-encoder 1.2.0"
+	Profile    string
+	CRF        int
+	Preset     int
+	SVTParams  string
+	SVTVersion string
+	Source     string // Source file name without directory
+	SourceSize int64
+	SourceHash string // SHA-256 of the size and the first and last 4 MiB
+	Date       time.Time
+	Fallbacks  string // Changes retries made to get the encode through, "" when none were needed
+}
+
+// tags returns the provenance as tag name/value pairs in a stable order
+func (p *Provenance) tags() [][2]string {
+	tags := [][2]string{
+		{tagTool, p.Tool},
+		{tagProfile, p.Profile},
+		{tagCRF, strconv.Itoa(p.CRF)},
+		{tagPreset, strconv.Itoa(p.Preset)},
+		{tagParams, p.SVTParams},
+		{tagSVTVersion, p.SVTVersion},
+		{tagSource, p.Source},
+		{tagSourceSize, strconv.FormatInt(p.SourceSize, 10)},
+		{tagSourceHash, p.SourceHash},
+		{tagDate, p.Date.UTC().Format(time.RFC3339)},
+	}
+	if p.Fallbacks != "" {
+		tags = append(tags, [2]string{tagFallbacks, p.Fallbacks})
+	}
+	return tags
+}
+
+// Lines formats the provenance for display, one "Name: value" per line
+func (p *Provenance) Lines() []string {
+	lines := []string{
+		"Tool:           " + p.Tool,
+		"Profile:        " + p.Profile,
+		fmt.Sprintf("CRF / preset:   %d / %d", p.CRF, p.Preset),
+		"SVT-AV1 params: " + p.SVTParams,
+		"SVT-AV1:        " + p.SVTVersion,
+		fmt.Sprintf("Source:         %s (%d bytes)", p.Source, p.SourceSize),
+		"Source hash:    " + p.SourceHash,
+		"Encoded:        " + p.Date.Local().Format("2006-01-02 15:04:05"),
+	}
+	if p.Fallbacks != "" {
+		lines = append(lines, "Fallbacks:      "+p.Fallbacks)
+	}
+	return lines
+}
+
+// provenanceFromTags reads provenance back from probed container tags
+// It returns nil when the file wasn't produced by this tool
+func provenanceFromTags(tags map[string]string) *Provenance {
+	// Probed tag names are lower-cased
+	get := func(name string) string { return tags[strings.ToLower(name)] }
+	if get(tagTool) == "" {
+		return nil
+	}
+
+	p := &Provenance{
+		Tool:       get(tagTool),
+		Profile:    get(tagProfile),
+		SVTParams:  get(tagParams),
+		SVTVersion: get(tagSVTVersion),
+		Source:     get(tagSource),
+		SourceSize: parseInt(get(tagSourceSize)),
+		SourceHash: get(tagSourceHash),
+		Fallbacks:  get(tagFallbacks),
+	}
+	p.CRF, _ = strconv.Atoi(get(tagCRF))
+	p.Preset, _ = strconv.Atoi(get(tagPreset))
+	p.Date, _ = time.Parse(time.RFC3339, get(tagDate))
+	return p
+}
+
+// ReadProvenance probes a file and returns its provenance, or nil if it has none
+func ReadProvenance(ctx context.Context, path string) (*Provenance, error) {
+	info, err := ProbeFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	return provenanceFromTags(info.Tags), nil
+}
+
+// SourceProvenance returns the probed input's provenance, or nil when this tool didn't produce it
+func (e *Encoder) SourceProvenance() *Provenance {
+	if e.Source == nil {
+		return nil
+	}
+	return provenanceFromTags(e.Source.Tags)
+}
+
+// recordProvenance fills in e.Provenance for the encode about to run
+func (e *Encoder) recordProvenance() error {
+	size, hash, err := quickHash(e.InputPath)
+	if err != nil {
+		return fmt.Errorf("cannot hash source: %w", err)
+	}
+
+	e.Provenance = &Provenance{
+		Tool:       "svt-av1-encoder " + Version,
+		Profile:    string(e.Config.ProfileName),
+		CRF:        e.Config.CRF,
+		Preset:     e.Config.Preset,
+		SVTParams:  e.svtParams(),
+		SVTVersion: e.detectSVTVersion(),
+		Source:     filepath.Base(e.InputPath),
+		SourceSize: size,
+		SourceHash: hash,
+		Date:       time.Now(),
+	}
+	return nil
+}
+
+// provenanceArgs writes the provenance as global tags; MP4 only keeps custom keys with use_metadata_tags
+func (e *Encoder) provenanceArgs() []string {
+	if e.Provenance == nil {
+		return nil
+	}
+	var args []string
+	for _, tag := range e.Provenance.tags() {
+		args = append(args, "-metadata", tag[0]+"="+tag[1])
+	}
+	if e.Config.Container == config.ContainerMP4 {
+		args = append(args, "-movflags", "+use_metadata_tags")
+	}
+	return args
+}
+
+// detectSVTVersion encodes one tiny frame to read the SVT-AV1 library banner
+// ffmpeg doesn't otherwise report which SVT-AV1 build libsvtav1 is linked against
+func (e *Encoder) detectSVTVersion() string {
+	stderr, _ := e.runFFmpeg("", nil,
+		"-v", "info",
+		"-f", "lavfi", "-i", "color=c=black:s=64x64:d=0.04",
+		"-frames:v", "1",
+		"-c:v", "libsvtav1",
+		"-f", "null", "-",
+	)
+	return parseSVTVersion(stderr)
+}
+
+// parseSVTVersion extracts the library version from SVT-AV1's startup banner
+func parseSVTVersion(stderr string) string {
+	if m := svtVersionRe.FindStringSubmatch(stderr); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return "unknown"
+}
+
+// quickHash fingerprints a file from its size and the first and last quickHashBytes
+func quickHash(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, "", err
+	}
+	size := info.Size()
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%d\n", size)
+	if _, err := io.CopyN(h, f, min(size, quickHashBytes)); err != nil {
+		return 0, "", err
+	}
+	if size > quickHashBytes {
+		tail := min(size-quickHashBytes, quickHashBytes)
+		if _, err := f.Seek(-tail, io.SeekEnd); err != nil {
+			return 0, "", err
+		}
+		if _, err := io.CopyN(h, f, tail); err != nil {
+			return 0, "", err
+		}
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
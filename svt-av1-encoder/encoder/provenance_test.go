@@ -0,0 +1,98 @@
+package encoder
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func TestProvenanceRoundTrip(t *testing.T) {
+	cfg := config.GetProfile(config.ProfileFilm)
+	cfg.Container = config.ContainerMP4
+	e := &Encoder{Config: cfg}
+	e.Provenance = &Provenance{
+		Tool:       "svt-av1-encoder 1.2.0",
+		Profile:    "film",
+		CRF:        32,
+		Preset:     2,
+		SVTParams:  e.svtParams(),
+		SVTVersion: "SVT-AV1-HDR Encoder Lib v2.3.0",
+		Source:     "movie.mkv",
+		SourceSize: 712500000,
+		SourceHash: "abc123",
+		Date:       time.Date(2026, 3, 14, 15, 9, 26, 0, time.UTC),
+	}
+
+	// Write the tags the way ffmpeg receives them, then read them back the way ffprobe reports them
+	args := e.provenanceArgs()
+	tags := map[string]string{}
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-metadata" {
+			key, value, _ := strings.Cut(args[i+1], "=")
+			tags[key] = value
+		}
+	}
+	if args[len(args)-1] != "+use_metadata_tags" {
+		t.Errorf("mp4 output needs use_metadata_tags, got %v", args)
+	}
+
+	got := provenanceFromTags(lowerKeys(tags))
+	if got == nil {
+		t.Fatal("provenanceFromTags returned nil for tagged file")
+	}
+	if *got != *e.Provenance {
+		t.Errorf("round trip:\n got  %+v\n want %+v", *got, *e.Provenance)
+	}
+
+	if provenanceFromTags(map[string]string{"encoder": "libebml"}) != nil {
+		t.Error("untagged file should have no provenance")
+	}
+}
+
+func TestParseSVTVersion(t *testing.T) {
+	stderr := "Svt[info]: -------------------------------------------\n" +
+		"Svt[info]: SVT [version]:\tSVT-AV1-HDR Encoder Lib v2.3.0-hdr\n" +
+		"Svt[info]: SVT [build]  :\tGCC 13.2.0\t 64 bit\n"
+	if got := parseSVTVersion(stderr); got != "SVT-AV1-HDR Encoder Lib v2.3.0-hdr" {
+		t.Errorf("parseSVTVersion = %q", got)
+	}
+	if got := parseSVTVersion("Unknown encoder 'libsvtav1'"); got != "unknown" {
+		t.Errorf("parseSVTVersion without banner = %q, want unknown", got)
+	}
+}
+
+func TestQuickHash(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	data := make([]byte, 3*quickHashBytes)
+	a := write("a.bin", data)
+	size, hashA, err := quickHash(a)
+	if err != nil || size != int64(len(data)) {
+		t.Fatalf("quickHash = %d, %v", size, err)
+	}
+
+	// Only the ends are hashed: a change in the middle keeps the fingerprint, one at the end doesn't
+	data[len(data)/2] = 1
+	if _, h, _ := quickHash(write("b.bin", data)); h != hashA {
+		t.Error("middle byte changed the quick hash")
+	}
+	data[len(data)-1] = 1
+	if _, h, _ := quickHash(write("c.bin", data)); h == hashA {
+		t.Error("last byte did not change the quick hash")
+	}
+
+	if _, _, err := quickHash(write("small.bin", []byte("tiny"))); err != nil {
+		t.Errorf("quickHash on a small file: %v", err)
+	}
+}
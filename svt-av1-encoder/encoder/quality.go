@@ -0,0 +1,301 @@
+package encoder
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QualityScore summarizes one objective metric across the sampled frames
+type QualityScore struct {
+	Metric      string  `json:"metric"`
+	Mean        float64 `json:"mean"`
+	Percentile1 float64 `json:"p1"`    // 1% of sampled frames score at or below this
+	Worst       float64 `json:"worst"` // Lowest single-frame score
+	WorstFrame  int64   `json:"worst_frame"`
+	Frames      int     `json:"frames"` // Number of frames compared
+}
+
+// QualityReport holds the result of a post-encode quality pass
+type QualityReport struct {
+	Source     string         `json:"source"`
+	Output     string         `json:"output"`
+	Stride     int            `json:"stride"`
+	Scores     []QualityScore `json:"scores"`
+	Elapsed    time.Duration  `json:"-"`
+	ReportPath string         `json:"-"`
+}
+
+// frameScore is a single per-frame metric value
+type frameScore struct {
+	frame int64
+	value float64
+}
+
+// psnrCap replaces infinite PSNR (identical frames) so means stay finite
+const psnrCap = 100.0
+
+// qualityLogFile returns the per-metric log file name written inside the work directory
+func qualityLogFile(metric string) string {
+	if metric == "vmaf" {
+		return "vmaf.json"
+	}
+	return metric + ".log"
+}
+
+// buildQualityFilter constructs the -lavfi graph comparing distorted (input 0) against reference (input 1)
+// Both sides are subsampled identically so frame N of one always lines up with frame N of the other
+// refFilters reproduce the encode's geometry (crop etc.) on the reference so frames are comparable
+func buildQualityFilter(metrics []string, stride int, refFilters FilterChain) string {
+	if stride < 1 {
+		stride = 1
+	}
+
+	prep := "setpts=PTS-STARTPTS,format=yuv420p10le"
+	if stride > 1 {
+		prep = fmt.Sprintf("select='not(mod(n,%d))',", stride) + prep
+	}
+
+	refPrep := prep
+	if len(refFilters) > 0 {
+		refPrep = refFilters.String() + "," + prep
+	}
+
+	n := len(metrics)
+	var chains []string
+	if n == 1 {
+		chains = append(chains,
+			fmt.Sprintf("[0:v]%s[d0]", prep),
+			fmt.Sprintf("[1:v]%s[r0]", refPrep),
+		)
+	} else {
+		var dOut, rOut string
+		for i := 0; i < n; i++ {
+			dOut += fmt.Sprintf("[d%d]", i)
+			rOut += fmt.Sprintf("[r%d]", i)
+		}
+		chains = append(chains,
+			fmt.Sprintf("[0:v]%s,split=%d%s", prep, n, dOut),
+			fmt.Sprintf("[1:v]%s,split=%d%s", refPrep, n, rOut),
+		)
+	}
+
+	for i, metric := range metrics {
+		logFile := qualityLogFile(metric)
+		var filter string
+		switch metric {
+		case "vmaf":
+			filter = fmt.Sprintf("libvmaf=log_fmt=json:log_path=%s", logFile)
+		default: // ssim, psnr
+			filter = fmt.Sprintf("%s=stats_file=%s", metric, logFile)
+		}
+		chains = append(chains, fmt.Sprintf("[d%d][r%d]%s", i, i, filter))
+	}
+
+	return strings.Join(chains, ";")
+}
+
+// parseVMAFLog extracts per-frame VMAF scores from a libvmaf JSON log
+func parseVMAFLog(data []byte) ([]frameScore, error) {
+	var log struct {
+		Frames []struct {
+			FrameNum int64              `json:"frameNum"`
+			Metrics  map[string]float64 `json:"metrics"`
+		} `json:"frames"`
+	}
+	if err := json.Unmarshal(data, &log); err != nil {
+		return nil, fmt.Errorf("invalid vmaf log: %w", err)
+	}
+
+	scores := make([]frameScore, 0, len(log.Frames))
+	for _, f := range log.Frames {
+		if v, ok := f.Metrics["vmaf"]; ok {
+			scores = append(scores, frameScore{frame: f.FrameNum, value: v})
+		}
+	}
+	return scores, nil
+}
+
+// parseStatsLog extracts per-frame values for key from an ssim/psnr stats file
+// Lines look like "n:1 Y:0.99 U:0.98 V:0.98 All:0.99 (20.1)" or "n:1 mse_avg:0.5 ... psnr_avg:51.2 ..."
+// Frame numbers in the file are 1-based
+func parseStatsLog(data []byte, key string) []frameScore {
+	var scores []frameScore
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	for scanner.Scan() {
+		var frame int64 = -1
+		value := math.NaN()
+		for _, field := range strings.Fields(scanner.Text()) {
+			k, v, ok := strings.Cut(field, ":")
+			if !ok {
+				continue
+			}
+			switch k {
+			case "n":
+				if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+					frame = n - 1
+				}
+			case key:
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					value = f
+				}
+			}
+		}
+		if frame < 0 || math.IsNaN(value) {
+			continue
+		}
+		if math.IsInf(value, 1) {
+			value = psnrCap
+		}
+		scores = append(scores, frameScore{frame: frame, value: value})
+	}
+	return scores
+}
+
+// summarizeScores reduces per-frame values to mean, 1st percentile and worst frame
+// Frame numbers are scaled by stride so they refer to frames in the full-length output
+func summarizeScores(metric string, scores []frameScore, stride int) QualityScore {
+	result := QualityScore{Metric: metric, Frames: len(scores)}
+	if len(scores) == 0 {
+		return result
+	}
+	if stride < 1 {
+		stride = 1
+	}
+
+	values := make([]float64, len(scores))
+	var sum float64
+	worst := scores[0]
+	for i, s := range scores {
+		values[i] = s.value
+		sum += s.value
+		if s.value < worst.value {
+			worst = s
+		}
+	}
+	sort.Float64s(values)
+
+	result.Mean = sum / float64(len(values))
+	result.Percentile1 = values[int(float64(len(values)-1)*0.01)]
+	result.Worst = worst.value
+	result.WorstFrame = worst.frame * int64(stride)
+	return result
+}
+
+// MeasureQuality compares the finished output against the source with ffmpeg's libvmaf, ssim and psnr filters
+// It writes a JSON sidecar report next to the output and returns the summary
+func (e *Encoder) MeasureQuality() (*QualityReport, error) {
+	metrics := e.Config.QualityMetrics
+	if len(metrics) == 0 {
+		return nil, fmt.Errorf("no quality metrics configured")
+	}
+	stride := e.Config.QualitySampleStride
+	if stride < 1 {
+		stride = 1
+	}
+
+	// Metric filters write their logs relative to the working directory, which avoids
+	// having to escape absolute paths inside the filtergraph
+	workDir, err := os.MkdirTemp("", "svtav1-quality-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create quality work dir: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	output, err := filepath.Abs(e.OutputPath)
+	if err != nil {
+		return nil, err
+	}
+	source, err := filepath.Abs(e.InputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	e.addLog(fmt.Sprintf("Measuring quality (%s, every %d frames)", strings.Join(metrics, ", "), stride))
+	start := time.Now()
+
+	frames, err := e.measureFrames(workDir, []string{"-i", output}, []string{"-i", source}, metrics, stride)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &QualityReport{
+		Source:  e.InputPath,
+		Output:  e.OutputPath,
+		Stride:  stride,
+		Elapsed: time.Since(start),
+	}
+
+	for _, metric := range metrics {
+		score := summarizeScores(metric, frames[metric], stride)
+		report.Scores = append(report.Scores, score)
+		e.addLog(fmt.Sprintf("%s: mean %.2f, 1%% low %.2f, worst %.2f (frame %d)",
+			strings.ToUpper(metric), score.Mean, score.Percentile1, score.Worst, score.WorstFrame))
+	}
+
+	if err := report.write(qualityReportPath(e.OutputPath)); err != nil {
+		e.addLog(fmt.Sprintf("Failed to write quality report: %v", err))
+	} else {
+		e.addLog(fmt.Sprintf("Quality report: %s", report.ReportPath))
+	}
+
+	return report, nil
+}
+
+// measureFrames runs the metric filters on a distorted input against a reference input and
+// returns the per-frame scores by metric; the input args may seek (-ss/-t) to compare clips
+// Logs are written to workDir, replacing those of any earlier pass
+func (e *Encoder) measureFrames(workDir string, distorted, reference []string, metrics []string, stride int) (map[string][]frameScore, error) {
+	args := append(append(append([]string{}, distorted...), reference...),
+		"-lavfi", buildQualityFilter(metrics, stride, e.referenceFilters()),
+		"-f", "null", "-",
+	)
+	if _, err := e.runFFmpeg(workDir, nil, args...); err != nil {
+		return nil, fmt.Errorf("quality pass failed: %w", err)
+	}
+
+	frames := make(map[string][]frameScore)
+	for _, metric := range metrics {
+		data, err := os.ReadFile(filepath.Join(workDir, qualityLogFile(metric)))
+		if err != nil {
+			return nil, fmt.Errorf("missing %s log: %w", metric, err)
+		}
+
+		switch metric {
+		case "vmaf":
+			if frames[metric], err = parseVMAFLog(data); err != nil {
+				return nil, err
+			}
+		case "ssim":
+			frames[metric] = parseStatsLog(data, "All")
+		case "psnr":
+			frames[metric] = parseStatsLog(data, "psnr_avg")
+		}
+	}
+	return frames, nil
+}
+
+// qualityReportPath returns the sidecar report path for an output file (movie.av1.mkv -> movie.av1.quality.json)
+func qualityReportPath(outputPath string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".quality.json"
+}
+
+// write saves the report as indented JSON and records where it went
+func (r *QualityReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	r.ReportPath = path
+	return nil
+}
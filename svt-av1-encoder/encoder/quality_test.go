@@ -0,0 +1,112 @@
+package encoder
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParseStatsLog_SSIM(t *testing.T) {
+	data := []byte("n:1 Y:0.995000 U:0.990000 V:0.991000 All:0.993000 (21.549)\n" +
+		"n:2 Y:0.900000 U:0.910000 V:0.920000 All:0.905000 (10.227)\n" +
+		"garbage line\n")
+
+	scores := parseStatsLog(data, "All")
+	if len(scores) != 2 {
+		t.Fatalf("parseStatsLog returned %d scores, want 2", len(scores))
+	}
+	if scores[0].frame != 0 || math.Abs(scores[0].value-0.993) > 1e-9 {
+		t.Errorf("first score = %+v, want frame 0 value 0.993", scores[0])
+	}
+	if scores[1].frame != 1 || math.Abs(scores[1].value-0.905) > 1e-9 {
+		t.Errorf("second score = %+v, want frame 1 value 0.905", scores[1])
+	}
+}
+
+func TestParseStatsLog_PSNRInfinite(t *testing.T) {
+	data := []byte("n:1 mse_avg:0.00 mse_y:0.00 mse_u:0.00 mse_v:0.00 psnr_avg:inf psnr_y:inf psnr_u:inf psnr_v:inf\n" +
+		"n:2 mse_avg:1.52 mse_y:1.80 mse_u:0.98 mse_v:0.95 psnr_avg:46.31 psnr_y:45.58 psnr_u:48.21 psnr_v:48.35\n")
+
+	scores := parseStatsLog(data, "psnr_avg")
+	if len(scores) != 2 {
+		t.Fatalf("parseStatsLog returned %d scores, want 2", len(scores))
+	}
+	if scores[0].value != psnrCap {
+		t.Errorf("infinite PSNR = %f, want cap %f", scores[0].value, psnrCap)
+	}
+	if math.Abs(scores[1].value-46.31) > 1e-9 {
+		t.Errorf("psnr_avg = %f, want 46.31", scores[1].value)
+	}
+}
+
+func TestParseVMAFLog(t *testing.T) {
+	data := []byte(`{"version":"2.3.1","frames":[
+		{"frameNum":0,"metrics":{"integer_adm2":0.98,"vmaf":95.5}},
+		{"frameNum":1,"metrics":{"integer_adm2":0.97,"vmaf":88.25}}
+	],"pooled_metrics":{}}`)
+
+	scores, err := parseVMAFLog(data)
+	if err != nil {
+		t.Fatalf("parseVMAFLog error: %v", err)
+	}
+	if len(scores) != 2 || scores[1].value != 88.25 || scores[1].frame != 1 {
+		t.Errorf("parseVMAFLog = %+v, want two frames with frame 1 = 88.25", scores)
+	}
+
+	if _, err := parseVMAFLog([]byte("not json")); err == nil {
+		t.Error("parseVMAFLog should fail on invalid JSON")
+	}
+}
+
+func TestSummarizeScores(t *testing.T) {
+	var scores []frameScore
+	for i := 0; i < 200; i++ {
+		scores = append(scores, frameScore{frame: int64(i), value: 90})
+	}
+	scores[50].value = 40
+	scores[120].value = 60
+
+	got := summarizeScores("vmaf", scores, 10)
+	if got.Frames != 200 {
+		t.Errorf("Frames = %d, want 200", got.Frames)
+	}
+	if got.Worst != 40 || got.WorstFrame != 500 {
+		t.Errorf("Worst = %f at %d, want 40 at frame 500 (stride-scaled)", got.Worst, got.WorstFrame)
+	}
+	if got.Percentile1 != 60 {
+		t.Errorf("Percentile1 = %f, want 60", got.Percentile1)
+	}
+	wantMean := (198*90.0 + 40 + 60) / 200
+	if math.Abs(got.Mean-wantMean) > 1e-9 {
+		t.Errorf("Mean = %f, want %f", got.Mean, wantMean)
+	}
+
+	empty := summarizeScores("ssim", nil, 1)
+	if empty.Frames != 0 || empty.Mean != 0 {
+		t.Errorf("empty summary = %+v, want zero values", empty)
+	}
+}
+
+func TestBuildQualityFilter(t *testing.T) {
+	single := buildQualityFilter([]string{"ssim"}, 1, nil)
+	if strings.Contains(single, "select") || strings.Contains(single, "split") {
+		t.Errorf("single metric, stride 1 should not select or split: %s", single)
+	}
+	if !strings.Contains(single, "[d0][r0]ssim=stats_file=ssim.log") {
+		t.Errorf("missing ssim comparison: %s", single)
+	}
+
+	multi := buildQualityFilter([]string{"vmaf", "psnr"}, 5, FilterChain{"crop=1920:800:0:140"})
+	for _, want := range []string{
+		"select='not(mod(n,5))'",
+		"split=2[d0][d1]",
+		"[1:v]crop=1920:800:0:140,select='not(mod(n,5))'",
+		"split=2[r0][r1]",
+		"[d0][r0]libvmaf=log_fmt=json:log_path=vmaf.json",
+		"[d1][r1]psnr=stats_file=psnr.log",
+	} {
+		if !strings.Contains(multi, want) {
+			t.Errorf("filter %q missing %q", multi, want)
+		}
+	}
+}
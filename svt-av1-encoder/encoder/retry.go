@@ -0,0 +1,209 @@
+package encoder
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxFallbackPreset is the fastest preset a retry switches to; presets above it trade too much quality
+const maxFallbackPreset = 12
+
+// outputStreamRe finds the output stream a muxer error names, e.g. "in stream #2" or "stream #0:3"
+var outputStreamRe = regexp.MustCompile(`(?i)stream #(?:0:)?(\d+)`)
+
+// Attempt is one ffmpeg run of the encode
+type Attempt struct {
+	Change  string // Fallback or restart that preceded this run, "" for the first
+	Started time.Time
+	Elapsed time.Duration // 0 while running
+	Result  string        // "completed", "stalled" or the error; "" while running
+}
+
+// Describe formats the attempt for logs and the TUI, e.g. "Attempt 2 (preset 4 → 5): completed"
+func (a Attempt) Describe(number int) string {
+	s := fmt.Sprintf("Attempt %d", number)
+	if a.Change != "" {
+		s += " (" + a.Change + ")"
+	}
+	if a.Result == "" {
+		return s + ": running"
+	}
+	return s + ": " + a.Result
+}
+
+// fallbacks are the settings retries have changed on top of Config
+type fallbacks struct {
+	retries       int
+	excluded      map[int]string // Source stream index → drop reason
+	stripSideData bool
+	pixelFormat   bool
+	ignoreErrors  bool
+	applied       []string // Descriptions in order, for provenance
+}
+
+// AttemptHistory returns a copy of the runs so far, oldest first
+func (e *Encoder) AttemptHistory() []Attempt {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	attempts := make([]Attempt, len(e.attempts))
+	copy(attempts, e.attempts)
+	return attempts
+}
+
+// finishAttemptLocked records how the current run ended (must hold mutex)
+func (e *Encoder) finishAttemptLocked(err error, restart bool) {
+	if len(e.attempts) == 0 {
+		return
+	}
+	last := &e.attempts[len(e.attempts)-1]
+	last.Elapsed = time.Since(last.Started).Round(time.Second)
+	switch {
+	case restart:
+		last.Result = "stalled"
+	case err != nil:
+		last.Result = err.Error()
+	default:
+		last.Result = "completed"
+	}
+}
+
+// crashed reports whether ffmpeg died from a signal (e.g. SIGSEGV inside the encoder library)
+func crashed(err error) bool {
+	var exitErr *exec.ExitError
+	return errors.As(err, &exitErr) && !exitErr.Exited()
+}
+
+// offendingStream finds the subtitle, data or attachment stream a muxer error names
+func (e *Encoder) offendingStream(lines []string) (StreamInfo, bool) {
+	outputs := e.planStreams().Outputs
+	for _, line := range lines {
+		m := outputStreamRe.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		i, _ := strconv.Atoi(m[1])
+		if i >= len(outputs) {
+			continue
+		}
+		switch s := outputs[i].Source; s.CodecType {
+		case "subtitle", "data", "attachment":
+			return s, true
+		}
+	}
+	return StreamInfo{}, false
+}
+
+// excludeStream leaves a source stream out of the next run
+func (e *Encoder) excludeStream(s StreamInfo, reason string) string {
+	if e.fallback.excluded == nil {
+		e.fallback.excluded = make(map[int]string)
+	}
+	e.fallback.excluded[s.Index] = reason
+	return fmt.Sprintf("dropped stream %d (%s %s)", s.Index, s.CodecType, s.CodecName)
+}
+
+// dropExtraStreams keeps only video and audio, when the muxer error didn't say which stream failed
+func (e *Encoder) dropExtraStreams() string {
+	var dropped []string
+	for _, o := range e.planStreams().Outputs {
+		switch o.Source.CodecType {
+		case "subtitle", "data", "attachment":
+			dropped = append(dropped, strings.TrimPrefix(e.excludeStream(o.Source, "dropped after muxer error"), "dropped "))
+		}
+	}
+	if len(dropped) == 0 {
+		return ""
+	}
+	return "dropped " + strings.Join(dropped, ", ")
+}
+
+// switchPreset moves to the next faster preset, which often avoids preset-specific encoder crashes
+// and lowers memory use
+func (e *Encoder) switchPreset() string {
+	if e.Config.Preset >= maxFallbackPreset {
+		return ""
+	}
+	from := e.Config.Preset
+	e.Config.Preset++
+	return fmt.Sprintf("preset %d → %d", from, e.Config.Preset)
+}
+
+// chooseFallback changes the settings to work around a failure and describes the change,
+// or returns "" when nothing would help (a full disk or a missing encoder won't fix themselves,
+// and a rejected parameter is the user's setting to correct, not something to silently change)
+func (e *Encoder) chooseFallback(err error) string {
+	var failure *EncodeError
+	if !errors.As(err, &failure) {
+		if crashed(err) {
+			return e.switchPreset()
+		}
+		return ""
+	}
+
+	switch failure.Category {
+	case CategoryMuxer:
+		if s, ok := e.offendingStream(failure.Lines); ok {
+			return e.excludeStream(s, "dropped after muxer error")
+		}
+		if !e.fallback.stripSideData {
+			e.fallback.stripSideData = true
+			return "stripped frame side data"
+		}
+		return e.dropExtraStreams()
+	case CategoryPixelFormat:
+		if !e.fallback.pixelFormat {
+			e.fallback.pixelFormat = true
+			return "converted to yuv420p10le before encoding"
+		}
+	case CategoryInputCorrupt:
+		if !e.fallback.ignoreErrors {
+			e.fallback.ignoreErrors = true
+			return "ignoring decode errors in the source"
+		}
+	case CategoryOutOfMemory:
+		return e.switchPreset()
+	}
+	return ""
+}
+
+// applyFallbackLocked picks and records the change for the next attempt; "" means give up.
+// It rewrites Config, Provenance and the stream plan (must hold mutex)
+func (e *Encoder) applyFallbackLocked(err error) string {
+	change := e.chooseFallback(err)
+	if change == "" {
+		return ""
+	}
+	e.fallback.retries++
+	e.fallback.applied = append(e.fallback.applied, change)
+
+	// Keep the output's tags true to how it was actually encoded
+	if e.Provenance != nil {
+		e.Provenance.Preset = e.Config.Preset
+		e.Provenance.SVTParams = e.svtParams()
+		e.Provenance.Fallbacks = strings.Join(e.fallback.applied, "; ")
+	}
+	return change
+}
+
+// addFallbackFilters appends the filters retries have turned on, after everything else
+func (e *Encoder) addFallbackFilters(chain *FilterChain) {
+	if e.fallback.pixelFormat {
+		chain.Add("format=yuv420p10le")
+	}
+	if e.fallback.stripSideData {
+		chain.Add("sidedata=mode=delete")
+	}
+}
+
+// fallbackInputArgs are input options retries have turned on
+func (e *Encoder) fallbackInputArgs() []string {
+	if !e.fallback.ignoreErrors {
+		return nil
+	}
+	return []string{"-err_detect", "ignore_err", "-fflags", "+discardcorrupt"}
+}
@@ -0,0 +1,253 @@
+package encoder
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+// retryEncoder has a video, an audio and two subtitle streams; the mp4 container rejects PGS
+func retryEncoder() *Encoder {
+	cfg := config.DefaultConfig()
+	return &Encoder{Config: cfg, Source: &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "hevc"},
+		audioStream(1, "aac", 2),
+		{Index: 2, CodecType: "subtitle", CodecName: "subrip"},
+		{Index: 3, CodecType: "subtitle", CodecName: "hdmv_pgs_subtitle"},
+	}}}
+}
+
+func TestAttemptDescribe(t *testing.T) {
+	tests := []struct {
+		attempt Attempt
+		want    string
+	}{
+		{Attempt{}, "Attempt 1: running"},
+		{Attempt{Result: "completed"}, "Attempt 1: completed"},
+		{Attempt{Change: "preset 4 → 5", Result: "completed"}, "Attempt 1 (preset 4 → 5): completed"},
+	}
+	for _, tc := range tests {
+		if got := tc.attempt.Describe(1); got != tc.want {
+			t.Errorf("Describe = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestChooseFallback_MuxerDropsNamedStream(t *testing.T) {
+	e := retryEncoder()
+	err := classifyStderr("[mp4 @ 0x1] Could not find tag for codec hdmv_pgs_subtitle in stream #3, codec not currently supported in container", errExit)
+
+	if got := e.chooseFallback(err); got != "dropped stream 3 (subtitle hdmv_pgs_subtitle)" {
+		t.Errorf("chooseFallback = %q", got)
+	}
+	plan := e.planStreams()
+	if len(plan.Outputs) != 3 || plan.Count("subtitle") != 1 {
+		t.Fatalf("only the PGS track should be dropped, outputs: %+v", plan.Outputs)
+	}
+	if plan.Dropped[0].Reason != "dropped after muxer error" {
+		t.Errorf("drop reason = %q", plan.Dropped[0].Reason)
+	}
+}
+
+func TestChooseFallback_MuxerWithoutStream(t *testing.T) {
+	e := retryEncoder()
+	err := classifyStderr("Could not write header for output file #0 (incorrect codec parameters ?)", errExit)
+
+	if got := e.chooseFallback(err); got != "stripped frame side data" {
+		t.Errorf("first fallback = %q, want side data stripped", got)
+	}
+	if !strings.HasSuffix(e.buildFilterChain().String(), "sidedata=mode=delete") {
+		t.Errorf("filters = %s", e.buildFilterChain())
+	}
+	if got := e.chooseFallback(err); got != "dropped stream 2 (subtitle subrip), stream 3 (subtitle hdmv_pgs_subtitle)" {
+		t.Errorf("second fallback = %q, want subtitles dropped", got)
+	}
+	if got := e.chooseFallback(err); got != "" {
+		t.Errorf("nothing left to try, got %q", got)
+	}
+}
+
+func TestChooseFallback_Categories(t *testing.T) {
+	e := retryEncoder()
+	e.Config.Preset = 4
+
+	if got := e.chooseFallback(classifyStderr("Cannot allocate memory", errExit)); got != "preset 4 → 5" || e.Config.Preset != 5 {
+		t.Errorf("out of memory fallback = %q (preset %d)", got, e.Config.Preset)
+	}
+	if got := e.chooseFallback(classifyStderr("Impossible to convert between the formats", errExit)); got == "" {
+		t.Error("pixel format failure should convert explicitly")
+	}
+	if !strings.Contains(e.buildFilterChain().String(), "format=yuv420p10le") {
+		t.Errorf("filters = %s", e.buildFilterChain())
+	}
+	if got := e.chooseFallback(classifyStderr("Invalid data found when processing input", errExit)); got == "" {
+		t.Error("corrupt input should ignore decode errors")
+	}
+	if args := strings.Join(e.buildFFmpegArgs(), " "); !strings.Contains(args, "-err_detect ignore_err -fflags +discardcorrupt -i ") {
+		t.Errorf("input options should come before -i: %s", args)
+	}
+	for _, stderr := range []string{"No space left on device", "Unknown encoder 'libsvtav1'", "Svt[error]: Invalid tune"} {
+		if got := e.chooseFallback(classifyStderr(stderr, errExit)); got != "" {
+			t.Errorf("%q should not be retried, got %q", stderr, got)
+		}
+	}
+	if got := e.chooseFallback(errExit); got != "" {
+		t.Errorf("an unclassified exit should not be retried, got %q", got)
+	}
+
+	e.Config.Preset = maxFallbackPreset
+	if got := e.switchPreset(); got != "" {
+		t.Errorf("no preset past %d, got %q", maxFallbackPreset, got)
+	}
+}
+
+func TestChooseFallback_Crash(t *testing.T) {
+	err := exec.Command("sh", "-c", "kill -SEGV $$").Run()
+	if !crashed(err) {
+		t.Skipf("expected a signal exit, got %v", err)
+	}
+	e := retryEncoder()
+	e.Config.Preset = 2
+	if got := e.chooseFallback(err); got != "preset 2 → 3" {
+		t.Errorf("crash fallback = %q", got)
+	}
+	if crashed(exec.Command("sh", "-c", "exit 1").Run()) {
+		t.Error("a normal non-zero exit is not a crash")
+	}
+}
+
+func TestApplyFallback_UpdatesProvenance(t *testing.T) {
+	e := retryEncoder()
+	e.Config.Preset = 4
+	e.Provenance = &Provenance{Preset: 4}
+
+	e.applyFallbackLocked(classifyStderr("Cannot allocate memory", errExit))
+	if e.fallback.retries != 1 || e.Provenance.Preset != 5 || e.Provenance.Fallbacks != "preset 4 → 5" {
+		t.Errorf("retries %d, provenance %+v", e.fallback.retries, e.Provenance)
+	}
+	tags := e.Provenance.tags()
+	if last := tags[len(tags)-1]; last != [2]string{tagFallbacks, "preset 4 → 5"} {
+		t.Errorf("fallbacks should be tagged, got %v", last)
+	}
+	if got := provenanceFromTags(map[string]string{"svtav1enc_tool": "x", "svtav1enc_fallbacks": "preset 4 → 5"}); got.Fallbacks != "preset 4 → 5" {
+		t.Errorf("fallbacks not read back: %+v", got)
+	}
+}
+
+func TestWait_FallbackWithConcurrentReader(t *testing.T) {
+	// Fails out of memory once, then succeeds at the faster preset
+	marker := filepath.Join(t.TempDir(), "ran")
+	fakeFFmpeg(t, `if [ ! -e '`+marker+`' ]; then
+touch '`+marker+`'
+echo "Cannot allocate memory" >&2
+exit 1
+fi`)
+	e := retryEncoder()
+	e.Config.Preset = 4
+	e.Provenance = &Provenance{Preset: 4}
+	e.OutputPath = filepath.Join(t.TempDir(), "out.mkv")
+
+	// Read the fields a retry rewrites the way the TUI polls state; -race catches unlocked writes
+	stop := make(chan struct{})
+	var reader sync.WaitGroup
+	reader.Add(1)
+	go func() {
+		defer reader.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			e.GetState()
+			e.AttemptHistory()
+			e.mu.Lock()
+			_, _ = e.Config.Preset, e.Provenance.Fallbacks
+			e.mu.Unlock()
+		}
+	}()
+	err := launchAndWait(t, e)
+	close(stop)
+	reader.Wait()
+
+	if err != nil {
+		t.Fatalf("retry should succeed, got %v", err)
+	}
+	attempts := e.AttemptHistory()
+	if len(attempts) != 2 || attempts[1].Change != "preset 4 → 5" || attempts[1].Result != "completed" {
+		t.Errorf("attempts = %+v", attempts)
+	}
+	if e.Provenance.Fallbacks != "preset 4 → 5" {
+		t.Errorf("provenance fallbacks = %q", e.Provenance.Fallbacks)
+	}
+}
+
+// muxerFFmpeg rejects the PGS track while it is mapped, then fails to write the header until
+// side data is stripped
+const muxerFFmpeg = `case "$*" in
+*"-map 0:3"*) echo "[mp4 @ 0x1] Could not find tag for codec hdmv_pgs_subtitle in stream #3, codec not currently supported in container" >&2; exit 1 ;;
+*sidedata=mode=delete*) exit 0 ;;
+*) echo "Could not write header for output file #0 (incorrect codec parameters ?)" >&2; exit 1 ;;
+esac`
+
+func TestWait_RetriesUntilFallbacksSucceed(t *testing.T) {
+	fakeFFmpeg(t, muxerFFmpeg)
+	e := retryEncoder()
+	e.Provenance = &Provenance{}
+	e.OutputPath = filepath.Join(t.TempDir(), "out.mp4")
+
+	if err := launchAndWait(t, e); err != nil {
+		t.Fatalf("encode should succeed after two fallbacks, got %v", err)
+	}
+	want := []string{"", "dropped stream 3 (subtitle hdmv_pgs_subtitle)", "stripped frame side data"}
+	attempts := e.AttemptHistory()
+	if len(attempts) != len(want) {
+		t.Fatalf("attempts = %+v", attempts)
+	}
+	for i, a := range attempts {
+		if a.Change != want[i] {
+			t.Errorf("attempt %d change = %q, want %q", i+1, a.Change, want[i])
+		}
+	}
+	if !strings.HasPrefix(attempts[0].Result, "Container or subtitle incompatibility") || attempts[2].Result != "completed" {
+		t.Errorf("results = %q, %q", attempts[0].Result, attempts[2].Result)
+	}
+	if e.Provenance.Fallbacks != strings.Join(want[1:], "; ") {
+		t.Errorf("provenance fallbacks = %q", e.Provenance.Fallbacks)
+	}
+}
+
+func TestWait_GivesUpAfterMaxRetries(t *testing.T) {
+	fakeFFmpeg(t, muxerFFmpeg)
+	e := retryEncoder()
+	e.Config.MaxRetries = 1
+	e.OutputPath = filepath.Join(t.TempDir(), "out.mp4")
+
+	var failure *EncodeError
+	if err := launchAndWait(t, e); !errors.As(err, &failure) || failure.Category != CategoryMuxer {
+		t.Fatalf("error = %v, want the classified muxer failure", err)
+	}
+	if attempts := e.AttemptHistory(); len(attempts) != 2 {
+		t.Errorf("attempts = %+v, want the first run and one retry", attempts)
+	}
+}
+
+func TestFinishAttemptLocked(t *testing.T) {
+	e := retryEncoder()
+	e.attempts = []Attempt{{Started: time.Now().Add(-time.Minute)}}
+	e.finishAttemptLocked(nil, false)
+	if a := e.attempts[0]; a.Result != "completed" || a.Elapsed != time.Minute {
+		t.Errorf("attempt = %+v", a)
+	}
+	e.attempts = append(e.attempts, Attempt{Started: time.Now()})
+	e.finishAttemptLocked(errExit, true)
+	if got := e.AttemptHistory()[1].Result; got != "stalled" {
+		t.Errorf("restart result = %q", got)
+	}
+}
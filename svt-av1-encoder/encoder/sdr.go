@@ -0,0 +1,111 @@
+package encoder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// toneMapFilter converts HDR (PQ or HLG, BT.2020) to 10-bit BT.709 SDR
+// ffmpeg's tonemap filter has no BT.2390 curve, so that one is rendered by libplacebo instead
+func toneMapFilter(mapper config.ToneMapper) string {
+	if mapper == config.ToneMapBT2390 {
+		return "libplacebo=tonemapping=bt.2390:colorspace=bt709:color_primaries=bt709:color_trc=bt709:range=tv:format=yuv420p10le"
+	}
+	if mapper == "" {
+		mapper = config.ToneMapHable
+	}
+	// Linearize, move to BT.709 primaries in float, tonemap, then apply the BT.709 transfer
+	return "zscale=t=linear:npl=100,format=gbrpf32le,zscale=p=bt709," +
+		fmt.Sprintf("tonemap=tonemap=%s:desat=0,", mapper) +
+		"zscale=t=bt709:m=bt709:r=tv,format=yuv420p10le"
+}
+
+// IsHDR reports whether the probed source video is HDR10, HLG or Dolby Vision
+func (e *Encoder) IsHDR() bool {
+	if e.Source == nil {
+		return false
+	}
+	video, ok := e.Source.VideoStream()
+	return ok && hdrClass(video) != "SDR"
+}
+
+// IsSDRCompanion reports whether this encoder produces the tonemapped companion output
+func (e *Encoder) IsSDRCompanion() bool {
+	return e.toneMap != ""
+}
+
+// NeedsSDRCompanion reports whether a companion encode should be queued after this one
+func (e *Encoder) NeedsSDRCompanion() bool {
+	return e.Config.SDRCompanion && !e.IsSDRCompanion() && e.IsHDR()
+}
+
+// suffixedOutputPath inserts suffix before .av1.<container>, e.g. movie.sdr.av1.mkv
+func suffixedOutputPath(output, suffix string) string {
+	ext := filepath.Ext(output)
+	base := strings.TrimSuffix(strings.TrimSuffix(output, ext), ".av1")
+	return base + suffix + ".av1" + ext
+}
+
+// SDRCompanion creates the linked encoder for the tonemapped SDR output
+// It reuses the main encode's probe and scan/crop decisions, so its Prepare only re-plans
+func (e *Encoder) SDRCompanion() *Encoder {
+	cfg := e.Config
+	if cfg.SDRProfile != "" {
+		cfg = cfg.WithProfile(cfg.SDRProfile)
+	}
+	cfg.SDRCompanion = false
+	cfg.ExtractSubtitles = false // The main encode already wrote the sidecars
+	cfg.CropMode = config.CropOff
+	cfg.DeinterlaceMode = config.DeinterlaceOff
+	cfg.GrainMode = config.GrainOff
+
+	c := New(e.InputPath, cfg)
+	c.OutputPath = suffixedOutputPath(e.OutputPath, cfg.SDRSuffix)
+	c.Source = e.Source
+	c.Crop = e.Crop
+	c.Scan = e.Scan
+	c.toneMap = cfg.ToneMapper
+	if c.toneMap == "" {
+		c.toneMap = config.ToneMapHable
+	}
+	return c
+}
+
+// describeSDRCompanion explains whether the companion encode will run, for the analysis notes
+func (e *Encoder) describeSDRCompanion() string {
+	if !e.Config.SDRCompanion || e.IsSDRCompanion() {
+		return ""
+	}
+	if !e.IsHDR() {
+		return "not needed, source is SDR"
+	}
+	profile := e.Config.SDRProfile
+	if profile == "" {
+		profile = e.Config.ProfileName
+	}
+	return fmt.Sprintf("queued → %s (profile %s, tonemap %s)",
+		filepath.Base(suffixedOutputPath(e.OutputPath, e.Config.SDRSuffix)), profile, e.Config.ToneMapper)
+}
+
+// addToneMapFilter appends the HDR → SDR conversion on the companion encode
+func (e *Encoder) addToneMapFilter(chain *FilterChain) {
+	if e.toneMap != "" {
+		chain.Add(toneMapFilter(e.toneMap))
+	}
+}
+
+// colorArgs tags the companion output as BT.709 so players don't treat it as HDR
+func (e *Encoder) colorArgs() []string {
+	if e.toneMap == "" {
+		return nil
+	}
+	return []string{
+		"-color_primaries", "bt709",
+		"-color_trc", "bt709",
+		"-colorspace", "bt709",
+		"-color_range", "tv",
+	}
+}
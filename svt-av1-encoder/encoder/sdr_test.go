@@ -0,0 +1,75 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestSuffixedOutputPath(t *testing.T) {
+	tests := []struct{ output, want string }{
+		{"/media/movie.av1.mkv", "/media/movie.sdr.av1.mkv"},
+		{"clip.av1.mp4", "clip.sdr.av1.mp4"},
+	}
+	for _, tc := range tests {
+		if got := suffixedOutputPath(tc.output, ".sdr"); got != tc.want {
+			t.Errorf("suffixedOutputPath(%q) = %q, want %q", tc.output, got, tc.want)
+		}
+	}
+}
+
+func TestToneMapFilter(t *testing.T) {
+	if got := toneMapFilter(config.ToneMapMobius); !strings.Contains(got, "tonemap=tonemap=mobius") ||
+		!strings.HasPrefix(got, "zscale=t=linear") || !strings.HasSuffix(got, "format=yuv420p10le") {
+		t.Errorf("mobius chain = %q", got)
+	}
+	if got := toneMapFilter(config.ToneMapBT2390); !strings.HasPrefix(got, "libplacebo=tonemapping=bt.2390") {
+		t.Errorf("bt2390 chain = %q", got)
+	}
+}
+
+func TestSDRCompanion(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SDRCompanion = true
+	cfg.SDRProfile = config.ProfileCompress
+	cfg.ToneMapper = config.ToneMapHable
+	cfg.SDRSuffix = ".sdr"
+	cfg.ExtractSubtitles = true
+	cfg.CropMode = config.CropAuto
+
+	main := New("/media/movie.mkv", cfg)
+	main.Source = &MediaInfo{Streams: []StreamInfo{{CodecType: "video", Width: 3840, Height: 1600, ColorTransfer: "smpte2084"}}}
+	main.Crop = &CropRect{W: 3840, H: 1600}
+	if !main.NeedsSDRCompanion() {
+		t.Fatal("HDR source with SDRCompanion should need a companion")
+	}
+
+	c := main.SDRCompanion()
+	if c.OutputPath != "/media/movie.sdr.av1.mkv" {
+		t.Errorf("companion output = %s", c.OutputPath)
+	}
+	if c.NeedsSDRCompanion() || !c.IsSDRCompanion() {
+		t.Error("the companion must not queue another companion")
+	}
+	if c.Config.ProfileName != config.ProfileCompress || c.Config.ExtractSubtitles || c.Config.CropMode != config.CropOff || c.Crop != main.Crop {
+		t.Errorf("companion config = %+v, crop %v", c.Config, c.Crop)
+	}
+
+	// Tonemapping runs on the downscaled frame, and the reference is tonemapped too
+	chain := c.buildFilterChain().String()
+	if !strings.HasPrefix(chain, "crop=3840:1600:0:0,scale=1920:800:flags=lanczos,zscale=t=linear") {
+		t.Errorf("companion filter chain = %q", chain)
+	}
+	if ref := c.referenceFilters().String(); !strings.Contains(ref, "tonemap=tonemap=hable") {
+		t.Errorf("companion reference filters = %q", ref)
+	}
+	if args := strings.Join(c.buildFFmpegArgs(), " "); !strings.Contains(args, "-color_trc bt709") {
+		t.Errorf("companion args missing BT.709 tags: %s", args)
+	}
+
+	main.Source.Streams[0].ColorTransfer = "bt709"
+	if main.NeedsSDRCompanion() {
+		t.Error("SDR sources need no companion")
+	}
+}
@@ -0,0 +1,53 @@
+package encoder
+
+import (
+	"fmt"
+
+	"svt-av1-encoder/config"
+)
+
+// formatBitrate formats bits/s as "8.00 Mbps" or "850 kbps"
+func formatBitrate(bps int64) string {
+	if bps >= 1000000 {
+		return fmt.Sprintf("%.2f Mbps", float64(bps)/1e6)
+	}
+	return fmt.Sprintf("%d kbps", bps/1000)
+}
+
+// describeSourceBitrate summarizes the video bitrate for the analysis notes, "" when unknown
+func (e *Encoder) describeSourceBitrate() string {
+	if e.Source == nil {
+		return ""
+	}
+	bitrate, source := e.Source.VideoBitrate()
+	if bitrate == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s video (%s), %.3f bits/pixel", formatBitrate(bitrate), source, e.Source.BitsPerPixel())
+}
+
+// BitrateSkipReason returns why the source is too starved to be worth re-encoding, or ""
+// to encode it; sources whose bitrate can't be determined are always encoded
+func (e *Encoder) BitrateSkipReason() string {
+	if e.Source == nil {
+		return ""
+	}
+	video, ok := e.Source.VideoStream()
+	if !ok {
+		return ""
+	}
+
+	class := config.ResolutionClassOf(video.Width, video.Height)
+	threshold := e.Config.MinBitsPerPixel[class]
+	if threshold <= 0 {
+		return ""
+	}
+	bpp := e.Source.BitsPerPixel()
+	if bpp == 0 || bpp >= threshold {
+		return ""
+	}
+
+	bitrate, source := e.Source.VideoBitrate()
+	return fmt.Sprintf("Source video is %.3f bits/pixel (%s from %s at %dx%d), below the %s minimum of %.3f",
+		bpp, formatBitrate(bitrate), source, video.Width, video.Height, class, threshold)
+}
@@ -0,0 +1,47 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestBitrateSkipReason(t *testing.T) {
+	info, err := parseProbeOutput([]byte(sampleProbeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 8 Mbps at 1920x1080 23.976fps is about 0.161 bits/pixel
+	tests := []struct {
+		thresholds map[config.ResolutionClass]float64
+		skip       bool
+	}{
+		{map[config.ResolutionClass]float64{}, false},
+		{config.DefaultMinBitsPerPixel(), false},
+		{map[config.ResolutionClass]float64{config.ResolutionFHD: 0.2}, true},
+		{map[config.ResolutionClass]float64{config.ResolutionSD: 0.2}, false},
+	}
+	for _, tc := range tests {
+		cfg := config.DefaultConfig()
+		cfg.MinBitsPerPixel = tc.thresholds
+		e := &Encoder{Config: cfg, Source: info}
+		reason := e.BitrateSkipReason()
+		if (reason != "") != tc.skip {
+			t.Errorf("thresholds %v: reason %q, want skip=%v", tc.thresholds, reason, tc.skip)
+		}
+		if tc.skip && !strings.Contains(reason, "0.161 bits/pixel") {
+			t.Errorf("reason %q should show the computed bits/pixel", reason)
+		}
+	}
+}
+
+func TestFormatBitrate(t *testing.T) {
+	if got := formatBitrate(8000000); got != "8.00 Mbps" {
+		t.Errorf("formatBitrate(8000000) = %s", got)
+	}
+	if got := formatBitrate(850000); got != "850 kbps" {
+		t.Errorf("formatBitrate(850000) = %s", got)
+	}
+}
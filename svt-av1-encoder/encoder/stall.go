@@ -0,0 +1,78 @@
+package encoder
+
+import (
+	"fmt"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+// maxStallCheckInterval bounds how late a stall is noticed after the timeout passes
+const maxStallCheckInterval = 5 * time.Second
+
+// stallCheckInterval polls often enough for short timeouts without busy-looping
+func stallCheckInterval(timeout time.Duration) time.Duration {
+	return max(time.Second, min(timeout/4, maxStallCheckInterval))
+}
+
+// stalledFor returns how long the encode has gone without advancing, and whether that
+// newly crosses the timeout (a stall already reported isn't reported again until progress resumes)
+func (e *Encoder) stalledFor(timeout time.Duration) (time.Duration, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	idle := time.Since(e.Progress.LastAdvance)
+	if idle < timeout || e.Progress.Stalled {
+		return idle, false
+	}
+	e.Progress.Stalled = true
+	return idle, true
+}
+
+// restartEncode kills the hung ffmpeg run and has wait launch a fresh one
+func (e *Encoder) restartEncode(reason string) {
+	e.mu.Lock()
+	e.restart = true
+	e.Progress.Restarts++
+	e.mu.Unlock()
+	e.addLog("Watchdog: " + reason + ", restarting")
+	e.kill()
+}
+
+// watchStall notices an ffmpeg that has stopped advancing, e.g. blocked reading a stalled network
+// mount, where progress batches simply stop arriving and the last percentage would stay on screen
+func (e *Encoder) watchStall(finished <-chan struct{}) {
+	timeout := e.Config.StallTimeout
+	ticker := time.NewTicker(stallCheckInterval(timeout))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-finished:
+			return
+		case <-ticker.C:
+		}
+		idle, stalled := e.stalledFor(timeout)
+		if !stalled {
+			continue
+		}
+		reason := fmt.Sprintf("no progress for %s", idle.Round(time.Second))
+
+		switch e.Config.StallAction {
+		case config.StallCancel:
+			e.abortEncode(fmt.Errorf("stopped: %s; ffmpeg appears hung (stalled input or network mount?)", reason))
+			return
+		case config.StallRestart:
+			e.mu.Lock()
+			restarts := e.Progress.Restarts
+			e.mu.Unlock()
+			if restarts < e.Config.StallRestarts {
+				e.restartEncode(reason)
+			} else {
+				e.abortEncode(fmt.Errorf("stopped: %s after %d restarts; ffmpeg keeps hanging", reason, restarts))
+			}
+			return
+		default:
+			e.addLog("Watchdog: " + reason + ", marked as stalled")
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package encoder
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func TestStallCheckInterval(t *testing.T) {
+	tests := map[time.Duration]time.Duration{
+		2 * time.Second: time.Second,
+		8 * time.Second: 2 * time.Second,
+		5 * time.Minute: 5 * time.Second,
+	}
+	for timeout, want := range tests {
+		if got := stallCheckInterval(timeout); got != want {
+			t.Errorf("stallCheckInterval(%s) = %s, want %s", timeout, got, want)
+		}
+	}
+}
+
+func TestStalledFor_ReportsOncePerStall(t *testing.T) {
+	enc := New("movie.mkv", config.DefaultConfig())
+	enc.Progress.LastAdvance = time.Now().Add(-time.Minute)
+
+	if _, stalled := enc.stalledFor(time.Minute / 2); !stalled {
+		t.Fatal("a minute without progress should cross a 30s timeout")
+	}
+	if _, stalled := enc.stalledFor(time.Minute / 2); stalled {
+		t.Error("the same stall should not be reported twice")
+	}
+
+	// Progress resumes, which clears the stall
+	enc.applyProgressBatch(progressUpdate{frame: 10, frameSet: true})
+	if enc.Progress.Stalled {
+		t.Error("an advancing frame count should clear the stall")
+	}
+	if _, stalled := enc.stalledFor(time.Minute / 2); stalled {
+		t.Error("fresh progress should not count as stalled")
+	}
+}
+
+func TestApplyProgressBatch_RepeatDoesNotAdvance(t *testing.T) {
+	enc := New("movie.mkv", config.DefaultConfig())
+	enc.applyProgressBatch(progressUpdate{frame: 10, frameSet: true, outTimeUs: 400000, outTimeSet: true})
+	before := time.Now().Add(-time.Hour)
+	enc.Progress.LastAdvance = before
+
+	// ffmpeg repeating the same numbers is not progress
+	enc.applyProgressBatch(progressUpdate{frame: 10, frameSet: true, outTimeUs: 400000, outTimeSet: true})
+	if !enc.Progress.LastAdvance.Equal(before) {
+		t.Error("unchanged frame and out_time should not reset the stall clock")
+	}
+}
+
+func TestResetProgressLocked_KeepsTotals(t *testing.T) {
+	enc := New("movie.mkv", config.DefaultConfig())
+	enc.Progress = Progress{Frame: 500, TotalFrames: 1000, TotalDuration: time.Minute, SourceFPS: 24, Restarts: 1, Stalled: true}
+	enc.resetProgressLocked()
+
+	p := enc.Progress
+	if p.Frame != 0 || p.Stalled {
+		t.Errorf("per-run counters should reset, got frame %d stalled %v", p.Frame, p.Stalled)
+	}
+	if p.TotalFrames != 1000 || p.TotalDuration != time.Minute || p.SourceFPS != 24 || p.Restarts != 1 {
+		t.Errorf("totals and restart count should be kept, got %+v", p)
+	}
+}
+
+func TestWatchStall_Cancel(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StallTimeout = time.Second
+	cfg.StallAction = config.StallCancel
+	enc := New("movie.mkv", cfg)
+	enc.Progress.LastAdvance = time.Now().Add(-time.Minute)
+
+	finished := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		enc.watchStall(finished)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		close(finished)
+		t.Fatal("watchdog did not act on the stall")
+	}
+	if enc.abort == nil || !strings.Contains(enc.abort.Error(), "no progress for 1m") {
+		t.Errorf("abort = %v", enc.abort)
+	}
+}
+
+func TestWatchStall_RestartLimit(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.StallTimeout = time.Second
+	cfg.StallAction = config.StallRestart
+	cfg.StallRestarts = 1
+	enc := New("movie.mkv", cfg)
+	enc.Progress.LastAdvance = time.Now().Add(-time.Minute)
+
+	enc.watchStall(make(chan struct{}))
+	if !enc.restart || enc.Progress.Restarts != 1 || enc.abort != nil {
+		t.Fatalf("first stall should restart, got restart=%v restarts=%d abort=%v", enc.restart, enc.Progress.Restarts, enc.abort)
+	}
+
+	enc.restart = false
+	enc.Progress.Stalled = false
+	enc.watchStall(make(chan struct{}))
+	if enc.restart || enc.abort == nil || !strings.Contains(enc.abort.Error(), "after 1 restarts") {
+		t.Errorf("stall past the restart limit should cancel, got restart=%v abort=%v", enc.restart, enc.abort)
+	}
+}
+
+func TestLaunch_AfterStopKillsProcess(t *testing.T) {
+	fakeFFmpeg(t, "exec sleep 30")
+	e := retryEncoder()
+	e.OutputPath = filepath.Join(t.TempDir(), "out.mkv")
+	e.ctx, e.cancel = context.WithCancel(context.Background())
+
+	// Quitting while the source is still being prepared leaves no process for Stop to kill
+	e.Stop()
+	if err := e.launch(""); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitDone(t, e); err == nil {
+		t.Error("a stopped encode should not report success")
+	}
+	if attempts := e.AttemptHistory(); len(attempts) != 1 {
+		t.Errorf("a stopped encode should not be retried, attempts: %+v", attempts)
+	}
+}
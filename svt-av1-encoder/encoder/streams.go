@@ -0,0 +1,280 @@
+package encoder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// losslessAudioCodecs are codecs worth keeping as an untouched original alongside a transcode
+var losslessAudioCodecs = []string{"truehd", "mlp", "flac", "alac", "pcm_*"}
+
+// OutputStream is one stream written to the output, with how it is produced
+type OutputStream struct {
+	Source  StreamInfo
+	Codec   string // "copy" or an encoder name
+	Bitrate int    // kbps, 0 when copying
+	Reason  string // Why this codec was chosen
+	// Disposition is written with -disposition ("default", "forced", "default+forced", "0" to clear)
+	// Empty keeps the source flags
+	Disposition string
+}
+
+// DroppedStream is a source stream left out of the output
+type DroppedStream struct {
+	Source StreamInfo
+	Reason string
+}
+
+// StreamPlan lists every output stream in order, every dropped source stream,
+// the subtitle tracks extracted to sidecar files and the cover art re-attached to the output
+type StreamPlan struct {
+	Outputs  []OutputStream
+	Dropped  []DroppedStream
+	Sidecars []SidecarFile
+	Covers   []CoverArt
+}
+
+// Count returns how many output streams have the given codec type
+func (p StreamPlan) Count(codecType string) int {
+	n := 0
+	for _, o := range p.Outputs {
+		if o.Source.CodecType == codecType {
+			n++
+		}
+	}
+	return n
+}
+
+// Describe returns one line per stream for logs and dry-run output
+func (p StreamPlan) Describe() []string {
+	var lines []string
+	for _, o := range p.Outputs {
+		action := "copy"
+		if o.Codec != "copy" {
+			action = o.Codec
+			if o.Bitrate > 0 {
+				action += fmt.Sprintf(" %dk", o.Bitrate)
+			}
+		}
+		line := fmt.Sprintf("#%d %s → %s", o.Source.Index, describeStream(o.Source), action)
+		if o.Disposition != "" && o.Disposition != "0" {
+			line += " [" + o.Disposition + "]"
+		}
+		if o.Reason != "" {
+			line += " (" + o.Reason + ")"
+		}
+		lines = append(lines, line)
+	}
+	for _, d := range p.Dropped {
+		lines = append(lines, fmt.Sprintf("#%d %s → drop (%s)", d.Source.Index, describeStream(d.Source), d.Reason))
+	}
+	for _, c := range p.Covers {
+		lines = append(lines, fmt.Sprintf("#%d %s → attachment %s (%s)", c.Source.Index, describeStream(c.Source), c.Filename, c.MimeType))
+	}
+	for _, sc := range p.Sidecars {
+		lines = append(lines, fmt.Sprintf("#%d %s → sidecar %s", sc.Source.Index, describeStream(sc.Source), filepath.Base(sc.Path)))
+	}
+	return lines
+}
+
+// describeStream formats a stream like "audio truehd 8ch eng"
+func describeStream(s StreamInfo) string {
+	parts := []string{s.CodecType, s.CodecName}
+	if s.Channels > 0 {
+		parts = append(parts, fmt.Sprintf("%dch", s.Channels))
+	}
+	if s.Language != "" {
+		parts = append(parts, s.Language)
+	}
+	if s.Title != "" {
+		parts = append(parts, fmt.Sprintf("%q", s.Title))
+	}
+	if role := trackRole(s); role != "" && s.CodecType != "video" {
+		parts = append(parts, "["+role+"]")
+	}
+	return strings.Join(parts, " ")
+}
+
+// codecMatches reports whether codec is in the list; entries ending in * match by prefix
+func codecMatches(codec string, list []string) bool {
+	for _, c := range list {
+		c = strings.ToLower(c)
+		if strings.HasSuffix(c, "*") {
+			if strings.HasPrefix(codec, strings.TrimSuffix(c, "*")) {
+				return true
+			}
+		} else if codec == c {
+			return true
+		}
+	}
+	return false
+}
+
+// isLosslessAudio reports whether a stream carries lossless audio (DTS only in its HD MA profile)
+func isLosslessAudio(s StreamInfo) bool {
+	if s.CodecName == "dts" {
+		return strings.Contains(s.Profile, "MA")
+	}
+	return codecMatches(s.CodecName, losslessAudioCodecs)
+}
+
+// matchAudioRule returns the first rule matching the stream's codec and channel count
+func matchAudioRule(s StreamInfo, rules []config.AudioRule) (config.AudioRule, bool) {
+	for _, r := range rules {
+		if len(r.Codecs) > 0 && !codecMatches(s.CodecName, r.Codecs) {
+			continue
+		}
+		if r.MinChannels > 0 && s.Channels < r.MinChannels {
+			continue
+		}
+		if r.MaxChannels > 0 && s.Channels > r.MaxChannels {
+			continue
+		}
+		return r, true
+	}
+	return config.AudioRule{}, false
+}
+
+// audioBitrate scales the per-pair bitrate by the number of channel pairs (5.1 = 3 pairs)
+func audioBitrate(channels, perPair int) int {
+	pairs := (channels + 1) / 2
+	if pairs < 1 {
+		pairs = 1
+	}
+	return pairs * perPair
+}
+
+// planStreams decides which source streams reach the output and how each is encoded
+func (e *Encoder) planStreams() StreamPlan {
+	var plan StreamPlan
+	if e.Source == nil {
+		return plan
+	}
+
+	drops := make(map[int]string)
+	for _, s := range e.Source.Streams {
+		if reason := e.dropReason(s); reason != "" {
+			drops[s.Index] = reason
+		}
+	}
+
+	// Never leave the output without audio, whatever the language rules say
+	rescued, hasRescue := lastAudioTrack(e.Source.Streams, drops)
+	if hasRescue {
+		delete(drops, rescued.Index)
+	}
+
+	keptLossless := false
+	for _, s := range e.Source.Streams {
+		// Sidecars are planned before the drops, so -subs=none still extracts them
+		if s.CodecType == "subtitle" && e.sidecarWanted(s) {
+			e.planSidecar(&plan, s)
+		}
+		if reason, dropped := drops[s.Index]; dropped {
+			plan.Dropped = append(plan.Dropped, DroppedStream{Source: s, Reason: reason})
+			continue
+		}
+
+		switch s.CodecType {
+		case "video":
+			if isCoverArt(s) {
+				planCover(&plan, s)
+				continue
+			}
+			plan.Outputs = append(plan.Outputs, OutputStream{Source: s, Codec: "libsvtav1"})
+
+		case "audio":
+			rule, ok := matchAudioRule(s, e.Config.AudioRules)
+			reason := "no matching rule"
+			if ok {
+				reason = "rule: " + rule.String()
+			}
+			if hasRescue && s.Index == rescued.Index {
+				reason = "last audio track, " + reason
+			}
+
+			if !ok || rule.Action == config.AudioCopy {
+				plan.Outputs = append(plan.Outputs, OutputStream{Source: s, Codec: "copy", Reason: reason})
+				continue
+			}
+
+			plan.Outputs = append(plan.Outputs, OutputStream{
+				Source:  s,
+				Codec:   e.Config.AudioCodec,
+				Bitrate: audioBitrate(s.Channels, e.Config.AudioBitratePerPair),
+				Reason:  reason,
+			})
+
+			// Optionally keep the first lossless track untouched next to its transcode
+			if e.Config.KeepLosslessOriginal && !keptLossless && isLosslessAudio(s) {
+				keptLossless = true
+				plan.Outputs = append(plan.Outputs, OutputStream{Source: s, Codec: "copy", Reason: "lossless original"})
+			}
+
+		case "subtitle":
+			e.planSubtitle(&plan, s)
+
+		case "attachment":
+			reason := ""
+			if isFontAttachment(s) {
+				reason = "font for ASS subtitles"
+			}
+			plan.Outputs = append(plan.Outputs, OutputStream{Source: s, Codec: "copy", Reason: reason})
+
+		default:
+			plan.Outputs = append(plan.Outputs, OutputStream{Source: s, Codec: "copy"})
+		}
+	}
+
+	e.assignDispositions(&plan)
+	return plan
+}
+
+// streamArgs renders the plan as explicit -map, per-stream codec and disposition options
+func (p StreamPlan) streamArgs() []string {
+	var args []string
+	for _, o := range p.Outputs {
+		args = append(args, "-map", fmt.Sprintf("0:%d", o.Source.Index))
+	}
+
+	typeIndex := make(map[string]int)
+	for _, o := range p.Outputs {
+		var kind string
+		switch o.Source.CodecType {
+		case "audio":
+			kind = "a"
+		case "subtitle":
+			kind = "s"
+		default:
+			continue
+		}
+		spec := fmt.Sprintf("%s:%d", kind, typeIndex[kind])
+		typeIndex[kind]++
+
+		if o.Disposition != "" {
+			args = append(args, "-disposition:"+spec, o.Disposition)
+		}
+		if o.Source.CodecType == "subtitle" {
+			args = append(args, "-c:"+spec, o.Codec)
+			continue
+		}
+
+		args = append(args, "-c:"+spec, o.Codec)
+		if o.Codec == "copy" {
+			continue
+		}
+		args = append(args, "-b:"+spec, fmt.Sprintf("%dk", o.Bitrate))
+		if o.Codec == "libopus" && o.Source.Channels > 2 {
+			// libopus only accepts standard layouts; 5.1(side) etc. need remapping first
+			args = append(args,
+				"-mapping_family:"+spec, "1",
+				"-filter:"+spec, "aformat=channel_layouts=7.1|5.1|4.0|3.0|stereo",
+			)
+		}
+	}
+
+	return args
+}
@@ -0,0 +1,138 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func audioStream(index int, codec string, channels int) StreamInfo {
+	return StreamInfo{Index: index, CodecType: "audio", CodecName: codec, Channels: channels}
+}
+
+func TestMatchAudioRule(t *testing.T) {
+	rules := config.DefaultAudioRules()
+	tests := []struct {
+		stream StreamInfo
+		want   config.AudioAction
+		found  bool
+	}{
+		{audioStream(1, "aac", 2), config.AudioCopy, true},
+		{audioStream(1, "truehd", 8), config.AudioTranscode, true},
+		{audioStream(1, "pcm_s24le", 2), config.AudioTranscode, true},
+		{audioStream(1, "wmav2", 2), "", false},
+	}
+	for _, tc := range tests {
+		rule, ok := matchAudioRule(tc.stream, rules)
+		if ok != tc.found || rule.Action != tc.want {
+			t.Errorf("matchAudioRule(%s) = %v, %v; want %v, %v", tc.stream.CodecName, rule.Action, ok, tc.want, tc.found)
+		}
+	}
+
+	channelRules := []config.AudioRule{{MinChannels: 6, Action: config.AudioTranscode}}
+	if _, ok := matchAudioRule(audioStream(1, "eac3", 2), channelRules); ok {
+		t.Error("stereo stream should not match a 6+ channel rule")
+	}
+	if _, ok := matchAudioRule(audioStream(1, "eac3", 6), channelRules); !ok {
+		t.Error("5.1 stream should match a 6+ channel rule")
+	}
+}
+
+func TestAudioBitrate(t *testing.T) {
+	tests := []struct{ channels, want int }{
+		{1, 96}, {2, 96}, {6, 288}, {8, 384}, {0, 96},
+	}
+	for _, tc := range tests {
+		if got := audioBitrate(tc.channels, 96); got != tc.want {
+			t.Errorf("audioBitrate(%d, 96) = %d, want %d", tc.channels, got, tc.want)
+		}
+	}
+}
+
+func TestPlanStreams_DefaultCopiesAudio(t *testing.T) {
+	// Transcode rules are opt-in; by default lossless tracks must survive untouched
+	e := &Encoder{Config: config.DefaultConfig(), Source: &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "hevc"},
+		audioStream(1, "truehd", 8),
+		audioStream(2, "flac", 2),
+		audioStream(3, "pcm_s24le", 2),
+	}}}
+	for _, o := range e.planStreams().Outputs[1:] {
+		if o.Codec != "copy" {
+			t.Errorf("%s track planned as %s, want copy", o.Source.CodecName, o.Codec)
+		}
+	}
+}
+
+func TestPlanStreams(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AudioRules = config.DefaultAudioRules()
+	cfg.KeepLosslessOriginal = true
+	e := &Encoder{Config: cfg, Source: &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "hevc"},
+		audioStream(1, "truehd", 8),
+		audioStream(2, "ac3", 6),
+		audioStream(3, "flac", 2),
+		{Index: 4, CodecType: "data", CodecName: "bin_data"},
+	}}}
+
+	plan := e.planStreams()
+	var got []string
+	for _, o := range plan.Outputs {
+		got = append(got, o.Codec)
+	}
+	// truehd is transcoded and its original kept; the second lossless track is only transcoded
+	want := []string{"libsvtav1", "libopus", "copy", "copy", "libopus"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("plan codecs = %v, want %v", got, want)
+	}
+	if plan.Outputs[1].Bitrate != 384 {
+		t.Errorf("7.1 transcode bitrate = %d, want 384", plan.Outputs[1].Bitrate)
+	}
+	if plan.Count("audio") != 4 {
+		t.Errorf("Count(audio) = %d, want 4", plan.Count("audio"))
+	}
+	if len(plan.Dropped) != 1 || plan.Dropped[0].Source.CodecType != "data" {
+		t.Errorf("Dropped = %+v, want the data stream", plan.Dropped)
+	}
+
+	args := strings.Join(plan.streamArgs(), " ")
+	for _, want := range []string{
+		"-map 0:0 -map 0:1 -map 0:1 -map 0:2 -map 0:3",
+		"-c:a:0 libopus -b:a:0 384k -mapping_family:a:0 1",
+		"-c:a:1 copy",
+		"-c:a:2 copy",
+		"-c:a:3 libopus -b:a:3 96k",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("streamArgs %q missing %q", args, want)
+		}
+	}
+	if strings.Contains(args, "mapping_family:a:3") {
+		t.Errorf("stereo transcode should not set a mapping family: %q", args)
+	}
+}
+
+func TestPlanStreams_CopyAll(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AudioRules = nil
+	e := &Encoder{Config: cfg, Source: &MediaInfo{Streams: []StreamInfo{audioStream(0, "dts", 6)}}}
+
+	plan := e.planStreams()
+	if len(plan.Outputs) != 1 || plan.Outputs[0].Codec != "copy" {
+		t.Errorf("with no rules every track should be copied, got %+v", plan.Outputs)
+	}
+}
+
+func TestIsLosslessAudio(t *testing.T) {
+	if !isLosslessAudio(StreamInfo{CodecName: "dts", Profile: "DTS-HD MA"}) {
+		t.Error("DTS-HD MA should be lossless")
+	}
+	if isLosslessAudio(StreamInfo{CodecName: "dts", Profile: "DTS"}) {
+		t.Error("core DTS should not be lossless")
+	}
+	if !isLosslessAudio(StreamInfo{CodecName: "pcm_s16le"}) {
+		t.Error("PCM should be lossless")
+	}
+}
@@ -0,0 +1,157 @@
+package encoder
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// textSubtitleCodecs can be converted between each other without OCR
+var textSubtitleCodecs = []string{"subrip", "srt", "ass", "ssa", "webvtt", "mov_text", "text"}
+
+// Title words that mark forced-only subtitle tracks when the forced disposition is missing
+var forcedTitleWords = []string{"forced", "foreign", "signs"}
+
+// SidecarFile is a subtitle track extracted next to the output
+type SidecarFile struct {
+	Source StreamInfo
+	Path   string
+	Codec  string // "copy" or the subtitle encoder used to convert it
+}
+
+// isTextSubtitle reports whether a subtitle stream is text rather than bitmaps
+func isTextSubtitle(s StreamInfo) bool {
+	return codecMatches(s.CodecName, textSubtitleCodecs)
+}
+
+// isForcedSubtitle reports whether a subtitle track only covers foreign dialogue and signs,
+// from its disposition or, since many releases don't set it, its title
+func isForcedSubtitle(s StreamInfo) bool {
+	return s.Disposition["forced"] > 0 || titleHasWord(s, forcedTitleWords)
+}
+
+// subtitleCodec returns how a subtitle stream is written into the container:
+// "copy", a text encoder to convert to, or "" when the container can't hold it
+func subtitleCodec(s StreamInfo, container config.Container) string {
+	switch container {
+	case config.ContainerMP4:
+		switch {
+		case s.CodecName == "mov_text", s.CodecName == "dvd_subtitle":
+			return "copy"
+		case isTextSubtitle(s):
+			return "mov_text"
+		}
+		return "" // PGS and DVB bitmaps have no MP4 mapping
+	default:
+		// Matroska stores everything except MP4's own text format
+		if s.CodecName == "mov_text" || s.CodecName == "text" {
+			return "srt"
+		}
+		return "copy"
+	}
+}
+
+// sidecarFormat returns the file extension and codec used to extract a subtitle stream
+// ok is false for formats without a standalone file ffmpeg can write (DVD and DVB bitmaps)
+func sidecarFormat(s StreamInfo) (ext, codec string, ok bool) {
+	switch s.CodecName {
+	case "subrip", "srt":
+		return "srt", "copy", true
+	case "ass", "ssa":
+		return "ass", "copy", true
+	case "hdmv_pgs_subtitle":
+		return "sup", "copy", true
+	}
+	if isTextSubtitle(s) {
+		return "srt", "srt", true
+	}
+	return "", "", false
+}
+
+// sidecarPath names an extracted track after the output, e.g. movie.av1.3.eng.forced.srt
+func (e *Encoder) sidecarPath(s StreamInfo, ext string) string {
+	base := strings.TrimSuffix(e.OutputPath, filepath.Ext(e.OutputPath))
+	name := fmt.Sprintf("%s.%d.%s", base, s.Index, trackLanguage(s))
+	if isForcedSubtitle(s) {
+		name += ".forced"
+	}
+	return name + "." + ext
+}
+
+// subtitleDropReason applies SubtitleMode, returning "" to keep the track
+func (e *Encoder) subtitleDropReason(s StreamInfo) string {
+	switch e.Config.SubtitleMode {
+	case config.SubtitlesNone:
+		return "subtitles disabled"
+	case config.SubtitlesPreferred:
+		if isForcedSubtitle(s) || languageListed(s, []string{e.Config.PreferredLanguage}) {
+			return ""
+		}
+		return "not forced or " + e.Config.PreferredLanguage
+	}
+	return ""
+}
+
+// sidecarWanted keeps sidecars in step with the container's subtitles, except that
+// SubtitlesNone still extracts every track the language and role rules keep
+func (e *Encoder) sidecarWanted(s StreamInfo) bool {
+	if e.trackDropReason(s) != "" {
+		return false
+	}
+	return e.Config.SubtitleMode == config.SubtitlesNone || e.subtitleDropReason(s) == ""
+}
+
+// planSidecar extracts a subtitle stream to a sidecar file when enabled and its format allows
+func (e *Encoder) planSidecar(plan *StreamPlan, s StreamInfo) {
+	if !e.Config.ExtractSubtitles {
+		return
+	}
+	if ext, sidecarCodec, ok := sidecarFormat(s); ok {
+		plan.Sidecars = append(plan.Sidecars, SidecarFile{Source: s, Path: e.sidecarPath(s, ext), Codec: sidecarCodec})
+	}
+}
+
+// planSubtitle adds a kept subtitle stream to the plan: converted or copied into the
+// container, or dropped when the container can't hold it
+func (e *Encoder) planSubtitle(plan *StreamPlan, s StreamInfo) {
+	codec := subtitleCodec(s, e.Config.Container)
+
+	switch codec {
+	case "":
+		plan.Dropped = append(plan.Dropped, DroppedStream{Source: s,
+			Reason: fmt.Sprintf("%s not supported in %s", s.CodecName, e.Config.Container)})
+	case "copy":
+		plan.Outputs = append(plan.Outputs, OutputStream{Source: s, Codec: codec})
+	default:
+		plan.Outputs = append(plan.Outputs, OutputStream{Source: s, Codec: codec,
+			Reason: fmt.Sprintf("text converted for %s", e.Config.Container)})
+	}
+}
+
+// ExtractSubtitles writes the planned sidecar subtitle files in one demux pass
+// It returns a note per written file for the analysis display
+func (e *Encoder) ExtractSubtitles() ([]string, error) {
+	sidecars := e.planStreams().Sidecars
+	if len(sidecars) == 0 {
+		return nil, nil
+	}
+
+	args := []string{"-v", "error", "-i", e.InputPath}
+	for _, sc := range sidecars {
+		args = append(args, "-map", fmt.Sprintf("0:%d", sc.Source.Index), "-c:s", sc.Codec, "-y", sc.Path)
+	}
+
+	e.addLog(fmt.Sprintf("Extracting %d subtitle track(s)", len(sidecars)))
+	if _, err := e.runFFmpeg("", nil, args...); err != nil {
+		return nil, fmt.Errorf("subtitle extraction failed: %w", err)
+	}
+
+	var notes []string
+	for _, sc := range sidecars {
+		e.addLog("Extracted subtitle: " + sc.Path)
+		notes = append(notes, "Sidecar: "+filepath.Base(sc.Path))
+	}
+	return notes, nil
+}
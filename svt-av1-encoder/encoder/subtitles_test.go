@@ -0,0 +1,131 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func subtitle(index int, codec, lang, title string, disposition map[string]int) StreamInfo {
+	return StreamInfo{Index: index, CodecType: "subtitle", CodecName: codec,
+		Language: lang, Title: title, Disposition: disposition}
+}
+
+func TestSubtitleCodec(t *testing.T) {
+	tests := []struct {
+		codec     string
+		container config.Container
+		want      string
+	}{
+		{"subrip", config.ContainerMKV, "copy"},
+		{"hdmv_pgs_subtitle", config.ContainerMKV, "copy"},
+		{"mov_text", config.ContainerMKV, "srt"},
+		{"subrip", config.ContainerMP4, "mov_text"},
+		{"ass", config.ContainerMP4, "mov_text"},
+		{"mov_text", config.ContainerMP4, "copy"},
+		{"hdmv_pgs_subtitle", config.ContainerMP4, ""},
+	}
+	for _, tc := range tests {
+		if got := subtitleCodec(subtitle(2, tc.codec, "eng", "", nil), tc.container); got != tc.want {
+			t.Errorf("subtitleCodec(%s, %s) = %q, want %q", tc.codec, tc.container, got, tc.want)
+		}
+	}
+}
+
+func TestIsForcedSubtitle(t *testing.T) {
+	tests := []struct {
+		stream StreamInfo
+		want   bool
+	}{
+		{subtitle(2, "subrip", "eng", "", map[string]int{"forced": 1}), true},
+		{subtitle(2, "subrip", "eng", "English (Forced)", nil), true},
+		{subtitle(2, "ass", "eng", "Signs & Songs", nil), true},
+		{subtitle(2, "subrip", "eng", "English SDH", nil), false},
+	}
+	for _, tc := range tests {
+		if got := isForcedSubtitle(tc.stream); got != tc.want {
+			t.Errorf("isForcedSubtitle(%q) = %v, want %v", tc.stream.Title, got, tc.want)
+		}
+	}
+}
+
+func TestPlanStreams_Subtitles(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Container = config.ContainerMP4
+	cfg.SubtitleMode = config.SubtitlesPreferred
+	cfg.PreferredLanguage = "eng"
+	cfg.ExtractSubtitles = true
+	e := New("/videos/movie.mkv", cfg)
+	e.Source = &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		subtitle(1, "subrip", "eng", "", nil),
+		subtitle(2, "hdmv_pgs_subtitle", "eng", "", nil),
+		subtitle(3, "subrip", "fre", "", nil),
+		subtitle(4, "subrip", "fre", "Forced", nil),
+		{Index: 5, CodecType: "attachment", CodecName: "ttf"},
+	}}
+
+	plan := e.planStreams()
+	var kept []string
+	for _, o := range plan.Outputs[1:] {
+		kept = append(kept, o.Codec)
+	}
+	if plan.Count("subtitle") != 2 || strings.Join(kept, ",") != "mov_text,mov_text" {
+		t.Errorf("kept subtitle codecs = %v, want the eng and forced text tracks as mov_text", kept)
+	}
+
+	reasons := map[int]string{}
+	for _, d := range plan.Dropped {
+		reasons[d.Source.Index] = d.Reason
+	}
+	if reasons[2] != "hdmv_pgs_subtitle not supported in mp4" || reasons[3] != "not forced or eng" ||
+		reasons[5] != "attachments not supported in mp4" {
+		t.Errorf("drop reasons = %v", reasons)
+	}
+
+	var sidecars []string
+	for _, sc := range plan.Sidecars {
+		sidecars = append(sidecars, sc.Path)
+	}
+	want := []string{"/videos/movie.av1.1.eng.srt", "/videos/movie.av1.2.eng.sup", "/videos/movie.av1.4.fre.forced.srt"}
+	if strings.Join(sidecars, ",") != strings.Join(want, ",") {
+		t.Errorf("sidecars = %v, want %v", sidecars, want)
+	}
+
+	args := strings.Join(plan.streamArgs(), " ")
+	if !strings.Contains(args, "-c:s:0 mov_text") || !strings.Contains(args, "-c:s:1 mov_text") {
+		t.Errorf("streamArgs %q should convert both subtitles", args)
+	}
+	if e.OutputPath != "/videos/movie.av1.mp4" {
+		t.Errorf("OutputPath = %s, want .av1.mp4", e.OutputPath)
+	}
+}
+
+func TestPlanStreams_SubtitlesNoneStillExtracts(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.SubtitleMode = config.SubtitlesNone
+	cfg.ExtractSubtitles = true
+	cfg.KeepLanguages = []string{"eng"}
+	e := New("/videos/movie.mkv", cfg)
+	e.Source = &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		subtitle(1, "subrip", "eng", "", nil),
+		subtitle(2, "hdmv_pgs_subtitle", "eng", "", nil),
+		subtitle(3, "subrip", "ger", "", nil),
+	}}
+
+	plan := e.planStreams()
+	if plan.Count("subtitle") != 0 {
+		t.Errorf("no subtitles should go into the container, outputs: %+v", plan.Outputs)
+	}
+	var sidecars []string
+	for _, sc := range plan.Sidecars {
+		sidecars = append(sidecars, sc.Path)
+	}
+	// The language rules still apply to sidecars
+	want := []string{"/videos/movie.av1.1.eng.srt", "/videos/movie.av1.2.eng.sup"}
+	if strings.Join(sidecars, ",") != strings.Join(want, ",") {
+		t.Errorf("sidecars = %v, want %v", sidecars, want)
+	}
+}
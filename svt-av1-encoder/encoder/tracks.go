@@ -0,0 +1,227 @@
+package encoder
+
+import (
+	"fmt"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+// Title words that mark commentary and audio description tracks when the disposition flags are missing
+var (
+	commentaryTitleWords  = []string{"commentary"}
+	descriptiveTitleWords = []string{"description", "descriptive", "described"}
+)
+
+// trackLanguage returns the stream's language, treating untagged tracks as "und"
+func trackLanguage(s StreamInfo) string {
+	if s.Language == "" {
+		return "und"
+	}
+	return s.Language
+}
+
+// languageListed reports whether the stream's language is in list (case-insensitive)
+func languageListed(s StreamInfo, list []string) bool {
+	lang := trackLanguage(s)
+	for _, l := range list {
+		if strings.EqualFold(strings.TrimSpace(l), lang) {
+			return true
+		}
+	}
+	return false
+}
+
+// titleHasWord reports whether the stream title contains any of the words (case-insensitive)
+func titleHasWord(s StreamInfo, words []string) bool {
+	title := strings.ToLower(s.Title)
+	for _, w := range words {
+		if strings.Contains(title, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// isCommentary reports whether a track is commentary, from its disposition or title
+func isCommentary(s StreamInfo) bool {
+	return s.Disposition["comment"] > 0 || titleHasWord(s, commentaryTitleWords)
+}
+
+// isDescriptive reports whether an audio track is audio description for the visually impaired
+func isDescriptive(s StreamInfo) bool {
+	return s.Disposition["visual_impaired"] > 0 || titleHasWord(s, descriptiveTitleWords)
+}
+
+// isMainTrack reports whether a track is regular programme audio or subtitles rather than an extra
+func isMainTrack(s StreamInfo) bool {
+	return !isCommentary(s) && !isDescriptive(s)
+}
+
+// trackRole names a track's detected role for display ("" for main tracks)
+func trackRole(s StreamInfo) string {
+	switch {
+	case isCommentary(s):
+		return "commentary"
+	case isDescriptive(s):
+		return "descriptive"
+	}
+	return ""
+}
+
+// dropReason explains why a stream is left out of the output, or returns "" to keep it
+func (e *Encoder) dropReason(s StreamInfo) string {
+	if reason, ok := e.fallback.excluded[s.Index]; ok {
+		return reason
+	}
+	if reason := e.trackDropReason(s); reason != "" {
+		return reason
+	}
+	if s.CodecType == "subtitle" {
+		return e.subtitleDropReason(s)
+	}
+	return ""
+}
+
+// trackDropReason applies the language and role rules, which also decide which subtitles
+// become sidecars (see sidecarWanted)
+func (e *Encoder) trackDropReason(s StreamInfo) string {
+	switch s.CodecType {
+	case "data":
+		return "data stream"
+
+	case "video":
+		if isCoverArt(s) {
+			return e.coverDropReason()
+		}
+		for _, codec := range e.Config.RemoveImageCodecs {
+			if s.CodecName == codec {
+				return "image codec " + s.CodecName
+			}
+		}
+
+	case "audio", "subtitle":
+		if languageListed(s, e.Config.RemoveLanguages) {
+			return "language " + trackLanguage(s) + " removed"
+		}
+		if len(e.Config.KeepLanguages) > 0 && !languageListed(s, e.Config.KeepLanguages) {
+			return "language " + trackLanguage(s) + " not kept"
+		}
+		if e.Config.DropCommentary && isCommentary(s) {
+			return "commentary"
+		}
+		if s.CodecType == "audio" && e.Config.DropDescriptiveAudio && isDescriptive(s) {
+			return "audio description"
+		}
+
+	case "attachment":
+		if e.Config.Container == config.ContainerMP4 {
+			if isFontAttachment(s) {
+				return "fonts not supported in mp4"
+			}
+			return "attachments not supported in mp4"
+		}
+	}
+	return ""
+}
+
+// lastAudioTrack picks the audio track to keep when the language and role rules would
+// remove every one of them, preferring a main track over commentary or description
+func lastAudioTrack(streams []StreamInfo, drops map[int]string) (StreamInfo, bool) {
+	var audio []StreamInfo
+	for _, s := range streams {
+		if s.CodecType != "audio" {
+			continue
+		}
+		if _, dropped := drops[s.Index]; !dropped {
+			return StreamInfo{}, false
+		}
+		audio = append(audio, s)
+	}
+	if len(audio) == 0 {
+		return StreamInfo{}, false
+	}
+	for _, s := range audio {
+		if isMainTrack(s) {
+			return s, true
+		}
+	}
+	return audio[0], true
+}
+
+// assignDispositions sets default and forced flags so the preferred language plays by default
+// Audio: the first main track in the preferred language, else the first main track.
+// Subtitles: full subtitles when the default audio is in another language, otherwise
+// forced subtitles in the preferred language (for foreign dialogue); forced flags are kept.
+func (e *Encoder) assignDispositions(plan *StreamPlan) {
+	pref := strings.ToLower(strings.TrimSpace(e.Config.PreferredLanguage))
+	if pref == "" {
+		return
+	}
+
+	isAudio := func(o OutputStream) bool { return o.Source.CodecType == "audio" }
+	isMainAudio := func(o OutputStream) bool { return isAudio(o) && isMainTrack(o.Source) }
+	defaultAudio := plan.first(func(o OutputStream) bool { return isMainAudio(o) && trackLanguage(o.Source) == pref })
+	if defaultAudio < 0 {
+		defaultAudio = plan.first(isMainAudio)
+	}
+	if defaultAudio < 0 {
+		defaultAudio = plan.first(isAudio)
+	}
+
+	audioIsPreferred := defaultAudio >= 0 && trackLanguage(plan.Outputs[defaultAudio].Source) == pref
+	defaultSub := plan.first(func(o OutputStream) bool {
+		s := o.Source
+		return s.CodecType == "subtitle" && trackLanguage(s) == pref && isMainTrack(s) &&
+			isForcedSubtitle(s) == audioIsPreferred
+	})
+
+	for i := range plan.Outputs {
+		o := &plan.Outputs[i]
+		switch o.Source.CodecType {
+		case "audio":
+			o.Disposition = "0"
+			if i == defaultAudio {
+				o.Disposition = "default"
+			}
+		case "subtitle":
+			o.Disposition = "0"
+			if isForcedSubtitle(o.Source) {
+				o.Disposition = "forced"
+			}
+			if i == defaultSub {
+				if o.Disposition == "forced" {
+					o.Disposition = "default+forced"
+				} else {
+					o.Disposition = "default"
+				}
+			}
+		}
+	}
+}
+
+// first returns the index of the first output matching fn, or -1
+func (p StreamPlan) first(fn func(OutputStream) bool) int {
+	for i, o := range p.Outputs {
+		if fn(o) {
+			return i
+		}
+	}
+	return -1
+}
+
+// describeDefaults summarizes the default tracks chosen by assignDispositions, e.g. "audio #1 eng, subtitle #3 eng (forced)"
+func describeDefaults(plan StreamPlan) string {
+	var parts []string
+	for _, o := range plan.Outputs {
+		if !strings.HasPrefix(o.Disposition, "default") {
+			continue
+		}
+		part := fmt.Sprintf("%s #%d %s", o.Source.CodecType, o.Source.Index, trackLanguage(o.Source))
+		if o.Disposition == "default+forced" {
+			part += " (forced)"
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
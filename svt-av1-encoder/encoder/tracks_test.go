@@ -0,0 +1,142 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func track(index int, codecType, lang, title string, disposition map[string]int) StreamInfo {
+	return StreamInfo{Index: index, CodecType: codecType, CodecName: "aac", Channels: 2,
+		Language: lang, Title: title, Disposition: disposition}
+}
+
+func TestTrackRoles(t *testing.T) {
+	tests := []struct {
+		stream StreamInfo
+		want   string
+	}{
+		{track(1, "audio", "eng", "Director's Commentary", nil), "commentary"},
+		{track(1, "audio", "eng", "", map[string]int{"comment": 1}), "commentary"},
+		{track(1, "audio", "eng", "Audio Description", nil), "descriptive"},
+		{track(1, "audio", "eng", "", map[string]int{"visual_impaired": 1}), "descriptive"},
+		{track(1, "audio", "eng", "Surround 5.1", nil), ""},
+	}
+	for _, tc := range tests {
+		if got := trackRole(tc.stream); got != tc.want {
+			t.Errorf("trackRole(%q, %v) = %q, want %q", tc.stream.Title, tc.stream.Disposition, got, tc.want)
+		}
+	}
+}
+
+func TestPlanStreams_KeepLanguages(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AudioRules = nil
+	cfg.KeepLanguages = []string{"eng", "und"}
+	cfg.DropCommentary = true
+	e := &Encoder{Config: cfg, Source: &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		track(1, "audio", "fre", "", nil),
+		track(2, "audio", "eng", "", nil),
+		track(3, "audio", "", "", nil),
+		track(4, "audio", "eng", "Commentary", nil),
+		track(5, "subtitle", "ger", "", nil),
+	}}}
+
+	plan := e.planStreams()
+	var kept []int
+	for _, o := range plan.Outputs {
+		kept = append(kept, o.Source.Index)
+	}
+	if len(kept) != 3 || kept[1] != 2 || kept[2] != 3 {
+		t.Errorf("kept streams %v, want [0 2 3]", kept)
+	}
+	reasons := map[int]string{}
+	for _, d := range plan.Dropped {
+		reasons[d.Source.Index] = d.Reason
+	}
+	if reasons[1] != "language fre not kept" || reasons[4] != "commentary" || reasons[5] != "language ger not kept" {
+		t.Errorf("drop reasons = %v", reasons)
+	}
+}
+
+func TestPlanStreams_KeepsLastAudioTrack(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.AudioRules = nil
+	cfg.KeepLanguages = []string{"eng"}
+	e := &Encoder{Config: cfg, Source: &MediaInfo{Streams: []StreamInfo{
+		{Index: 0, CodecType: "video", CodecName: "h264"},
+		track(1, "audio", "jpn", "Commentary", nil),
+		track(2, "audio", "jpn", "", nil),
+	}}}
+
+	plan := e.planStreams()
+	if plan.Count("audio") != 1 {
+		t.Fatalf("expected one rescued audio track, got %+v", plan.Outputs)
+	}
+	if got := plan.Outputs[1]; got.Source.Index != 2 || !strings.HasPrefix(got.Reason, "last audio track") {
+		t.Errorf("rescued %+v, want the main jpn track #2", got)
+	}
+}
+
+func TestAssignDispositions(t *testing.T) {
+	tests := []struct {
+		name    string
+		streams []StreamInfo
+		want    []string // dispositions of the audio/subtitle outputs in order
+	}{
+		{
+			name: "preferred audio with forced subtitles",
+			streams: []StreamInfo{
+				track(1, "audio", "jpn", "", map[string]int{"default": 1}),
+				track(2, "audio", "eng", "Commentary", nil),
+				track(3, "audio", "eng", "", nil),
+				track(4, "subtitle", "eng", "", nil),
+				track(5, "subtitle", "eng", "Signs", map[string]int{"forced": 1}),
+			},
+			want: []string{"0", "0", "default", "0", "default+forced"},
+		},
+		{
+			name: "foreign audio gets full subtitles",
+			streams: []StreamInfo{
+				track(1, "audio", "jpn", "", nil),
+				track(2, "subtitle", "eng", "", map[string]int{"forced": 1}),
+				track(3, "subtitle", "eng", "", nil),
+			},
+			want: []string{"default", "forced", "default"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.DefaultConfig()
+			cfg.AudioRules = nil
+			cfg.PreferredLanguage = "eng"
+			e := &Encoder{Config: cfg, Source: &MediaInfo{Streams: tc.streams}}
+
+			plan := e.planStreams()
+			var got []string
+			for _, o := range plan.Outputs {
+				got = append(got, o.Disposition)
+			}
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("dispositions = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStreamArgs_Dispositions(t *testing.T) {
+	plan := StreamPlan{Outputs: []OutputStream{
+		{Source: track(1, "audio", "jpn", "", nil), Codec: "copy", Disposition: "0"},
+		{Source: track(2, "audio", "eng", "", nil), Codec: "copy", Disposition: "default"},
+		{Source: track(3, "subtitle", "eng", "", nil), Codec: "copy", Disposition: "default+forced"},
+	}}
+	args := strings.Join(plan.streamArgs(), " ")
+	for _, want := range []string{"-disposition:a:0 0", "-disposition:a:1 default", "-disposition:s:0 default+forced"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("streamArgs %q missing %q", args, want)
+		}
+	}
+}
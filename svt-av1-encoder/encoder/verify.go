@@ -0,0 +1,330 @@
+package encoder
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+// maxDecodeErrors limits how many decoder error lines are kept in a VerifyResult
+const maxDecodeErrors = 20
+
+// verifySampleSeconds is the length of the start, middle and end segments decoded
+// when the full decode is off
+const verifySampleSeconds = 10
+
+// outputExpectations is what a correct output should contain, derived from the
+// probed source and the stream selection rules
+type outputExpectations struct {
+	Duration        time.Duration
+	Frames          int64
+	FramesEstimated bool
+	Audio           int
+	Subtitles       int
+	Attachments     int
+	CoverArt        int
+	Chapters        int
+	Languages       []string // Audio and subtitle languages in output order, nil to skip the check
+	Title           string   // Container title that should survive, "" to skip the check
+}
+
+// VerifyResult summarizes the post-encode integrity check
+type VerifyResult struct {
+	OutputDuration time.Duration
+	OutputFrames   int64
+	Audio          int
+	Subtitles      int
+	Attachments    int
+	CoverArt       int
+	Chapters       int
+	Languages      []string
+	Title          string
+	SampledFrames  int64    // Frames decoded from the start, middle and end segments (0 after a full decode)
+	DecodeErrors   []string // Error lines from the decode
+	Problems       []string // Human-readable reasons the output was rejected
+}
+
+// OK reports whether the output passed every check
+func (r *VerifyResult) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Summary returns a one-line description of what was verified
+func (r *VerifyResult) Summary() string {
+	// Sampled checks of Matroska outputs have no total frame count, so show the duration instead
+	length := fmt.Sprintf("%d frames", r.OutputFrames)
+	if r.OutputFrames == 0 {
+		length = formatTimestamp(r.OutputDuration)
+	}
+	if r.SampledFrames > 0 {
+		length += fmt.Sprintf(" (%d frames decoded at start, middle and end)", r.SampledFrames)
+	}
+	summary := fmt.Sprintf("%s, %d audio, %d subtitle, %d chapters, %d attachments",
+		length, r.Audio, r.Subtitles, r.Chapters, r.Attachments)
+	if r.CoverArt > 0 {
+		summary += fmt.Sprintf(", %d cover", r.CoverArt)
+	}
+	return summary
+}
+
+// expectedOutput derives what the output should contain from the probed source
+func (e *Encoder) expectedOutput() outputExpectations {
+	want := outputExpectations{Duration: e.Source.Duration}
+	if e.Config.KeepChapters {
+		want.Chapters = len(e.Source.Chapters)
+	}
+	if e.Config.KeepMetadata {
+		want.Title = e.Source.Tags["title"]
+	}
+
+	plan := e.planStreams()
+	want.Audio = plan.Count("audio")
+	want.Subtitles = plan.Count("subtitle")
+	want.Attachments = plan.Count("attachment")
+	want.CoverArt = len(plan.Covers)
+
+	// MP4 rewrites language codes (fre → fra), so languages are only compared for Matroska
+	if e.Config.Container != config.ContainerMP4 {
+		var sources []StreamInfo
+		for _, o := range plan.Outputs {
+			sources = append(sources, o.Source)
+		}
+		want.Languages = streamLanguages(sources)
+	}
+
+	if video, ok := e.Source.VideoStream(); ok {
+		if video.NbFrames > 0 {
+			want.Frames = video.NbFrames
+		} else if fps := parseFrameRate(video.FrameRate); fps > 0 && want.Duration > 0 {
+			want.Frames = int64(want.Duration.Seconds() * fps)
+			want.FramesEstimated = true
+		}
+	}
+
+	// CFR conversion and VFR sources without nb_frames are counted at the output's frame rate
+	if video, ok := e.Source.VideoStream(); ok && want.Duration > 0 &&
+		(e.VFR.Convert() || (e.VFR.VFR && video.NbFrames == 0)) {
+		want.Frames = int64(want.Duration.Seconds() * e.outputFrameRate(parseFrameRate(video.FrameRate)))
+		want.FramesEstimated = true
+	}
+
+	// Inverse telecine drops frames, so the count is only approximately known
+	if factor := e.frameRateFactor(); factor != 1 && want.Frames > 0 && !e.VFR.Convert() {
+		want.Frames = int64(float64(want.Frames) * factor)
+		want.FramesEstimated = true
+	}
+
+	return want
+}
+
+// VerifyOutput checks the output against the source and decodes its start, middle and end,
+// or with VerifyDecode every frame
+// A non-nil error means verification could not run; a failed check is reported via VerifyResult.Problems
+func (e *Encoder) VerifyOutput() (*VerifyResult, error) {
+	if e.Source == nil {
+		if err := e.Probe(); err != nil {
+			return nil, err
+		}
+	}
+
+	output, err := ProbeFile(e.ctx, e.OutputPath)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read output: %w", err)
+	}
+
+	result := &VerifyResult{
+		OutputDuration: output.Duration,
+		Audio:          len(output.StreamsOfType("audio")),
+		Subtitles:      len(output.StreamsOfType("subtitle")),
+		Attachments:    len(output.StreamsOfType("attachment")),
+		Chapters:       len(output.Chapters),
+		Title:          output.Tags["title"],
+		Languages:      streamLanguages(output.Streams),
+	}
+	// Matroska demuxers expose image attachments as attached_pic video streams
+	for _, s := range output.StreamsOfType("video") {
+		if isCoverArt(s) {
+			result.CoverArt++
+		}
+	}
+
+	want := e.expectedOutput()
+	var decodeErr error
+	if e.Config.VerifyDecode {
+		e.addLog("Verifying output: full decode")
+		result.OutputFrames, decodeErr = e.decodeOutput(result)
+	} else {
+		e.addLog("Verifying output: decoding start, middle and end")
+		for _, at := range verifySampleStarts(output.Duration) {
+			frames, err := e.decodeOutput(result,
+				"-ss", fmt.Sprintf("%.3f", at.Seconds()), "-t", strconv.Itoa(verifySampleSeconds))
+			result.SampledFrames += frames
+			if err != nil && decodeErr == nil {
+				decodeErr = err
+			}
+		}
+		// Matroska doesn't record frame counts, so without a full decode they are only compared when present
+		if video, ok := output.VideoStream(); ok {
+			result.OutputFrames = video.NbFrames
+		}
+		if result.OutputFrames == 0 {
+			want.Frames = 0
+		}
+		if result.SampledFrames == 0 && decodeErr == nil {
+			result.Problems = append(result.Problems, "no frames decoded")
+		}
+	}
+	if decodeErr != nil {
+		result.Problems = append(result.Problems, fmt.Sprintf("decode failed: %v", decodeErr))
+	} else if len(result.DecodeErrors) > 0 {
+		result.Problems = append(result.Problems,
+			fmt.Sprintf("decoder reported errors: %s", result.DecodeErrors[0]))
+	}
+
+	result.Problems = append(result.Problems, checkOutput(want, result)...)
+
+	for _, p := range result.Problems {
+		e.addLog("Verification failed: " + p)
+	}
+	if result.OK() {
+		e.addLog("Verification passed: " + result.Summary())
+	}
+
+	return result, nil
+}
+
+// verifySampleStarts places the sampled segments at the start, middle and end of the output;
+// outputs too short for three segments are decoded from the start in one
+func verifySampleStarts(duration time.Duration) []time.Duration {
+	sample := time.Duration(verifySampleSeconds) * time.Second
+	if duration <= 3*sample {
+		return []time.Duration{0}
+	}
+	return []time.Duration{0, (duration - sample) / 2, duration - sample}
+}
+
+// decodeOutput decodes the output's video and audio streams, or only the span seek selects,
+// collecting decoder errors on result; it returns how many video frames were decoded
+func (e *Encoder) decodeOutput(result *VerifyResult, seek ...string) (int64, error) {
+	// -v error limits stderr to real problems
+	args := append([]string{"-v", "error", "-progress", "pipe:1"}, seek...)
+	args = append(args,
+		"-i", e.OutputPath,
+		"-map", "0:v",
+		"-map", "0:a?",
+		"-f", "null", "-",
+	)
+	var progress bytes.Buffer
+	stderr, err := e.runFFmpeg("", &progress, args...)
+
+	for _, line := range strings.Split(stderr, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if len(result.DecodeErrors) < maxDecodeErrors {
+			result.DecodeErrors = append(result.DecodeErrors, line)
+		}
+	}
+	return lastProgressValue(progress.String(), "frame"), err
+}
+
+// checkOutput compares the verified output with what the source says it should contain
+func checkOutput(want outputExpectations, got *VerifyResult) []string {
+	var problems []string
+
+	// Containers round durations differently and audio may run slightly longer than video
+	if want.Duration > 0 {
+		tolerance := time.Duration(float64(want.Duration) * 0.005)
+		if tolerance < time.Second {
+			tolerance = time.Second
+		}
+		diff := got.OutputDuration - want.Duration
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			problems = append(problems, fmt.Sprintf("duration %s differs from source %s",
+				got.OutputDuration.Round(time.Millisecond), want.Duration.Round(time.Millisecond)))
+		}
+	}
+
+	// Exact source frame counts get a tight tolerance, duration-based estimates a loose one
+	if want.Frames > 0 && got.OutputFrames > 0 {
+		tolerance := int64(math.Max(2, float64(want.Frames)*0.001))
+		if want.FramesEstimated {
+			tolerance = int64(math.Max(2, float64(want.Frames)*0.02))
+		}
+		diff := got.OutputFrames - want.Frames
+		if diff < 0 {
+			diff = -diff
+		}
+		if diff > tolerance {
+			problems = append(problems, fmt.Sprintf("decoded %d frames, source has %d", got.OutputFrames, want.Frames))
+		}
+	} else if want.Frames > 0 {
+		problems = append(problems, "no frames decoded")
+	}
+
+	counts := []struct {
+		name      string
+		want, got int
+	}{
+		{"audio streams", want.Audio, got.Audio},
+		{"subtitle streams", want.Subtitles, got.Subtitles},
+		{"attachments", want.Attachments, got.Attachments},
+		{"cover images", want.CoverArt, got.CoverArt},
+		{"chapters", want.Chapters, got.Chapters},
+	}
+	for _, c := range counts {
+		if c.got != c.want {
+			problems = append(problems, fmt.Sprintf("output has %d %s, expected %d", c.got, c.name, c.want))
+		}
+	}
+
+	if want.Languages != nil && len(want.Languages) == len(got.Languages) {
+		for i := range want.Languages {
+			if want.Languages[i] != got.Languages[i] {
+				problems = append(problems, fmt.Sprintf("track languages %s, expected %s",
+					strings.Join(got.Languages, ","), strings.Join(want.Languages, ",")))
+				break
+			}
+		}
+	}
+	if want.Title != "" && got.Title != want.Title {
+		problems = append(problems, fmt.Sprintf("title %q, expected %q", got.Title, want.Title))
+	}
+
+	return problems
+}
+
+// streamLanguages lists the languages of audio and subtitle streams in order
+func streamLanguages(streams []StreamInfo) []string {
+	langs := []string{}
+	for _, s := range streams {
+		if s.CodecType == "audio" || s.CodecType == "subtitle" {
+			langs = append(langs, trackLanguage(s))
+		}
+	}
+	return langs
+}
+
+// lastProgressValue returns the last integer value of key in ffmpeg -progress output
+func lastProgressValue(progress, key string) int64 {
+	var value int64
+	for _, line := range strings.Split(progress, "\n") {
+		k, v, ok := strings.Cut(strings.TrimSpace(line), "=")
+		if !ok || k != key {
+			continue
+		}
+		if n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64); err == nil {
+			value = n
+		}
+	}
+	return value
+}
@@ -0,0 +1,140 @@
+package encoder
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+func TestCheckOutput(t *testing.T) {
+	want := outputExpectations{
+		Duration:    10 * time.Minute,
+		Frames:      14386,
+		Audio:       2,
+		Subtitles:   1,
+		Attachments: 3,
+		Chapters:    12,
+	}
+	good := &VerifyResult{
+		OutputDuration: 10*time.Minute + 200*time.Millisecond,
+		OutputFrames:   14386,
+		Audio:          2,
+		Subtitles:      1,
+		Attachments:    3,
+		Chapters:       12,
+	}
+
+	if problems := checkOutput(want, good); len(problems) != 0 {
+		t.Errorf("matching output reported problems: %v", problems)
+	}
+
+	truncated := *good
+	truncated.OutputDuration = 7 * time.Minute
+	truncated.OutputFrames = 10000
+	problems := checkOutput(want, &truncated)
+	if len(problems) != 2 {
+		t.Errorf("truncated output: got %v, want duration and frame problems", problems)
+	}
+
+	missing := *good
+	missing.Subtitles = 0
+	missing.Chapters = 0
+	problems = checkOutput(want, &missing)
+	if len(problems) != 2 || !strings.Contains(problems[0], "subtitle") || !strings.Contains(problems[1], "chapters") {
+		t.Errorf("missing streams: got %v", problems)
+	}
+}
+
+func TestCheckOutput_EstimatedFrames(t *testing.T) {
+	want := outputExpectations{Frames: 10000, FramesEstimated: true}
+	got := &VerifyResult{OutputFrames: 10150}
+	if problems := checkOutput(want, got); len(problems) != 0 {
+		t.Errorf("1.5%% off an estimate should pass, got %v", problems)
+	}
+
+	want.FramesEstimated = false
+	if problems := checkOutput(want, got); len(problems) != 1 {
+		t.Errorf("1.5%% off an exact count should fail, got %v", problems)
+	}
+}
+
+func TestLastProgressValue(t *testing.T) {
+	progress := "frame=10\nfps=0.00\nprogress=continue\nframe=250\nfps=120.5\nprogress=end\n"
+	if got := lastProgressValue(progress, "frame"); got != 250 {
+		t.Errorf("lastProgressValue(frame) = %d, want 250", got)
+	}
+	if got := lastProgressValue(progress, "missing"); got != 0 {
+		t.Errorf("lastProgressValue(missing) = %d, want 0", got)
+	}
+}
+
+func TestExpectedOutput(t *testing.T) {
+	info, err := parseProbeOutput([]byte(sampleProbeJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.DefaultConfig()
+	e := &Encoder{Config: cfg, Source: info}
+
+	want := e.expectedOutput()
+	if want.Audio != 1 || want.Subtitles != 1 || want.Attachments != 1 || want.CoverArt != 1 || want.Chapters != 2 {
+		t.Errorf("expectedOutput = %+v", want)
+	}
+	if strings.Join(want.Languages, ",") != "eng,eng" {
+		t.Errorf("expected languages = %v, want eng,eng", want.Languages)
+	}
+	if !want.FramesEstimated || want.Frames != 14385 {
+		t.Errorf("expected estimated 14385 frames from 600s at 23.976fps, got %d (estimated=%v)", want.Frames, want.FramesEstimated)
+	}
+
+	cfg.RemoveLanguages = []string{"eng"}
+	e.Config = cfg
+	if want := e.expectedOutput(); want.Audio != 1 || want.Subtitles != 0 {
+		t.Errorf("removing eng should drop subtitles but keep the last audio track, got %+v", want)
+	}
+
+	cfg.KeepChapters = false
+	cfg.KeepCoverArt = false
+	e.Config = cfg
+	if want := e.expectedOutput(); want.Chapters != 0 || want.CoverArt != 0 {
+		t.Errorf("chapters and cover art disabled, got %+v", want)
+	}
+}
+
+func TestCheckOutput_Metadata(t *testing.T) {
+	want := outputExpectations{CoverArt: 1, Languages: []string{"eng", "jpn"}, Title: "Movie"}
+	got := &VerifyResult{CoverArt: 1, Languages: []string{"eng", "jpn"}, Title: "Movie"}
+	if problems := checkOutput(want, got); len(problems) != 0 {
+		t.Errorf("matching metadata reported problems: %v", problems)
+	}
+
+	got = &VerifyResult{Languages: []string{"jpn", "eng"}}
+	problems := checkOutput(want, got)
+	if len(problems) != 3 || !strings.Contains(problems[0], "cover") ||
+		!strings.Contains(problems[1], "languages") || !strings.Contains(problems[2], "title") {
+		t.Errorf("lost metadata: got %v", problems)
+	}
+}
+
+func TestVerifyResultSummary(t *testing.T) {
+	decoded := &VerifyResult{OutputFrames: 2400, OutputDuration: 100 * time.Second, Audio: 1, Chapters: 4}
+	if got := decoded.Summary(); got != "2400 frames, 1 audio, 0 subtitle, 4 chapters, 0 attachments" {
+		t.Errorf("decoded summary = %q", got)
+	}
+	// A sampled check of a Matroska output has no total frame count
+	sampled := &VerifyResult{OutputDuration: 3723 * time.Second, SampledFrames: 720, Audio: 2}
+	if got := sampled.Summary(); got != "1:02:03 (720 frames decoded at start, middle and end), 2 audio, 0 subtitle, 0 chapters, 0 attachments" {
+		t.Errorf("sampled summary = %q", got)
+	}
+}
+
+func TestVerifySampleStarts(t *testing.T) {
+	if got := verifySampleStarts(2 * time.Hour); len(got) != 3 || got[0] != 0 || got[1] != time.Hour-5*time.Second || got[2] != 2*time.Hour-10*time.Second {
+		t.Errorf("samples of a 2h output = %v", got)
+	}
+	if got := verifySampleStarts(25 * time.Second); len(got) != 1 || got[0] != 0 {
+		t.Errorf("short outputs should be decoded from the start once, got %v", got)
+	}
+}
@@ -0,0 +1,245 @@
+package encoder
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"svt-av1-encoder/config"
+)
+
+const (
+	// vfrSamples is how many windows of packet timestamps are read
+	vfrSamples = 3
+	// vfrSampleSeconds is the length of each window
+	vfrSampleSeconds = 20
+	// vfrIrregularShare is the share of frame intervals off the typical interval that marks a source VFR
+	vfrIrregularShare = 0.05
+	// vfrMinIntervals is how many intervals the timestamp check needs before it is trusted
+	vfrMinIntervals = 50
+)
+
+// standardFrameRates are the rates CFR conversion snaps to when no target is configured
+var standardFrameRates = []string{"24000/1001", "24", "25", "30000/1001", "30", "50", "60000/1001", "60"}
+
+// VFRAnalysis is the outcome of variable frame rate detection
+type VFRAnalysis struct {
+	VFR       bool
+	RealFPS   float64 // r_frame_rate: the timebase-derived rate, often the maximum for VFR
+	AvgFPS    float64 // avg_frame_rate: frames divided by duration
+	Irregular float64 // Share of sampled frame intervals that differ from the typical interval
+	Target    string  // Constant rate the encode converts to, "" to keep the source timing
+	Reason    string
+}
+
+// Convert reports whether the encode changes the frame rate
+func (a VFRAnalysis) Convert() bool {
+	return a.Target != ""
+}
+
+// frameIntervalStats sorts packet timestamps and returns the share of intervals that are
+// more than 10% off the median interval, plus the number of intervals considered
+// Gaps over a second are the jumps between sample windows and are skipped
+func frameIntervalStats(pts []float64) (float64, int) {
+	sorted := append([]float64(nil), pts...)
+	sort.Float64s(sorted)
+
+	var intervals []float64
+	for i := 1; i < len(sorted); i++ {
+		d := sorted[i] - sorted[i-1]
+		if d > 0 && d < 1 {
+			intervals = append(intervals, d)
+		}
+	}
+	if len(intervals) == 0 {
+		return 0, 0
+	}
+
+	typical := median(intervals)
+	irregular := 0
+	for _, d := range intervals {
+		if math.Abs(d-typical) > typical*0.1 {
+			irregular++
+		}
+	}
+	return float64(irregular) / float64(len(intervals)), len(intervals)
+}
+
+// parsePacketTimes reads the pts_time column of ffprobe's csv packet output
+func parsePacketTimes(output string) []float64 {
+	var pts []float64
+	for _, line := range strings.Split(output, "\n") {
+		field, _, _ := strings.Cut(strings.TrimSpace(line), ",")
+		if v, err := strconv.ParseFloat(field, 64); err == nil {
+			pts = append(pts, v)
+		}
+	}
+	return pts
+}
+
+// classifyVFR decides from the sampled timestamps, falling back to comparing the two
+// ffprobe rates when too few packets were read
+func classifyVFR(realFPS, avgFPS float64, pts []float64) VFRAnalysis {
+	a := VFRAnalysis{RealFPS: realFPS, AvgFPS: avgFPS}
+
+	share, n := frameIntervalStats(pts)
+	if n >= vfrMinIntervals {
+		a.Irregular = share
+		a.VFR = share > vfrIrregularShare
+		if a.VFR {
+			a.Reason = fmt.Sprintf("%.0f%% of frame intervals irregular", share*100)
+		} else {
+			a.Reason = "regular frame intervals"
+		}
+		return a
+	}
+
+	if realFPS > 0 && avgFPS > 0 && math.Abs(realFPS-avgFPS)/realFPS > 0.02 {
+		a.VFR = true
+		a.Reason = fmt.Sprintf("r_frame_rate %.3f vs avg_frame_rate %.3f", realFPS, avgFPS)
+		return a
+	}
+	a.Reason = "frame rates agree"
+	return a
+}
+
+// nearestStandardRate returns the common frame rate closest to fps
+func nearestStandardRate(fps float64) string {
+	best := standardFrameRates[0]
+	for _, rate := range standardFrameRates {
+		if math.Abs(parseFrameRate(rate)-fps) < math.Abs(parseFrameRate(best)-fps) {
+			best = rate
+		}
+	}
+	return best
+}
+
+// conversionTarget picks the constant rate for the configured mode, or "" to keep the source timing
+func conversionTarget(cfg config.Config, a VFRAnalysis) string {
+	if cfg.FrameRateMode != config.FrameRateCFR {
+		return ""
+	}
+	if cfg.TargetFrameRate != "" {
+		// A CFR source already at the target needs no conversion
+		if !a.VFR && math.Abs(parseFrameRate(cfg.TargetFrameRate)-a.RealFPS) < 0.001 {
+			return ""
+		}
+		return cfg.TargetFrameRate
+	}
+	if !a.VFR || a.AvgFPS <= 0 {
+		return ""
+	}
+	return nearestStandardRate(a.AvgFPS)
+}
+
+// DetectVFR samples packet timestamps from the video stream and stores the result on the encoder
+func (e *Encoder) DetectVFR() (VFRAnalysis, error) {
+	if e.Source == nil {
+		return VFRAnalysis{}, fmt.Errorf("frame rate detection needs a probed source")
+	}
+	video, ok := e.Source.VideoStream()
+	if !ok {
+		return VFRAnalysis{}, nil
+	}
+
+	var pts []float64
+	if e.Source.Duration > 0 {
+		e.addLog(fmt.Sprintf("Checking frame timing (%d x %ds samples)", vfrSamples, vfrSampleSeconds))
+
+		var intervals []string
+		for i := 0; i < vfrSamples; i++ {
+			at := e.Source.Duration.Seconds() * (float64(i) + 0.5) / vfrSamples
+			intervals = append(intervals, fmt.Sprintf("%.3f%%+%d", at, vfrSampleSeconds))
+		}
+		cmd := exec.CommandContext(e.ctx, "ffprobe",
+			"-v", "error",
+			"-select_streams", strconv.Itoa(video.Index),
+			"-read_intervals", strings.Join(intervals, ","),
+			"-show_entries", "packet=pts_time",
+			"-of", "csv=p=0",
+			e.InputPath,
+		)
+		output, err := cmd.Output()
+		if err != nil {
+			return VFRAnalysis{}, fmt.Errorf("reading packet timestamps failed: %w", err)
+		}
+		pts = parsePacketTimes(string(output))
+	}
+
+	result := classifyVFR(parseFrameRate(video.FrameRate), parseFrameRate(video.AvgFrameRate), pts)
+	result.Target = conversionTarget(e.Config, result)
+	e.VFR = result
+	e.addLog(fmt.Sprintf("Frame rate: %s (%s)", result.Describe(), result.Reason))
+	return result, nil
+}
+
+// Describe summarizes the detection and the handling, e.g. "VFR, avg 29.82 fps → CFR 30000/1001"
+func (a VFRAnalysis) Describe() string {
+	desc := fmt.Sprintf("constant %.3f fps", a.RealFPS)
+	if a.VFR {
+		desc = fmt.Sprintf("VFR, avg %.3f fps (r_frame_rate %.3f)", a.AvgFPS, a.RealFPS)
+	}
+	switch {
+	case a.Convert():
+		desc += " → CFR " + a.Target
+	case a.VFR:
+		desc += " → timestamps preserved"
+	}
+	return desc
+}
+
+// addFrameRateFilter appends the CFR conversion; it changes frame timing, so the quality
+// reference needs it too
+func (e *Encoder) addFrameRateFilter(chain *FilterChain) {
+	if e.VFR.Convert() {
+		chain.Add("fps=" + e.VFR.Target)
+	}
+}
+
+// frameRateArgs keeps VFR timestamps intact; without passthrough ffmpeg duplicates and
+// drops frames to make MP4 output constant rate
+func (e *Encoder) frameRateArgs() []string {
+	if e.VFR.VFR && !e.VFR.Convert() {
+		return []string{"-fps_mode:v", "passthrough"}
+	}
+	return nil
+}
+
+// outputFrameRate is the rate frames leave the filter chain at, used for frame totals
+// VFR sources use the average rate because r_frame_rate is usually the peak
+func (e *Encoder) outputFrameRate(probed float64) float64 {
+	switch {
+	case e.VFR.Convert():
+		return parseFrameRate(e.VFR.Target)
+	case e.VFR.VFR && e.VFR.AvgFPS > 0:
+		return e.VFR.AvgFPS
+	}
+	return probed
+}
+
+// applyFrameRateMode corrects SourceFPS and TotalFrames for VFR sources and CFR conversion,
+// so the frame-based progress agrees with the time-based one
+func (e *Encoder) applyFrameRateMode() {
+	if !e.VFR.VFR && !e.VFR.Convert() {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.Progress.TotalDuration == 0 && e.Source != nil {
+		e.Progress.TotalDuration = e.Source.Duration
+	}
+	e.Progress.SourceFPS = e.outputFrameRate(e.Progress.SourceFPS)
+
+	// nb_frames counts the source's frames exactly, which is what a preserved VFR encode outputs
+	if !e.VFR.Convert() && !e.Progress.FrameEstimated && e.Progress.TotalFrames > 0 {
+		return
+	}
+	if e.Progress.TotalDuration > 0 {
+		e.Progress.TotalFrames = int64(e.Progress.TotalDuration.Seconds() * e.Progress.SourceFPS)
+		e.Progress.FrameEstimated = true
+	}
+}
@@ -0,0 +1,117 @@
+package encoder
+
+import (
+	"testing"
+	"time"
+
+	"svt-av1-encoder/config"
+)
+
+// ptsSeries returns n timestamps spaced by the given intervals, cycling through them
+func ptsSeries(n int, intervals ...float64) []float64 {
+	pts := make([]float64, n)
+	for i := 1; i < n; i++ {
+		pts[i] = pts[i-1] + intervals[i%len(intervals)]
+	}
+	return pts
+}
+
+func TestClassifyVFR(t *testing.T) {
+	// Constant 30 fps, with packets in decode order and a jump to a second sample window
+	cfr := ptsSeries(100, 1.0/30)
+	cfr[3], cfr[4] = cfr[4], cfr[3]
+	cfr = append(cfr, ptsSeries(100, 1.0/30)...)
+	for i := 100; i < len(cfr); i++ {
+		cfr[i] += 120
+	}
+	if a := classifyVFR(30, 30, cfr); a.VFR {
+		t.Errorf("constant timestamps classified VFR: %+v", a)
+	}
+
+	// Phone footage: frames mostly at 30 fps with frequent longer gaps
+	phone := ptsSeries(200, 1.0/30, 1.0/30, 1.0/30, 1.0/20)
+	if a := classifyVFR(30, 28.3, phone); !a.VFR || a.Irregular < 0.2 {
+		t.Errorf("irregular timestamps not classified VFR: %+v", a)
+	}
+
+	// Too few packets: fall back to comparing r_frame_rate with avg_frame_rate
+	if a := classifyVFR(60, 29.7, nil); !a.VFR {
+		t.Errorf("r/avg mismatch without packets should be VFR: %+v", a)
+	}
+	if a := classifyVFR(24000.0/1001, 23.975, nil); a.VFR {
+		t.Errorf("matching rates without packets should be constant: %+v", a)
+	}
+}
+
+func TestParsePacketTimes(t *testing.T) {
+	pts := parsePacketTimes("0.033367,\n0.000000\nN/A\n\n0.066733\n")
+	if len(pts) != 3 || pts[2] != 0.066733 {
+		t.Errorf("parsePacketTimes = %v", pts)
+	}
+}
+
+func TestConversionTarget(t *testing.T) {
+	vfr := VFRAnalysis{VFR: true, RealFPS: 60, AvgFPS: 29.8}
+	constant := VFRAnalysis{RealFPS: 25, AvgFPS: 25}
+
+	tests := []struct {
+		name     string
+		mode     config.FrameRateMode
+		target   string
+		analysis VFRAnalysis
+		want     string
+	}{
+		{"preserve keeps VFR", config.FrameRatePreserve, "", vfr, ""},
+		{"cfr snaps to nearest standard rate", config.FrameRateCFR, "", vfr, "30000/1001"},
+		{"cfr uses explicit target", config.FrameRateCFR, "30", vfr, "30"},
+		{"cfr leaves constant source alone", config.FrameRateCFR, "", constant, ""},
+		{"explicit target converts constant source", config.FrameRateCFR, "24", constant, "24"},
+		{"constant source already at target", config.FrameRateCFR, "25", constant, ""},
+	}
+	for _, tc := range tests {
+		cfg := config.DefaultConfig()
+		cfg.FrameRateMode, cfg.TargetFrameRate = tc.mode, tc.target
+		if got := conversionTarget(cfg, tc.analysis); got != tc.want {
+			t.Errorf("%s: conversionTarget = %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestApplyFrameRateMode(t *testing.T) {
+	newEncoder := func(vfr VFRAnalysis) *Encoder {
+		e := &Encoder{Config: config.DefaultConfig(), VFR: vfr}
+		e.Progress.SourceFPS = 60 // r_frame_rate
+		e.Progress.TotalDuration = 100 * time.Second
+		e.Progress.TotalFrames = 6000
+		e.Progress.FrameEstimated = true
+		return e
+	}
+
+	// Preserved VFR: r_frame_rate over-estimates, the average rate doesn't
+	e := newEncoder(VFRAnalysis{VFR: true, RealFPS: 60, AvgFPS: 29.5})
+	e.applyFrameRateMode()
+	if e.Progress.TotalFrames != 2950 || e.Progress.SourceFPS != 29.5 {
+		t.Errorf("preserve: TotalFrames=%d SourceFPS=%v, want 2950 at 29.5", e.Progress.TotalFrames, e.Progress.SourceFPS)
+	}
+
+	// Exact nb_frames counts are kept when timestamps are preserved
+	e = newEncoder(VFRAnalysis{VFR: true, RealFPS: 60, AvgFPS: 29.5})
+	e.Progress.TotalFrames, e.Progress.FrameEstimated = 2948, false
+	e.applyFrameRateMode()
+	if e.Progress.TotalFrames != 2948 {
+		t.Errorf("preserve with nb_frames: TotalFrames=%d, want 2948", e.Progress.TotalFrames)
+	}
+
+	// CFR conversion outputs duration × target frames
+	e = newEncoder(VFRAnalysis{VFR: true, RealFPS: 60, AvgFPS: 29.5, Target: "25"})
+	e.applyFrameRateMode()
+	if e.Progress.TotalFrames != 2500 || e.Progress.SourceFPS != 25 {
+		t.Errorf("cfr: TotalFrames=%d SourceFPS=%v, want 2500 at 25", e.Progress.TotalFrames, e.Progress.SourceFPS)
+	}
+	if args := e.frameRateArgs(); args != nil {
+		t.Errorf("cfr should not pass timestamps through, got %v", args)
+	}
+	if chain := e.buildFilterChain().String(); chain != "fps=25" {
+		t.Errorf("cfr filter chain = %q, want fps=25", chain)
+	}
+}
@@ -1,25 +1,101 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 
 	"svt-av1-encoder/config"
+	"svt-av1-encoder/encoder"
 	"svt-av1-encoder/tui"
 )
 
 func main() {
+	// Subcommands are checked before flag parsing so they can take their own arguments
+	if len(os.Args) > 1 && os.Args[1] == "provenance" {
+		os.Exit(runProvenance(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "compare" {
+		os.Exit(runCompare(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "frames" {
+		os.Exit(runFrames(os.Args[2:]))
+	}
+
 	// Define flags
-	profileFlag := flag.String("profile", "default", "Encoding profile: default, quality, podcast, compress, extreme, film")
+	profileFlag := flag.String("profile", "default", "Encoding profile: default, quality, podcast, compress, extreme, film, auto")
+	autoRulesFlag := flag.String("auto-rules", "", "File with rules mapping content to profiles for -profile=auto (see -list-profiles)")
 	listProfiles := flag.Bool("list-profiles", false, "List all available profiles and exit")
+	versionFlag := flag.Bool("version", false, "Print the version and exit")
+	provenanceFlag := flag.Bool("provenance", true, "Tag the output with the settings, encoder build and source fingerprint")
+	forceFlag := flag.Bool("force", false, "Encode even if the input was produced by this tool")
+	dryRun := flag.Bool("dry-run", false, "Analyze the input and print the stream decisions and ffmpeg command without encoding")
+	previewFlag := flag.Bool("preview", false, "Encode short sample clips and project the full size, bitrate and encode time")
+	previewClips := flag.Int("preview-clips", 5, "Number of -preview clips, spread through the source")
+	previewLength := flag.Float64("preview-length", 20, "Length of each -preview clip in seconds")
+	audioFlag := flag.String("audio", "copy", "Audio handling: copy (every track as-is) or rules (copy AAC/Opus/AC3, transcode DTS/TrueHD/FLAC/PCM to lossy Opus)")
+	audioBitrate := flag.Int("audio-bitrate", 96, "Transcoded audio bitrate in kbps per channel pair")
+	keepLossless := flag.Bool("keep-lossless", false, "Also keep the first transcoded lossless track untouched")
+	keepLangFlag := flag.String("keep-lang", "", "Keep only audio/subtitle tracks in these languages, e.g. eng,jpn,und (und = untagged)")
+	defaultLangFlag := flag.String("default-lang", "", "Language flagged as default audio in the output (default: first -keep-lang entry)")
+	dropCommentary := flag.Bool("drop-commentary", false, "Remove commentary tracks")
+	dropDescriptive := flag.Bool("drop-descriptive", false, "Remove audio description tracks")
+	containerFlag := flag.String("container", "mkv", "Output container: mkv, mp4")
+	subsFlag := flag.String("subs", "all", "Subtitle tracks to keep: all, preferred (forced + default language), none")
+	extractSubs := flag.Bool("extract-subs", false, "Also extract kept subtitles to sidecar .srt/.ass/.sup files")
+	chaptersFlag := flag.Bool("chapters", true, "Copy chapter markers")
+	metadataFlag := flag.Bool("metadata", true, "Copy global container tags such as the title")
+	coverArtFlag := flag.Bool("cover-art", true, "Re-attach embedded cover art as a Matroska attachment")
+	gopFlag := flag.Float64("gop", 0, "Maximum keyframe interval in seconds (default: profile setting)")
+	keyframesFlag := flag.String("keyframes", "fixed", "Keyframe placement: fixed (regular GOP from frame rate, no scene-change keyframes) or svt (SVT-AV1 keyint + scene detection)")
+	chapterKeyframes := flag.Bool("chapter-keyframes", false, "Force keyframes at chapter starts")
+	grainFlag := flag.String("grain", "", "Film-grain estimation: off, suggest, apply (default: profile setting)")
+	sdrFlag := flag.Bool("sdr", false, "Also encode a tonemapped SDR (BT.709) copy of HDR sources after the main encode")
+	sdrProfileFlag := flag.String("sdr-profile", "", "Profile for the SDR copy (default: same as the main encode)")
+	tonemapFlag := flag.String("tonemap", "hable", "Tonemapping curve for the SDR copy: hable, mobius, bt2390")
+	sdrSuffixFlag := flag.String("sdr-suffix", ".sdr", "File name suffix for the SDR copy, before .av1.<container>")
+	vfrFlag := flag.String("vfr", "preserve", "Variable frame rate handling: preserve (keep timestamps) or cfr (convert to constant)")
+	fpsFlag := flag.String("fps", "", "Constant output frame rate for -vfr=cfr, e.g. 30 or 30000/1001 (implies cfr; default: nearest standard rate)")
+	filtersFlag := flag.String("filters", "", "Pre-encode video filters in order, e.g. hqdn3d=2:1.5:3:3,deband or none (default: profile setting)")
+	minBPPFlag := flag.String("min-bpp", "", "Skip sources below a video bits/pixel: auto, a number, or per class like sd=0.1,hd=0.07,fhd=0.05,uhd=0.03")
+	cropFlag := flag.String("crop", "off", "Black-bar cropping: off, auto, conservative")
+	deinterlaceFlag := flag.String("deinterlace", "auto", "Interlace/telecine handling: auto, off (auto decodes six 10s samples with idet before encoding, unless the stream is flagged progressive)")
+	deinterlacerFlag := flag.String("deinterlacer", "bwdif", "Deinterlace filter: bwdif, yadif")
+	maxResFlag := flag.String("max-res", "", "Downscale to fit a resolution cap: 2160p, 1440p, 1080p, 720p, 480p or WxH (default: profile setting)")
+	scalerFlag := flag.String("scaler", "", "Resize filter when downscaling: lanczos, spline36, bicubic (default: profile setting)")
+	verifyFlag := flag.Bool("verify", true, "Check the output's duration, streams and chapters against the source after encoding and decode its start, middle and end")
+	verifyDecode := flag.Bool("verify-decode", false, "Fully decode the output during -verify to catch corrupt frames and check the frame count (slow: a full decode pass)")
+	qualityFlag := flag.String("quality", "", "Measure quality after encoding: comma-separated vmaf, ssim, psnr")
+	qualityStride := flag.Int("quality-stride", 10, "Compare every Nth frame during the quality check")
+	spaceCheck := flag.Bool("space-check", true, "Refuse to start when the output filesystem can't hold the estimated output plus -space-margin")
+	spaceMargin := flag.String("space-margin", "1G", "Free space required on top of the estimated output size, e.g. 500M or 2G")
+	minFree := flag.String("min-free", "512M", "Stop the encode when free space on the output filesystem drops below this (0 = never)")
+	stallTimeout := flag.Duration("stall-timeout", 5*time.Minute, "Treat the encode as hung when frames stop advancing this long (0 = never)")
+	stallAction := flag.String("stall-action", "warn", "When the encode stalls: warn, cancel, restart")
+	stallRestarts := flag.Int("stall-restarts", 2, "Restarts allowed with -stall-action=restart before the job is cancelled")
+	retriesFlag := flag.Int("retries", 2, "Retry a failed encode up to N times with fallbacks chosen from the error (drop a stream, change preset, ...)")
+	sheetFlag := flag.Bool("contact-sheet", false, "Render a tiled contact sheet of output frames next to the file when done")
+	sheetFrames := flag.Int("sheet-frames", 16, "Number of frames on the contact sheet")
+	sheetColumns := flag.Int("sheet-columns", 4, "Contact sheet thumbnails per row")
+	sheetFormat := flag.String("sheet-format", "jpg", "Contact sheet image format: jpg, png")
 
 	// Custom usage
 	flag.Usage = func() {
 		fmt.Println("Usage: svt-av1-encoder [options] <input-file>")
+		fmt.Println("       svt-av1-encoder provenance <file>...")
+		fmt.Println("       svt-av1-encoder compare [flags] <file>")
+		fmt.Println("       svt-av1-encoder frames [flags] <source> [output]")
+		fmt.Println()
+		fmt.Println("Subcommands:")
+		fmt.Println("  provenance  Show the settings recorded in encoded files")
+		fmt.Println("  compare     Encode sample clips with several profiles and compare the results")
+		fmt.Println("  frames      Export matching source/output frames side by side")
 		fmt.Println()
 		fmt.Println("Encodes video using FFmpeg with SVT-AV1-HDR encoder.")
 		fmt.Println()
@@ -35,10 +111,30 @@ func main() {
 		fmt.Println("  svt-av1-encoder movie.mkv                    # Use default profile")
 		fmt.Println("  svt-av1-encoder -profile=podcast video.mp4   # Use podcast profile")
 		fmt.Println("  svt-av1-encoder -profile=quality movie.mkv   # Use quality profile")
+		fmt.Println("  svt-av1-encoder -profile=auto movie.mkv      # Pick a profile from the content")
+		fmt.Println("  svt-av1-encoder -quality=vmaf,ssim movie.mkv # Report VMAF/SSIM when done")
+		fmt.Println("  svt-av1-encoder -crop=auto movie.mkv         # Remove stable black bars")
+		fmt.Println("  svt-av1-encoder -dry-run movie.mkv           # Show decisions and command only")
+		fmt.Println("  svt-av1-encoder -keep-lang=eng,jpn,und a.mkv # Keep English/Japanese, English default")
+		fmt.Println("  svt-av1-encoder -extract-subs movie.mkv      # Also write .srt/.ass/.sup sidecars")
+		fmt.Println("  svt-av1-encoder -min-bpp=auto movie.mkv      # Skip sources already low on bits/pixel")
+		fmt.Println("  svt-av1-encoder -filters=hqdn3d,deband a.mkv # Denoise and deband before encoding")
+		fmt.Println("  svt-av1-encoder -fps=30 screen.mp4           # Convert a VFR recording to 30 fps")
+		fmt.Println("  svt-av1-encoder -sdr -tonemap=mobius hdr.mkv # Also make an SDR copy for non-HDR screens")
+		fmt.Println("  svt-av1-encoder -preview movie.mkv           # Encode 5×20s clips, project size and time")
+		fmt.Println("  svt-av1-encoder -contact-sheet movie.mkv     # Write movie.av1.sheet.jpg when done")
+		fmt.Println("  svt-av1-encoder provenance movie.av1.mkv     # Show how a file was encoded")
+		fmt.Println("  svt-av1-encoder compare -profiles=a,b x.mkv  # Compare profiles on sample clips")
+		fmt.Println("  svt-av1-encoder frames -zoom=center x.mkv    # Export source/output frames side by side")
 	}
 
 	flag.Parse()
 
+	if *versionFlag {
+		fmt.Println("svt-av1-encoder " + encoder.Version)
+		os.Exit(0)
+	}
+
 	// Handle --list-profiles
 	if *listProfiles {
 		fmt.Println("Available encoding profiles:")
@@ -48,8 +144,20 @@ func main() {
 			fmt.Printf("  %s\n", p)
 			fmt.Printf("    %s\n", config.ProfileDescription(p))
 			fmt.Printf("    CRF: %d, Preset: %d\n", cfg.CRF, cfg.Preset)
+			if cfg.MaxWidth > 0 || cfg.MaxHeight > 0 {
+				fmt.Printf("    Max resolution: %dx%d\n", cfg.MaxWidth, cfg.MaxHeight)
+			}
+			if len(cfg.PreFilters) > 0 {
+				fmt.Printf("    Filters: %s\n", config.FiltersString(cfg.PreFilters))
+			}
 			fmt.Println()
 		}
+		fmt.Printf("  %s\n", config.ProfileAuto)
+		fmt.Printf("    %s\n", config.ProfileDescription(config.ProfileAuto))
+		fmt.Println("    Rules (first match wins; override with -auto-rules):")
+		for _, r := range config.DefaultAutoRules() {
+			fmt.Printf("      %s\n", r.Text)
+		}
 		os.Exit(0)
 	}
 
@@ -70,7 +178,7 @@ func main() {
 
 	// Parse profile
 	profile := config.Profile(strings.ToLower(*profileFlag))
-	validProfile := false
+	validProfile := profile == config.ProfileAuto
 	for _, p := range config.AvailableProfiles() {
 		if p == profile {
 			validProfile = true
@@ -79,13 +187,308 @@ func main() {
 	}
 	if !validProfile {
 		fmt.Fprintf(os.Stderr, "Error: Unknown profile '%s'\n", *profileFlag)
-		fmt.Fprintf(os.Stderr, "Available profiles: default, quality, podcast, compress, extreme, film\n")
+		fmt.Fprintf(os.Stderr, "Available profiles: default, quality, podcast, compress, extreme, film, auto\n")
 		os.Exit(1)
 	}
 
 	// Get configuration for selected profile
 	cfg := config.GetProfile(profile)
 
+	switch mode := config.CropMode(strings.ToLower(*cropFlag)); mode {
+	case config.CropOff, config.CropAuto, config.CropConservative:
+		cfg.CropMode = mode
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown crop mode '%s' (use off, auto or conservative)\n", *cropFlag)
+		os.Exit(1)
+	}
+
+	switch mode := config.DeinterlaceMode(strings.ToLower(*deinterlaceFlag)); mode {
+	case config.DeinterlaceAuto, config.DeinterlaceOff:
+		cfg.DeinterlaceMode = mode
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown deinterlace mode '%s' (use auto or off)\n", *deinterlaceFlag)
+		os.Exit(1)
+	}
+
+	switch d := config.Deinterlacer(strings.ToLower(*deinterlacerFlag)); d {
+	case config.DeinterlacerBwdif, config.DeinterlacerYadif:
+		cfg.Deinterlacer = d
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown deinterlacer '%s' (use bwdif or yadif)\n", *deinterlacerFlag)
+		os.Exit(1)
+	}
+
+	if *maxResFlag != "" {
+		w, h, err := parseResolution(*maxResFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.MaxWidth, cfg.MaxHeight = w, h
+	}
+
+	if *scalerFlag != "" {
+		switch scaler := config.Scaler(strings.ToLower(*scalerFlag)); scaler {
+		case config.ScalerLanczos, config.ScalerSpline36, config.ScalerBicubic:
+			cfg.Scaler = scaler
+		default:
+			fmt.Fprintf(os.Stderr, "Error: Unknown scaler '%s' (use lanczos, spline36 or bicubic)\n", *scalerFlag)
+			os.Exit(1)
+		}
+	}
+
+	switch strings.ToLower(*audioFlag) {
+	case "rules":
+		cfg.AudioRules = config.DefaultAudioRules()
+	case "copy":
+		cfg.AudioRules = nil
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown audio mode '%s' (use copy or rules)\n", *audioFlag)
+		os.Exit(1)
+	}
+	if *audioBitrate <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -audio-bitrate must be positive\n")
+		os.Exit(1)
+	}
+	cfg.AudioBitratePerPair = *audioBitrate
+	cfg.KeepLosslessOriginal = *keepLossless
+
+	// Language keep-list; the preferred language defaults to the first real language listed
+	if *keepLangFlag != "" {
+		cfg.KeepLanguages = parseLanguages(*keepLangFlag)
+		for _, lang := range cfg.KeepLanguages {
+			if lang != "und" {
+				cfg.PreferredLanguage = lang
+				break
+			}
+		}
+	}
+	if *defaultLangFlag != "" {
+		cfg.PreferredLanguage = strings.ToLower(strings.TrimSpace(*defaultLangFlag))
+	}
+	cfg.DropCommentary = *dropCommentary
+	cfg.DropDescriptiveAudio = *dropDescriptive
+
+	switch c := config.Container(strings.ToLower(*containerFlag)); c {
+	case config.ContainerMKV, config.ContainerMP4:
+		cfg.Container = c
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown container '%s' (use mkv or mp4)\n", *containerFlag)
+		os.Exit(1)
+	}
+
+	switch mode := config.SubtitleMode(strings.ToLower(*subsFlag)); mode {
+	case config.SubtitlesAll, config.SubtitlesNone:
+		cfg.SubtitleMode = mode
+	case config.SubtitlesPreferred:
+		if cfg.PreferredLanguage == "" {
+			fmt.Fprintf(os.Stderr, "Error: -subs=preferred needs -default-lang or -keep-lang\n")
+			os.Exit(1)
+		}
+		cfg.SubtitleMode = mode
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown subtitle mode '%s' (use all, preferred or none)\n", *subsFlag)
+		os.Exit(1)
+	}
+	cfg.ExtractSubtitles = *extractSubs
+	if *gopFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -gop must be positive\n")
+		os.Exit(1)
+	} else if *gopFlag > 0 {
+		cfg.GOPSeconds = *gopFlag
+	}
+	switch mode := config.KeyframeMode(strings.ToLower(*keyframesFlag)); mode {
+	case config.KeyframesFixed, config.KeyframesSVT:
+		cfg.KeyframeMode = mode
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown keyframe mode '%s' (use fixed or svt)\n", *keyframesFlag)
+		os.Exit(1)
+	}
+	cfg.ChapterKeyframes = *chapterKeyframes
+
+	if *grainFlag != "" {
+		switch mode := config.GrainMode(strings.ToLower(*grainFlag)); mode {
+		case config.GrainOff, config.GrainSuggest, config.GrainApply:
+			cfg.GrainMode = mode
+		default:
+			fmt.Fprintf(os.Stderr, "Error: Unknown grain mode '%s' (use off, suggest or apply)\n", *grainFlag)
+			os.Exit(1)
+		}
+	}
+
+	cfg.SDRCompanion = *sdrFlag
+	cfg.SDRSuffix = *sdrSuffixFlag
+	switch mapper := config.ToneMapper(strings.ToLower(*tonemapFlag)); mapper {
+	case config.ToneMapHable, config.ToneMapMobius, config.ToneMapBT2390:
+		cfg.ToneMapper = mapper
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown tonemapper '%s' (use hable, mobius or bt2390)\n", *tonemapFlag)
+		os.Exit(1)
+	}
+	if *sdrProfileFlag != "" {
+		sdrProfile := config.Profile(strings.ToLower(*sdrProfileFlag))
+		valid := false
+		for _, p := range config.AvailableProfiles() {
+			if p == sdrProfile {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			fmt.Fprintf(os.Stderr, "Error: Unknown SDR profile '%s'\n", *sdrProfileFlag)
+			os.Exit(1)
+		}
+		cfg.SDRProfile = sdrProfile
+	}
+	if cfg.SDRSuffix == "" {
+		fmt.Fprintf(os.Stderr, "Error: -sdr-suffix must not be empty (the SDR copy would overwrite the main output)\n")
+		os.Exit(1)
+	}
+
+	switch mode := config.FrameRateMode(strings.ToLower(*vfrFlag)); mode {
+	case config.FrameRatePreserve, config.FrameRateCFR:
+		cfg.FrameRateMode = mode
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown VFR mode '%s' (use preserve or cfr)\n", *vfrFlag)
+		os.Exit(1)
+	}
+	if *fpsFlag != "" {
+		if err := validateFrameRate(*fpsFlag); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.FrameRateMode = config.FrameRateCFR
+		cfg.TargetFrameRate = *fpsFlag
+	}
+
+	if *filtersFlag != "" {
+		filters, err := config.ParseFilters(*filtersFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.PreFilters = filters
+	}
+
+	if *minBPPFlag != "" {
+		thresholds, err := parseMinBPP(*minBPPFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg.MinBitsPerPixel = thresholds
+	}
+
+	if *autoRulesFlag != "" {
+		data, err := os.ReadFile(*autoRulesFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		rules, err := config.ParseAutoRules(string(data))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", *autoRulesFlag, err)
+			os.Exit(1)
+		}
+		cfg.AutoRules = rules
+	}
+
+	cfg.WriteProvenance = *provenanceFlag
+	cfg.SkipEncoded = !*forceFlag
+	cfg.KeepChapters = *chaptersFlag
+	cfg.KeepMetadata = *metadataFlag
+	cfg.KeepCoverArt = *coverArtFlag
+
+	cfg.VerifyOutput = *verifyFlag
+	cfg.VerifyDecode = *verifyDecode
+
+	// Parse disk space protection
+	margin, err := parseSize(*spaceMargin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -space-margin: %v\n", err)
+		os.Exit(1)
+	}
+	minimum, err := parseSize(*minFree)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: -min-free: %v\n", err)
+		os.Exit(1)
+	}
+	cfg.CheckDiskSpace = *spaceCheck
+	cfg.FreeSpaceMargin = margin
+	cfg.MinFreeSpace = minimum
+
+	// Parse stall watchdog options
+	switch action := config.StallAction(strings.ToLower(*stallAction)); action {
+	case config.StallWarn, config.StallCancel, config.StallRestart:
+		cfg.StallAction = action
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown stall action '%s' (use warn, cancel or restart)\n", *stallAction)
+		os.Exit(1)
+	}
+	if *stallTimeout < 0 || *stallRestarts < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -stall-timeout and -stall-restarts must not be negative\n")
+		os.Exit(1)
+	}
+	cfg.StallTimeout = *stallTimeout
+	cfg.StallRestarts = *stallRestarts
+
+	if *retriesFlag < 0 {
+		fmt.Fprintf(os.Stderr, "Error: -retries must not be negative\n")
+		os.Exit(1)
+	}
+	cfg.MaxRetries = *retriesFlag
+
+	// Parse quality check options
+	if *qualityFlag != "" {
+		metrics, err := parseQualityMetrics(*qualityFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *qualityStride < 1 {
+			fmt.Fprintf(os.Stderr, "Error: -quality-stride must be at least 1\n")
+			os.Exit(1)
+		}
+		cfg.QualityMetrics = metrics
+		cfg.QualitySampleStride = *qualityStride
+	}
+
+	switch {
+	case *sheetFrames < 1 || *sheetColumns < 1:
+		fmt.Fprintf(os.Stderr, "Error: -sheet-frames and -sheet-columns must be positive\n")
+		os.Exit(1)
+	case *sheetFormat != "jpg" && *sheetFormat != "png":
+		fmt.Fprintf(os.Stderr, "Error: Unknown sheet format '%s' (use jpg or png)\n", *sheetFormat)
+		os.Exit(1)
+	}
+	cfg.ContactSheet = *sheetFlag
+	cfg.ContactSheetFrames = *sheetFrames
+	cfg.ContactSheetColumns = *sheetColumns
+	cfg.ContactSheetFormat = *sheetFormat
+
+	if *previewClips < 1 || *previewLength <= 0 {
+		fmt.Fprintf(os.Stderr, "Error: -preview-clips and -preview-length must be positive\n")
+		os.Exit(1)
+	}
+	cfg.PreviewClips = *previewClips
+	cfg.PreviewSeconds = *previewLength
+
+	if *previewFlag {
+		if err := runPreview(inputFile, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	if *dryRun {
+		if err := printDryRun(inputFile, cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	// Create and run the TUI
 	model := tui.NewModel(inputFile, cfg)
 	p := tea.NewProgram(model, tea.WithAltScreen())
@@ -95,3 +498,415 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// parseQualityMetrics splits and validates the -quality flag value
+func parseQualityMetrics(value string) ([]string, error) {
+	var metrics []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		valid := false
+		for _, known := range config.QualityMetricNames() {
+			if name == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown quality metric '%s' (available: %s)",
+				name, strings.Join(config.QualityMetricNames(), ", "))
+		}
+		metrics = append(metrics, name)
+	}
+	return metrics, nil
+}
+
+// parseLanguages splits a comma-separated language list into lowercase codes
+func parseLanguages(value string) []string {
+	var langs []string
+	for _, lang := range strings.Split(value, ",") {
+		lang = strings.ToLower(strings.TrimSpace(lang))
+		if lang != "" {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// parseMinBPP parses -min-bpp: "auto" for the suggested thresholds, one number for every
+// resolution class, or class=value pairs
+func parseMinBPP(value string) (map[config.ResolutionClass]float64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "auto" {
+		return config.DefaultMinBitsPerPixel(), nil
+	}
+
+	thresholds := make(map[config.ResolutionClass]float64)
+	if v, err := strconv.ParseFloat(value, 64); err == nil {
+		if v < 0 {
+			return nil, fmt.Errorf("-min-bpp must not be negative")
+		}
+		for _, class := range config.ResolutionClasses() {
+			thresholds[class] = v
+		}
+		return thresholds, nil
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, num, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		v, err := strconv.ParseFloat(num, 64)
+		if !ok || err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid -min-bpp entry '%s' (use e.g. fhd=0.05)", pair)
+		}
+		class := config.ResolutionClass(name)
+		valid := false
+		for _, known := range config.ResolutionClasses() {
+			if class == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return nil, fmt.Errorf("unknown resolution class '%s' (use sd, hd, fhd or uhd)", name)
+		}
+		thresholds[class] = v
+	}
+	return thresholds, nil
+}
+
+// validateFrameRate accepts a -fps value as a positive number or fraction ("25", "30000/1001")
+func validateFrameRate(value string) error {
+	num, den, isFraction := strings.Cut(value, "/")
+	n, err := strconv.ParseFloat(num, 64)
+	d := 1.0
+	if err == nil && isFraction {
+		d, err = strconv.ParseFloat(den, 64)
+	}
+	if err != nil || n <= 0 || d <= 0 || n/d > 240 {
+		return fmt.Errorf("invalid frame rate '%s' (use e.g. 30 or 30000/1001)", value)
+	}
+	return nil
+}
+
+// parseResolution converts a -max-res value ("1080p", "1280x720", "none") to a width/height cap
+func parseResolution(value string) (int, int, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	switch value {
+	case "none", "0":
+		return 0, 0, nil
+	case "2160p", "4k":
+		return 3840, 2160, nil
+	case "1440p":
+		return 2560, 1440, nil
+	case "1080p":
+		return 1920, 1080, nil
+	case "720p":
+		return 1280, 720, nil
+	case "480p":
+		return 854, 480, nil
+	}
+
+	var w, h int
+	if _, err := fmt.Sscanf(value, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid resolution '%s' (use e.g. 1080p or 1920x1080)", value)
+	}
+	return w, h, nil
+}
+
+// printDryRun runs the pre-encode analysis and prints what an encode would do
+func printDryRun(inputFile string, cfg config.Config) error {
+	enc := encoder.New(inputFile, cfg)
+	notes, err := enc.Prepare()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Input:   %s\n", inputFile)
+	fmt.Printf("Output:  %s\n", enc.OutputPath)
+	fmt.Printf("Profile: %s\n", enc.Config.ProfileName)
+	if prov := enc.SourceProvenance(); prov != nil {
+		fmt.Printf("Note:    already encoded by %s (profile %s); skipped unless -force\n", prov.Tool, prov.Profile)
+	}
+	if reason := enc.BitrateSkipReason(); reason != "" {
+		fmt.Printf("Note:    would be skipped: %s\n", reason)
+	}
+	if space, err := enc.CheckDiskSpace(); err != nil {
+		fmt.Printf("Note:    would not start: %v\n", err)
+	} else if space != "" {
+		notes = append(notes, space)
+	}
+	for _, note := range notes {
+		fmt.Printf("  %s\n", note)
+	}
+
+	fmt.Println()
+	fmt.Println("Streams:")
+	for _, line := range enc.StreamPlan().Describe() {
+		fmt.Printf("  %s\n", line)
+	}
+
+	fmt.Println()
+	fmt.Println("Command:")
+	fmt.Printf("  %s\n", enc.CommandLine())
+
+	if enc.NeedsSDRCompanion() {
+		companion := enc.SDRCompanion()
+		notes, err := companion.Prepare()
+		if err != nil {
+			return fmt.Errorf("SDR companion: %w", err)
+		}
+		fmt.Println()
+		fmt.Printf("SDR companion: %s\n", companion.OutputPath)
+		for _, note := range notes {
+			fmt.Printf("  %s\n", note)
+		}
+		fmt.Printf("  %s\n", companion.CommandLine())
+	}
+	return nil
+}
+
+// runPreview encodes the sample clips with the chosen settings and prints the projection
+func runPreview(inputFile string, cfg config.Config) error {
+	enc := encoder.New(inputFile, cfg)
+	notes, err := enc.Prepare()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Input:   %s\n", inputFile)
+	fmt.Printf("Profile: %s\n", enc.Config.ProfileName)
+	for _, note := range notes {
+		fmt.Printf("  %s\n", note)
+	}
+	fmt.Println()
+	fmt.Printf("Encoding %d × %gs preview clips...\n", cfg.PreviewClips, cfg.PreviewSeconds)
+
+	report, err := enc.EncodePreview()
+	if err != nil {
+		return err
+	}
+	for _, line := range report.Lines() {
+		fmt.Printf("  %s\n", line)
+	}
+	return nil
+}
+
+// runCompare implements "svt-av1-encoder compare [flags] <file>", encoding the same sample
+// clips with each profile and writing a size/speed/quality table next to the input
+func runCompare(args []string) int {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	profilesFlag := fs.String("profiles", "quality,default,compress", "Profiles to compare, in table order")
+	metricsFlag := fs.String("quality", "vmaf,ssim", "Quality metrics per profile: vmaf, ssim, psnr")
+	clipsFlag := fs.Int("clips", 5, "Number of sample clips, spread through the source")
+	lengthFlag := fs.Float64("clip-length", 20, "Length of each sample clip in seconds")
+	strideFlag := fs.Int("quality-stride", 5, "Compare every Nth frame of the clips")
+	cropFlag := fs.String("crop", "off", "Black-bar cropping: off, auto, conservative")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: svt-av1-encoder compare [flags] <file>")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 1
+	}
+	inputFile := fs.Arg(0)
+	if _, err := os.Stat(inputFile); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var profiles []config.Profile
+	for _, name := range strings.Split(*profilesFlag, ",") {
+		p := config.Profile(strings.ToLower(strings.TrimSpace(name)))
+		valid := false
+		for _, known := range config.AvailableProfiles() {
+			if p == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			fmt.Fprintf(os.Stderr, "Error: Unknown profile '%s' (auto can't be compared)\n", name)
+			return 1
+		}
+		profiles = append(profiles, p)
+	}
+
+	metrics, err := parseQualityMetrics(*metricsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	if *clipsFlag < 1 || *lengthFlag <= 0 || *strideFlag < 1 {
+		fmt.Fprintf(os.Stderr, "Error: -clips, -clip-length and -quality-stride must be positive\n")
+		return 1
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.QualityMetrics = metrics
+	cfg.QualitySampleStride = *strideFlag
+	cfg.PreviewClips = *clipsFlag
+	cfg.PreviewSeconds = *lengthFlag
+	switch mode := config.CropMode(strings.ToLower(*cropFlag)); mode {
+	case config.CropOff, config.CropAuto, config.CropConservative:
+		cfg.CropMode = mode
+	default:
+		fmt.Fprintf(os.Stderr, "Error: Unknown crop mode '%s' (use off, auto or conservative)\n", *cropFlag)
+		return 1
+	}
+
+	comparison := encoder.NewComparison(inputFile, cfg, profiles)
+	p := tea.NewProgram(tui.NewCompareModel(comparison), tea.WithAltScreen())
+	final, err := p.Run()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	// The alt screen is gone once the program exits, so repeat the table on the terminal
+	m := final.(tui.CompareModel)
+	if m.Report != nil {
+		fmt.Print(m.Report.Markdown())
+	}
+	if m.ErrorMsg != "" {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", m.ErrorMsg)
+		return 1
+	}
+	return 0
+}
+
+// runFrames implements "svt-av1-encoder frames [flags] <source> [output]", exporting
+// matching source/output frames for side-by-side review
+func runFrames(args []string) int {
+	fs := flag.NewFlagSet("frames", flag.ExitOnError)
+	atFlag := fs.String("at", "", "Comma-separated timestamps, e.g. 0:12:05,1:02:30.5 (default: scene cuts)")
+	scenesFlag := fs.Int("scenes", 6, "Number of scene cuts to export when -at is not given (found by decoding the output)")
+	zoomFlag := fs.String("zoom", "", "Also export 2× crops of a region: WxH+X+Y in output pixels, or center")
+	dirFlag := fs.String("dir", "", "Folder for the images (default: <output>.frames next to the output)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: svt-av1-encoder frames [flags] <source> [output]")
+		fmt.Fprintln(os.Stderr, "The output defaults to the file an encode of <source> would produce.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return 1
+	}
+
+	enc := encoder.New(fs.Arg(0), config.DefaultConfig())
+	if fs.NArg() == 2 {
+		enc.OutputPath = fs.Arg(1)
+	}
+	for _, path := range []string{enc.InputPath, enc.OutputPath} {
+		if _, err := os.Stat(path); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+	}
+
+	var zoom *encoder.ZoomRect
+	if *zoomFlag != "" {
+		z, err := encoder.ParseZoom(*zoomFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		zoom = z
+	}
+
+	var times []time.Duration
+	if *atFlag != "" {
+		for _, value := range strings.Split(*atFlag, ",") {
+			at, err := encoder.ParseTimestamp(value)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				return 1
+			}
+			times = append(times, at)
+		}
+	} else {
+		cuts, err := enc.SceneCutTimes(*scenesFlag)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		times = cuts
+	}
+	if len(times) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no timestamps to export (the output has no scene cuts; use -at)")
+		return 1
+	}
+
+	dir := *dirFlag
+	if dir == "" {
+		dir = encoder.FramesDir(enc.OutputPath)
+	}
+	frames, err := enc.ExportFrames(times, zoom, dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	for _, f := range frames {
+		fmt.Println(f.Stacked)
+		if f.Zoom != "" {
+			fmt.Println(f.Zoom)
+		}
+	}
+	fmt.Printf("%d frames exported to %s\n", len(frames), dir)
+	return 0
+}
+
+// runProvenance implements "svt-av1-encoder provenance <file>...", printing the tags an
+// earlier encode wrote; it exits non-zero if any file has none, so scripts can test for it
+func runProvenance(files []string) int {
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: svt-av1-encoder provenance <file>...")
+		return 1
+	}
+
+	status := 0
+	for i, file := range files {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s:\n", file)
+
+		prov, err := encoder.ReadProvenance(context.Background(), file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			status = 1
+			continue
+		}
+		if prov == nil {
+			fmt.Println("  no provenance tags (not produced by svt-av1-encoder)")
+			status = 1
+			continue
+		}
+		for _, line := range prov.Lines() {
+			fmt.Printf("  %s\n", line)
+		}
+	}
+	return status
+}
+
+// parseSize reads a byte count with an optional binary K, M, G or T suffix ("512M", "1.5G", "0")
+func parseSize(value string) (int64, error) {
+	v := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	v = strings.TrimSuffix(v, "I")
+	multiplier := 1.0
+	if n := len(v); n > 0 {
+		if i := strings.IndexByte("KMGT", v[n-1]); i >= 0 {
+			multiplier = float64(int64(1) << (10 * (i + 1)))
+			v = v[:n-1]
+		}
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size '%s' (use bytes or a K, M, G, T suffix, e.g. 512M)", value)
+	}
+	return int64(n * multiplier), nil
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"svt-av1-encoder/config"
+)
+
+func TestParseMinBPP(t *testing.T) {
+	all, err := parseMinBPP("0.03")
+	if err != nil || len(all) != len(config.ResolutionClasses()) || all[config.ResolutionFHD] != 0.03 {
+		t.Errorf("single value = %v, %v", all, err)
+	}
+	perClass, err := parseMinBPP("fhd=0.05,uhd=0.02")
+	if err != nil || perClass[config.ResolutionFHD] != 0.05 || perClass[config.ResolutionUHD] != 0.02 {
+		t.Errorf("per-class values = %v, %v", perClass, err)
+	}
+
+	for _, value := range []string{"-0.01", "fhd=-0.01", "fhd", "4k=0.05"} {
+		if _, err := parseMinBPP(value); err == nil {
+			t.Errorf("parseMinBPP(%q) should fail", value)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	"svt-av1-encoder/encoder"
+)
+
+// CompareProbedMsg is sent when the comparison source has been probed
+type CompareProbedMsg struct {
+	Err error
+}
+
+// CompareStepMsg is sent when one profile has been encoded and measured
+type CompareStepMsg struct {
+	Result *encoder.CompareResult
+	Err    error
+}
+
+// CompareModel is the Bubble Tea model for "compare": it runs the profiles one at a time
+// and fills in the table as each finishes
+type CompareModel struct {
+	Comparison *encoder.Comparison
+	Next       int // Index of the profile being run
+	Probed     bool
+	Results    []encoder.CompareResult
+	StartTime  time.Time
+	Done       bool
+	Report     *encoder.CompareReport
+	ErrorMsg   string
+}
+
+// NewCompareModel creates the comparison TUI
+func NewCompareModel(c *encoder.Comparison) CompareModel {
+	return CompareModel{Comparison: c}
+}
+
+// Init probes the source before the first profile runs
+func (m CompareModel) Init() tea.Cmd {
+	c := m.Comparison
+	return tea.Batch(
+		tea.EnterAltScreen,
+		func() tea.Msg { return CompareProbedMsg{Err: c.Probe()} },
+	)
+}
+
+// runNext encodes and measures the next profile in the background
+func (m CompareModel) runNext() tea.Cmd {
+	c := m.Comparison
+	profile := c.Profiles[m.Next]
+	return func() tea.Msg {
+		result, err := c.RunProfile(profile)
+		return CompareStepMsg{Result: result, Err: err}
+	}
+}
+
+// finish writes the Markdown/CSV tables and shows the final view
+func (m CompareModel) finish() (CompareModel, tea.Cmd) {
+	m.Done = true
+	m.Report = m.Comparison.Report(m.Results)
+	if err := m.Report.Write(); err != nil {
+		m.ErrorMsg = "Failed to write report: " + err.Error()
+	}
+	m.Comparison.Close()
+	return m, nil
+}
+
+// Update handles messages and advances through the profiles
+func (m CompareModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			m.Comparison.Stop()
+			m.Comparison.Close()
+			return m, tea.Quit
+		}
+
+	case CompareProbedMsg:
+		if msg.Err != nil {
+			m.Done = true
+			m.ErrorMsg = msg.Err.Error()
+			return m, nil
+		}
+		m.Probed = true
+		m.StartTime = time.Now()
+		return m, tea.Batch(m.runNext(), tickCmd())
+
+	case TickMsg:
+		// Keeps the elapsed time moving while a profile runs
+		if !m.Done {
+			return m, tickCmd()
+		}
+
+	case CompareStepMsg:
+		if msg.Err != nil {
+			// Keep the profiles that finished; a failed one shouldn't lose them
+			m.ErrorMsg = fmt.Sprintf("%s: %v", m.Comparison.Profiles[m.Next], msg.Err)
+			return m.finish()
+		}
+		m.Results = append(m.Results, *msg.Result)
+		m.Next++
+		if m.Next < len(m.Comparison.Profiles) {
+			return m, m.runNext()
+		}
+		return m.finish()
+	}
+	return m, nil
+}
+
+// View renders the table so far and what is running
+func (m CompareModel) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render(" ⚡ SVT-AV1-HDR Profile Comparison ") + "\n\n")
+
+	c := m.Comparison
+	b.WriteString(fileLabelStyle.Render("Input") + filePathStyle.Render(c.InputPath) + "\n")
+	b.WriteString(statUnitStyle.Render(fmt.Sprintf("  %d × %gs clips, metrics: %s",
+		c.Config.PreviewClips, c.Config.PreviewSeconds, strings.Join(c.Config.QualityMetrics, ", "))) + "\n\n")
+
+	report := m.Report
+	if report == nil {
+		report = c.Report(m.Results)
+	}
+	if len(report.Results) > 0 {
+		b.WriteString(statsBoxStyle.Render(renderCompareTable(report.Rows())) + "\n")
+	}
+
+	switch {
+	case m.ErrorMsg != "":
+		b.WriteString(errorStyle.Render("  ✗ "+m.ErrorMsg) + "\n")
+	case !m.Done && !m.Probed:
+		b.WriteString(statValueStyle.Render("  Probing source...") + "\n")
+	case !m.Done:
+		elapsed := time.Since(m.StartTime).Round(time.Second)
+		b.WriteString(statValueStyle.Render(fmt.Sprintf("  Encoding %s (%d/%d)...  %s elapsed",
+			c.Profiles[m.Next], m.Next+1, len(c.Profiles), formatDuration(elapsed))) + "\n")
+	}
+	if m.Done && report.MarkdownPath != "" {
+		b.WriteString(successStyle.Render("  ✓ Comparison complete") + "\n")
+		b.WriteString(statLabelStyle.Render("Markdown") + filePathStyle.Render(report.MarkdownPath) + "\n")
+		b.WriteString(statLabelStyle.Render("CSV") + filePathStyle.Render(report.CSVPath) + "\n")
+	}
+
+	b.WriteString("\n" + helpStyle.Render("  [Q] Quit") + "\n")
+	return b.String()
+}
+
+// renderCompareTable pads the rows into aligned columns, header highlighted
+func renderCompareTable(rows [][]string) string {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], lipgloss.Width(cell))
+		}
+	}
+
+	var lines []string
+	for r, row := range rows {
+		cells := make([]string, len(row))
+		for i, cell := range row {
+			cells[i] = cell + strings.Repeat(" ", widths[i]-lipgloss.Width(cell))
+		}
+		line := strings.Join(cells, "  ")
+		if r == 0 {
+			line = sectionHeaderStyle.Render(line)
+		} else {
+			line = statValueStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
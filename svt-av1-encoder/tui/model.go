@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -22,6 +23,7 @@ const (
 	StateDone
 	StateError
 	StateSkipped
+	StateVerifying
 )
 
 type SkippedMsg struct {
@@ -30,16 +32,46 @@ type SkippedMsg struct {
 
 // EncoderStartedMsg is sent when the encoder has started successfully
 type EncoderStartedMsg struct {
-	Encoder *encoder.Encoder
+	Encoder  *encoder.Encoder
+	Analysis []string // Pre-encode decisions (crop etc.) to show alongside progress
 }
 
 type EncoderErrorMsg struct {
 	Err error
 }
 
+// VerifyMsg is sent when the post-encode integrity check finishes
+type VerifyMsg struct {
+	Result *encoder.VerifyResult
+	Err    error
+}
+
+// QualityMsg is sent when the post-encode quality pass finishes
+type QualityMsg struct {
+	Report *encoder.QualityReport
+	Err    error
+}
+
+// ContactSheetMsg is sent when the contact sheet has been rendered
+type ContactSheetMsg struct {
+	Path string
+	Err  error
+}
+
+// FinishedJob keeps a completed encode's results on screen while a linked job runs
+type FinishedJob struct {
+	Encoder *encoder.Encoder
+	Elapsed time.Duration
+	Verify  *encoder.VerifyResult
+	Quality *encoder.QualityReport
+	Sheet   string // Contact sheet path, "" when none was rendered
+	Retried []encoder.Attempt
+}
+
 // Model is the Bubble Tea model for the TUI
 type Model struct {
 	Encoder         *encoder.Encoder
+	Preparing       *encoder.Encoder // Job being probed and analysed, so quitting can stop its ffmpeg passes
 	Config          config.Config
 	State           State
 	Progress        progress.Model
@@ -50,8 +82,18 @@ type Model struct {
 	InputFile       string
 	StartTime       time.Time
 	ErrorMessage    string
+	Failure         *encoder.EncodeError // Recognised cause of the error, nil when unclassified
+	Attempts        []encoder.Attempt    // ffmpeg runs of the current job, more than one after retries
 	SkippedReason   string
 	CurrentProgress encoder.Progress // Local safe copy
+	Analysis        []string         // Pre-encode decisions shown with the progress
+	VerifyStatus    string           // What the post-encode pass is doing
+	Verify          *encoder.VerifyResult
+	Quality         *encoder.QualityReport
+	QualityError    string
+	ContactSheet    string // Rendered contact sheet path
+	SheetError      string
+	Finished        []FinishedJob // Earlier jobs in the chain (the HDR encode when its SDR companion runs)
 }
 
 // TickMsg is sent periodically to update the UI
@@ -76,6 +118,7 @@ func NewModel(inputFile string, cfg config.Config) Model {
 		LogViewport: vp,
 		ShowLogs:    false,
 		InputFile:   inputFile,
+		Preparing:   encoder.New(inputFile, cfg),
 	}
 }
 
@@ -87,33 +130,164 @@ func (m Model) Init() tea.Cmd {
 	)
 }
 
+// startEncoding probes, prepares and starts m.Preparing, which was created up front so Stop can
+// reach the analysis passes before the encode itself starts
 func (m *Model) startEncoding() tea.Cmd {
+	enc := m.Preparing
 	return func() tea.Msg {
-		enc := encoder.New(m.InputFile, m.Config)
-
-		// Check bitrate if configured
-		if m.Config.MinBitrate > 0 {
-			bitrate, err := enc.GetBitrate()
-			if err == nil && bitrate > 0 {
-				if bitrate < m.Config.MinBitrate {
-					return SkippedMsg{
-						Reason: fmt.Sprintf("Source bitrate %d kbps is below minimum %d kbps", bitrate, m.Config.MinBitrate),
-					}
-				}
+		// Files this tool produced carry provenance tags; re-encoding them only loses quality
+		if err := enc.Probe(); err != nil {
+			return EncoderErrorMsg{Err: err}
+		}
+		if prov := enc.SourceProvenance(); prov != nil && m.Config.SkipEncoded {
+			return SkippedMsg{
+				Reason: fmt.Sprintf("Already encoded by %s (profile %s, %s)",
+					prov.Tool, prov.Profile, prov.Date.Local().Format("2006-01-02")),
 			}
-			// If we can't determine bitrate, we proceed safely
 		}
 
-		// Get total frames for progress calculation
-		if err := enc.GetTotalFrames(); err != nil {
+		// Sources that are already starved of bits only get worse when re-encoded
+		if reason := enc.BitrateSkipReason(); reason != "" {
+			return SkippedMsg{Reason: reason}
+		}
+
+		// Analyze the source (interlacing, crop, scaling, stream plan)
+		analysis, err := enc.Prepare()
+		if err != nil {
 			return EncoderErrorMsg{Err: err}
 		}
 
+		// Refuse to start an hours-long encode that can't fit on the output filesystem
+		space, err := enc.CheckDiskSpace()
+		if err != nil {
+			return EncoderErrorMsg{Err: err}
+		}
+		if space != "" {
+			analysis = append(analysis, space)
+		}
+
+		// Sidecar subtitles are written up front so they exist even if the encode is stopped
+		sidecars, err := enc.ExtractSubtitles()
+		if err != nil {
+			return EncoderErrorMsg{Err: err}
+		}
+		analysis = append(analysis, sidecars...)
+
 		if err := enc.Start(); err != nil {
 			return EncoderErrorMsg{Err: err}
 		}
 
-		return EncoderStartedMsg{Encoder: enc}
+		return EncoderStartedMsg{Encoder: enc, Analysis: analysis}
+	}
+}
+
+// startCompanion prepares and starts the tonemapped SDR encode linked to the finished main encode
+func startCompanion(enc *encoder.Encoder) tea.Cmd {
+	return func() tea.Msg {
+		analysis, err := enc.Prepare()
+		if err != nil {
+			return EncoderErrorMsg{Err: fmt.Errorf("SDR companion: %w", err)}
+		}
+		space, err := enc.CheckDiskSpace()
+		if err != nil {
+			return EncoderErrorMsg{Err: fmt.Errorf("SDR companion: %w", err)}
+		}
+		if space != "" {
+			analysis = append(analysis, space)
+		}
+		if err := enc.Start(); err != nil {
+			return EncoderErrorMsg{Err: fmt.Errorf("SDR companion: %w", err)}
+		}
+		return EncoderStartedMsg{Encoder: enc, Analysis: analysis}
+	}
+}
+
+// verifyOutput runs the integrity check in the background
+func (m *Model) verifyOutput() tea.Cmd {
+	enc := m.Encoder
+	return func() tea.Msg {
+		result, err := enc.VerifyOutput()
+		return VerifyMsg{Result: result, Err: err}
+	}
+}
+
+// afterEncode starts the next configured post-encode pass, or shows the done view when none remain
+// Verification runs first so quality is only measured on outputs that pass it
+func (m Model) afterEncode() (Model, tea.Cmd) {
+	if m.Config.VerifyOutput && m.Verify == nil {
+		m.State = StateVerifying
+		m.VerifyStatus = "Verifying output integrity"
+		if m.Config.VerifyDecode {
+			m.VerifyStatus += " (full decode)"
+		}
+		return m, m.verifyOutput()
+	}
+	if len(m.Config.QualityMetrics) > 0 && m.Quality == nil && m.QualityError == "" {
+		m.State = StateVerifying
+		m.VerifyStatus = "Measuring quality (" + strings.Join(m.Config.QualityMetrics, ", ") + ")"
+		return m, m.measureQuality()
+	}
+	if m.Config.ContactSheet && m.ContactSheet == "" && m.SheetError == "" {
+		return m.renderContactSheet()
+	}
+	// HDR sources can queue a tonemapped SDR encode; this job's results stay on screen meanwhile
+	if m.Encoder.NeedsSDRCompanion() {
+		m.Finished = append(m.Finished, FinishedJob{
+			Encoder: m.Encoder,
+			Elapsed: time.Since(m.StartTime).Round(time.Second),
+			Verify:  m.Verify,
+			Quality: m.Quality,
+			Sheet:   m.ContactSheet,
+			Retried: retriedAttempts(m.Attempts),
+		})
+		m.Preparing = m.Encoder.SDRCompanion()
+		m.Encoder, m.Verify, m.Quality, m.QualityError = nil, nil, nil, ""
+		m.ContactSheet, m.SheetError = "", ""
+		m.Attempts = nil
+		m.CurrentProgress = encoder.Progress{}
+		m.State = StateEncoding
+		return m, startCompanion(m.Preparing)
+	}
+	m.State = StateDone
+	return m, nil
+}
+
+// measureQuality runs the quality pass in the background
+func (m *Model) measureQuality() tea.Cmd {
+	enc := m.Encoder
+	return func() tea.Msg {
+		report, err := enc.MeasureQuality()
+		return QualityMsg{Report: report, Err: err}
+	}
+}
+
+// renderContactSheet tiles output frames into an image in the background
+func (m Model) renderContactSheet() (Model, tea.Cmd) {
+	m.State = StateVerifying
+	m.VerifyStatus = "Rendering contact sheet"
+	enc := m.Encoder
+	return m, func() tea.Msg {
+		path, err := enc.ContactSheet()
+		return ContactSheetMsg{Path: path, Err: err}
+	}
+}
+
+// retriedAttempts returns the attempt history only when the job needed more than one run
+func retriedAttempts(attempts []encoder.Attempt) []encoder.Attempt {
+	if len(attempts) < 2 {
+		return nil
+	}
+	return attempts
+}
+
+// setError shows err in the error view, with its category when ffmpeg's stderr identified one
+func (m *Model) setError(err error) {
+	m.State = StateError
+	m.ErrorMessage = err.Error()
+	m.Failure = nil
+	var failure *encoder.EncodeError
+	if errors.As(err, &failure) {
+		m.Failure = failure
 	}
 }
 
@@ -134,9 +308,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.Encoder != nil {
 				m.Encoder.Stop()
 			}
+			// Probe and Prepare run ffmpeg on the preparing encoder's context
+			if m.Preparing != nil {
+				m.Preparing.Stop()
+			}
 			return m, tea.Quit
 		case "l":
 			m.ShowLogs = !m.ShowLogs
+		case "s":
+			// Contact sheets can also be made on demand once the job is done
+			if m.State == StateDone && m.Encoder != nil && m.ContactSheet == "" {
+				m.SheetError = ""
+				return m.renderContactSheet()
+			}
 		}
 
 	case tea.WindowSizeMsg:
@@ -154,13 +338,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case EncoderStartedMsg:
 		m.Encoder = msg.Encoder
+		m.Preparing = nil
+		m.Analysis = msg.Analysis
 		m.State = StateEncoding
 		m.StartTime = time.Now()
 		cmds = append(cmds, tickCmd())
 
 	case EncoderErrorMsg:
-		m.State = StateError
-		m.ErrorMessage = msg.Err.Error()
+		m.setError(msg.Err)
 		return m, nil
 
 	case SkippedMsg:
@@ -168,6 +353,37 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.SkippedReason = msg.Reason
 		return m, nil
 
+	case VerifyMsg:
+		if msg.Err != nil {
+			m.State = StateError
+			m.ErrorMessage = "Verification could not run: " + msg.Err.Error()
+			return m, nil
+		}
+		m.Verify = msg.Result
+		if !msg.Result.OK() {
+			// The source is never touched; the suspect output is left for inspection
+			m.State = StateError
+			m.ErrorMessage = "Output failed verification:\n" + strings.Join(msg.Result.Problems, "\n")
+			return m, nil
+		}
+		return m.afterEncode()
+
+	case QualityMsg:
+		m.Quality = msg.Report
+		if msg.Err != nil {
+			// A failed quality pass doesn't invalidate the encode itself
+			m.QualityError = msg.Err.Error()
+		}
+		return m.afterEncode()
+
+	case ContactSheetMsg:
+		m.ContactSheet = msg.Path
+		if msg.Err != nil {
+			// Like the quality pass, a missing sheet doesn't invalidate the encode
+			m.SheetError = msg.Err.Error()
+		}
+		return m.afterEncode()
+
 	case TickMsg:
 		if m.Encoder != nil {
 			// Thread-safe state retrieval
@@ -175,6 +391,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			// Update local state
 			m.CurrentProgress = prog
+			m.Attempts = m.Encoder.AttemptHistory()
 
 			// Update log viewport content
 			if len(logs) > 0 {
@@ -185,20 +402,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			// Check if encoding is done
 			if done {
 				if err != nil {
-					m.State = StateError
-					m.ErrorMessage = err.Error()
-				} else {
-					m.State = StateDone
+					m.setError(err)
+					return m, nil
 				}
-				return m, nil
+				return m.afterEncode()
 			}
 
 			cmds = append(cmds, tickCmd())
 		}
 
 	case error:
-		m.State = StateError
-		m.ErrorMessage = msg.Error()
+		m.setError(msg)
 		return m, nil
 	}
 
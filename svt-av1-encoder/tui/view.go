@@ -141,6 +141,26 @@ func formatETADisplay(eta time.Duration, available bool) string {
 	return formatDuration(eta)
 }
 
+// formatStallStatus describes a stall or earlier restarts, "" while the encode is healthy
+func formatStallStatus(prog encoder.Progress) string {
+	var restarts string
+	if prog.Restarts > 0 {
+		restarts = fmt.Sprintf("restarted %d×", prog.Restarts)
+	}
+	if !prog.Stalled {
+		if restarts != "" {
+			return "↻ Encode " + restarts + " after stalling"
+		}
+		return ""
+	}
+	status := fmt.Sprintf("⚠ Stalled: no progress for %s (ffmpeg may be hung on input)",
+		formatDuration(time.Since(prog.LastAdvance)))
+	if restarts != "" {
+		status += ", " + restarts
+	}
+	return status
+}
+
 // formatPercentage handles cases where percentage cannot be calculated
 func formatPercentage(pct float64, totalFrames int64, totalDuration time.Duration) string {
 	if totalFrames == 0 && totalDuration == 0 {
@@ -201,10 +221,17 @@ func (m Model) View() string {
 
 	case StateSkipped:
 		b.WriteString(m.renderSkippedView())
+
+	case StateVerifying:
+		b.WriteString(m.renderVerifyingView())
 	}
 
 	// Help footer
-	help := helpStyle.Render("  [L] Toggle logs  •  [Q] Quit")
+	keys := "  [L] Toggle logs  •  [Q] Quit"
+	if m.State == StateDone && m.Encoder != nil && m.ContactSheet == "" {
+		keys = "  [S] Contact sheet  •  [L] Toggle logs  •  [Q] Quit"
+	}
+	help := helpStyle.Render(keys)
 	b.WriteString("\n" + help + "\n")
 
 	return b.String()
@@ -257,6 +284,14 @@ func (m Model) renderEncodingView() string {
 
 	b.WriteString("  " + progressBar + "  " + pctStyled + "\n")
 
+	// A hung ffmpeg sends nothing, so say so instead of leaving the last numbers looking live
+	if status := formatStallStatus(prog); status != "" {
+		b.WriteString(warningStyle.Render("  "+status) + "\n")
+	}
+	if attempts := retriedAttempts(m.Attempts); attempts != nil {
+		b.WriteString(buildAttemptLines(attempts) + "\n")
+	}
+
 	// Stats section
 	elapsed := time.Since(m.StartTime).Round(time.Second)
 
@@ -339,6 +374,9 @@ func (m Model) buildStatsGrid(prog encoder.Progress, elapsed time.Duration) stri
 	// Row 3: Size and ETA
 	sizeVal := formatSizeDisplay(prog.TotalSize)
 	etaVal := formatETADisplay(prog.ETA, prog.ETAAvailable)
+	if prog.Stalled {
+		etaVal = "stalled"
+	}
 
 	line3 := lipgloss.JoinHorizontal(lipgloss.Top,
 		statLabelStyle.Render("Size"),
@@ -377,7 +415,11 @@ func (m Model) buildFilesSection() string {
 	line1 := fileLabelStyle.Render("Input") + filePathStyle.Render(inputDisplay)
 	line2 := fileLabelStyle.Render("Output") + filePathStyle.Render(outputDisplay)
 
-	return line1 + "\n" + line2
+	content := line1 + "\n" + line2
+	for _, note := range m.Analysis {
+		content += "\n" + statUnitStyle.Render(truncatePath(note, maxPathLen+8))
+	}
+	return content
 }
 
 func truncatePath(path string, maxLen int) string {
@@ -398,6 +440,11 @@ func (m Model) renderDoneView() string {
 	b.WriteString("\n")
 	b.WriteString(successStyle.Render("  ✓ Encoding Complete!") + "\n")
 
+	for _, job := range m.Finished {
+		b.WriteString(statsBoxStyle.Render(buildFinishedJob(job)))
+		b.WriteString("\n")
+	}
+
 	if m.Encoder != nil {
 		elapsed := time.Since(m.StartTime).Round(time.Second)
 
@@ -424,6 +471,11 @@ func (m Model) renderDoneView() string {
 		lines = append(lines,
 			statLabelStyle.Render("Size")+statValueStyle.Render(formatBytes(finalSize)))
 
+		// Integrity check
+		if m.Verify != nil {
+			lines = append(lines, successStyle.Render("  ✓ Verified: "+m.Verify.Summary()))
+		}
+
 		// Size comparison
 		if err == nil {
 			if passed {
@@ -437,17 +489,115 @@ func (m Model) renderDoneView() string {
 
 		content := lipgloss.JoinVertical(lipgloss.Left, lines...)
 		b.WriteString(statsBoxStyle.Render(content))
+
+		if quality := m.buildQualitySection(); quality != "" {
+			b.WriteString("\n")
+			b.WriteString(statsBoxStyle.Render(quality))
+		}
+
+		if attempts := retriedAttempts(m.Attempts); attempts != nil {
+			b.WriteString("\n" + buildAttemptLines(attempts) + "\n")
+		}
+
+		if m.ContactSheet != "" {
+			b.WriteString("\n" + statLabelStyle.Render("Sheet") + filePathStyle.Render(m.ContactSheet) + "\n")
+		} else if m.SheetError != "" {
+			b.WriteString("\n" + warningStyle.Render("  ⚠ Contact sheet failed: "+m.SheetError) + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// buildFinishedJob summarizes an earlier job in the chain (output, time, size, checks)
+func buildFinishedJob(job FinishedJob) string {
+	lines := []string{
+		statLabelStyle.Render("Output") + filePathStyle.Render(job.Encoder.OutputPath),
+		statLabelStyle.Render("Time") + statValueStyle.Render(formatDuration(job.Elapsed)),
+	}
+	if size, err := job.Encoder.GetActualOutputSize(); err == nil {
+		lines = append(lines, statLabelStyle.Render("Size")+statValueStyle.Render(formatBytes(size)))
+	}
+	if job.Verify != nil {
+		lines = append(lines, successStyle.Render("  ✓ Verified: "+job.Verify.Summary()))
+	}
+	if job.Quality != nil {
+		for _, s := range job.Quality.Scores {
+			lines = append(lines, statLabelStyle.Render(strings.ToUpper(s.Metric))+
+				statValueStyle.Render(formatQualityScore(s.Metric, s.Mean)))
+		}
+	}
+	if job.Sheet != "" {
+		lines = append(lines, statLabelStyle.Render("Sheet")+filePathStyle.Render(job.Sheet))
+	}
+	if job.Retried != nil {
+		lines = append(lines, buildAttemptLines(job.Retried))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+func (m Model) renderVerifyingView() string {
+	var b strings.Builder
+
+	b.WriteString("\n")
+	b.WriteString(successStyle.Render("  ✓ Encoding Complete") + "\n")
+	b.WriteString(statValueStyle.Render("  "+m.VerifyStatus+"...") + "\n")
+
+	// Log viewport if enabled
+	if m.ShowLogs {
+		b.WriteString("\n")
+		logHeader := sectionHeaderStyle.Render("  Encoder Output")
+		b.WriteString(logHeader + "\n")
+		b.WriteString(logBoxStyle.Render(m.LogViewport.View()))
 	}
 
 	return b.String()
 }
 
+// buildQualitySection renders the quality report (or why it's missing) for the done view
+func (m Model) buildQualitySection() string {
+	if m.QualityError != "" {
+		return warningStyle.Render("  ⚠ Quality check failed: " + m.QualityError)
+	}
+	if m.Quality == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, s := range m.Quality.Scores {
+		lines = append(lines, statLabelStyle.Render(strings.ToUpper(s.Metric))+
+			statValueStyle.Render(formatQualityScore(s.Metric, s.Mean))+
+			statUnitStyle.Render(fmt.Sprintf("  1%% low %s  worst %s (frame %d)",
+				formatQualityScore(s.Metric, s.Percentile1), formatQualityScore(s.Metric, s.Worst), s.WorstFrame)))
+	}
+	if m.Quality.ReportPath != "" {
+		lines = append(lines, statLabelStyle.Render("Report")+filePathStyle.Render(m.Quality.ReportPath))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// formatQualityScore shows SSIM (0-1) with more precision than VMAF/PSNR
+func formatQualityScore(metric string, v float64) string {
+	if metric == "ssim" {
+		return fmt.Sprintf("%.4f", v)
+	}
+	return fmt.Sprintf("%.2f", v)
+}
+
 func (m Model) renderErrorView() string {
 	var b strings.Builder
 
 	b.WriteString("\n")
 	b.WriteString(errorStyle.Render("  ✗ Encoding Failed") + "\n\n")
 
+	// The recognised cause and its fix come first; the raw log is below for the details
+	if m.Failure != nil {
+		b.WriteString(buildFailureBox(m.Failure) + "\n")
+	}
+	if attempts := retriedAttempts(m.Attempts); attempts != nil {
+		b.WriteString(buildAttemptLines(attempts) + "\n\n")
+	}
+
 	// Error message in a box
 	errBox := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
@@ -469,6 +619,37 @@ func (m Model) renderErrorView() string {
 	return b.String()
 }
 
+// buildAttemptLines lists each ffmpeg run of a retried job with its fallback and result
+func buildAttemptLines(attempts []encoder.Attempt) string {
+	lines := []string{sectionHeaderStyle.Render("  Attempts")}
+	for i, a := range attempts {
+		line := "  " + a.Describe(i+1)
+		if a.Elapsed > 0 {
+			line += " (" + formatDuration(a.Elapsed) + ")"
+		}
+		style := statValueStyle
+		if a.Result != "" && a.Result != "completed" {
+			style = warningStyle
+		}
+		lines = append(lines, style.Render(line))
+	}
+	return lipgloss.JoinVertical(lipgloss.Left, lines...)
+}
+
+// buildFailureBox shows an error's category, the stderr lines that identified it and the suggested fix
+func buildFailureBox(f *encoder.EncodeError) string {
+	lines := []string{errorStyle.Render(f.Title)}
+	for _, line := range f.Lines {
+		lines = append(lines, statValueStyle.Render("  "+line))
+	}
+	lines = append(lines, "", statLabelStyle.Render("Fix")+statValueStyle.Render(f.Suggestion))
+	return lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(colorWarning).
+		Padding(0, 2).
+		Render(lipgloss.JoinVertical(lipgloss.Left, lines...))
+}
+
 func (m Model) renderSkippedView() string {
 	var b strings.Builder
 
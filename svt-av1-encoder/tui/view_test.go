@@ -5,6 +5,8 @@ import (
 	"testing"
 	"testing/quick"
 	"time"
+
+	"svt-av1-encoder/encoder"
 )
 
 // Feature: tui-accuracy-fix, Property 13: File Size Formatting
@@ -272,3 +274,17 @@ func TestTruncatePath(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatStallStatus(t *testing.T) {
+	if got := formatStallStatus(encoder.Progress{}); got != "" {
+		t.Errorf("healthy encode should have no status, got %q", got)
+	}
+	stalled := encoder.Progress{Stalled: true, LastAdvance: time.Now().Add(-6 * time.Minute), Restarts: 1}
+	got := formatStallStatus(stalled)
+	if !strings.Contains(got, "no progress for 6:00") || !strings.Contains(got, "restarted 1×") {
+		t.Errorf("formatStallStatus = %q", got)
+	}
+	if got := formatStallStatus(encoder.Progress{Restarts: 2}); !strings.Contains(got, "restarted 2×") {
+		t.Errorf("recovered encode should still mention restarts, got %q", got)
+	}
+}